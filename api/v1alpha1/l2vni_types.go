@@ -0,0 +1,90 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// L2VNISpec defines the desired state of L2VNI.
+type L2VNISpec struct {
+	// VNI is the VXLan VNI to be used for the layer 2 domain.
+	VNI uint32 `json:"vni,omitempty"`
+
+	// VXLanPort is the port to be used for the VXLan encapsulation.
+	// +optional
+	VXLanPort uint32 `json:"vxlanport,omitempty"`
+
+	// VRF is the name of the vrf of the (L3) VNI the layer 2 domain is
+	// tied to. When set, the traffic of the layer 2 domain is routed
+	// through the given vrf. When not set, the layer 2 domain is
+	// not attached to any vrf.
+	// +optional
+	VRF *string `json:"vrf,omitempty"`
+
+	// HostMaster is the interface on the host the host side of the
+	// veth pair is attached to. If not set, the host side of the veth
+	// is left unattached.
+	// +optional
+	HostMaster *HostMaster `json:"hostmaster,omitempty"`
+
+	// NodeSelector specifies the nodes this l2vni applies to.
+	// If not set, the l2vni applies to all the nodes.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+}
+
+// HostMaster represents the interface on the host the veth
+// of a layer 2 domain is attached to.
+type HostMaster struct {
+	// Name is the name of the interface on the host. It must be a linux bridge.
+	Name string `json:"name,omitempty"`
+
+	// AutoCreate, if true, creates a linux bridge with the given name
+	// on the host when it does not exist.
+	// +optional
+	AutoCreate bool `json:"autocreate,omitempty"`
+}
+
+// L2VNIStatus defines the observed state of L2VNI.
+type L2VNIStatus struct {
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// L2VNI is the Schema for the l2vnis API.
+type L2VNI struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   L2VNISpec   `json:"spec,omitempty"`
+	Status L2VNIStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// L2VNIList contains a list of L2VNI.
+type L2VNIList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []L2VNI `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&L2VNI{}, &L2VNIList{})
+}
@@ -35,7 +35,7 @@ type Neighbor struct {
 
 	// PasswordSecret is name of the authentication secret for the neighbor.
 	// the secret must be of type "kubernetes.io/basic-auth", and created in the
-	// same namespace as the frr-k8s daemon. The password is stored in the
+	// same namespace as the openperouter controller. The password is stored in the
 	// secret as the key "password".
 	// Password and PasswordSecret are mutually exclusive.
 	// +optional
@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RouterStateStatus is the routing state of the router running on a node,
+// as reported by FRR, together with the network devices the controller
+// manages on the node.
+type RouterStateStatus struct {
+	// LastUpdateTime is the last time the state was refreshed.
+	// +optional
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+
+	// Error is set when the state of the router could not be retrieved,
+	// in which case the routing state is the one retrieved last.
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// EstablishedSessions is the number of BGP sessions in the
+	// Established state.
+	EstablishedSessions int `json:"establishedSessions"`
+
+	// TotalSessions is the number of BGP sessions configured.
+	TotalSessions int `json:"totalSessions"`
+
+	// VNICount is the number of EVPN vnis known to the router.
+	VNICount int `json:"vniCount"`
+
+	// BGPSessions are the BGP sessions of the router, across all the vrfs.
+	// +optional
+	BGPSessions []BGPSessionState `json:"bgpSessions,omitempty"`
+
+	// BFDPeers are the BFD peers of the router.
+	// +optional
+	BFDPeers []BFDPeerState `json:"bfdPeers,omitempty"`
+
+	// VRFs are the vrfs known to FRR.
+	// +optional
+	VRFs []string `json:"vrfs,omitempty"`
+
+	// VNIs are the EVPN vnis known to the router.
+	// +optional
+	VNIs []EVPNVNIState `json:"vnis,omitempty"`
+
+	// Devices are the network devices managed by the controller, both
+	// in the host and in the router's network namespace.
+	// +optional
+	Devices []DeviceState `json:"devices,omitempty"`
+}
+
+// BGPSessionState is the state of a BGP session.
+type BGPSessionState struct {
+	Peer             string `json:"peer"`
+	VRF              string `json:"vrf"`
+	Established      bool   `json:"established"`
+	RemoteAS         string `json:"remoteAS,omitempty"`
+	PrefixesSent     int    `json:"prefixesSent"`
+	PrefixesReceived int    `json:"prefixesReceived"`
+}
+
+// BFDPeerState is the state of a BFD session.
+type BFDPeerState struct {
+	Peer   string `json:"peer"`
+	Local  string `json:"local,omitempty"`
+	VRF    string `json:"vrf,omitempty"`
+	Status string `json:"status"`
+}
+
+// EVPNVNIState is an EVPN vni known to the router.
+type EVPNVNIState struct {
+	VNI  int    `json:"vni"`
+	Type string `json:"type"`
+	VRF  string `json:"vrf,omitempty"`
+}
+
+// DeviceState is a network device managed by the controller.
+type DeviceState struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Namespace is where the device lives, either host or router.
+	Namespace string `json:"namespace"`
+	OperState string `json:"operState"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Established",type=integer,JSONPath=`.status.establishedSessions`
+// +kubebuilder:printcolumn:name="Sessions",type=integer,JSONPath=`.status.totalSessions`
+// +kubebuilder:printcolumn:name="VNIs",type=integer,JSONPath=`.status.vniCount`
+// +kubebuilder:printcolumn:name="Updated",type=date,JSONPath=`.status.lastUpdateTime`
+// +kubebuilder:printcolumn:name="Error",type=string,JSONPath=`.status.error`,priority=1
+
+// RouterState reports the routing state of the router running on a node.
+// There is one per node, named after the node and written by the
+// controller running on it.
+type RouterState struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status RouterStateStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RouterStateList contains a list of RouterState.
+type RouterStateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RouterState `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RouterState{}, &RouterStateList{})
+}
@@ -25,7 +25,27 @@ type UnderlaySpec struct {
 	ASN       uint32     `json:"asn,omitempty"`
 	VTEPCIDR  string     `json:"vtepcidr,omitempty"`
 	Neighbors []Neighbor `json:"neighbors,omitempty"`
-	Nic       string     `json:"nic,omitempty"`
+
+	// Nics is the list of the host interfaces connected to the external
+	// routers, which are moved into the router's namespace. When more than
+	// one nic is set, the paths toward the vteps are balanced across them.
+	Nics []string `json:"nics,omitempty"`
+
+	// Nic is the host interface connected to the external routers.
+	// Deprecated: use Nics instead. It is merged into Nics, and it can't be
+	// set together with it.
+	// +optional
+	Nic string `json:"nic,omitempty"`
+
+	// BFDProfiles is the list of bfd profiles to be used when configuring
+	// the neighbors.
+	// +optional
+	BFDProfiles []BFDProfile `json:"bfdProfiles,omitempty"`
+
+	// NodeSelector specifies the nodes this underlay applies to.
+	// If not set, the underlay applies to all the nodes.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
 }
 
 // UnderlayStatus defines the observed state of Underlay.
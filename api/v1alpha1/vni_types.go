@@ -25,20 +25,90 @@ import (
 
 // VNISpec defines the desired state of VNI.
 type VNISpec struct {
-	ASN       uint32 `json:"asn,omitempty"`
-	VRF       string `json:"vrf,omitempty"`
-	LocalASN  uint32 `json:"localasn,omitempty"`
-	VNI       uint32 `json:"vni,omitempty"`
+	ASN      uint32 `json:"asn,omitempty"`
+	VRF      string `json:"vrf,omitempty"`
+	LocalASN uint32 `json:"localasn,omitempty"`
+	VNI      uint32 `json:"vni,omitempty"`
+	// LocalCIDR is the ipv4 cidr to be used for the veth pair
+	// to connect with the default namespace. The router side of
+	// the veth gets the first address of the cidr.
+	// At least one of LocalCIDR and LocalCIDRV6 must be set.
 	LocalCIDR string `json:"localcidr,omitempty"`
-	VXLanPort uint32 `json:"vxlanport,omitempty"`
+	// LocalCIDRV6 is the ipv6 cidr to be used for the veth pair,
+	// together with LocalCIDR for dual stack.
+	// +optional
+	LocalCIDRV6 string `json:"localcidrv6,omitempty"`
+	VXLanPort   uint32 `json:"vxlanport,omitempty"`
+
+	// NodeSelector specifies the nodes this vni applies to.
+	// If not set, the vni applies to all the nodes.
+	// +optional
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
 }
 
 // VNIStatus defines the observed state of VNI.
 type VNIStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Nodes contains the per node status of the VNI, as reported by
+	// the controller running on each node.
+	// +optional
+	// +listType=map
+	// +listMapKey=node
+	Nodes []VNINodeStatus `json:"nodes,omitempty"`
+}
+
+// VNINodeStatus represents the status of the VNI on a given node.
+type VNINodeStatus struct {
+	// Node is the name of the node the status refers to.
+	Node string `json:"node"`
+	// HostIPs are the IPs assigned to the host side of the veth pair
+	// connecting the router to the host, one per ip family.
+	// +optional
+	HostIPs []string `json:"hostIPs,omitempty"`
+	// PEIPs are the IPs assigned to the router side of the veth pair
+	// connecting the router to the host, one per ip family. These are the
+	// addresses the BGP speaker running on the host must peer with.
+	// +optional
+	PEIPs []string `json:"peIPs,omitempty"`
+	// VTEPIP is the IP of the VTEP assigned to the node.
+	// +optional
+	VTEPIP string `json:"vtepIP,omitempty"`
+	// Conditions contains the Ready condition of the VNI on the node. When
+	// the configuration fails, the message of the condition contains the last
+	// error.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+const (
+	// VNIReadyCondition is the condition type reporting whether the VNI
+	// was configured successfully on the node.
+	VNIReadyCondition = "Ready"
+	// VNIConfiguredReason is the reason used when the VNI is configured.
+	VNIConfiguredReason = "Configured"
+	// VNIFailedReason is the reason used when the VNI configuration failed.
+	VNIFailedReason = "Failed"
+)
+
+// The reasons of the failures reported in the conditions and in the events
+// related to the openperouter resources.
+const (
+	// ConversionFailedReason is used when the resources cannot be converted
+	// to the configuration of the router.
+	ConversionFailedReason = "ConversionFailed"
+	// FRRReloadFailedReason is used when the FRR configuration cannot be applied.
+	FRRReloadFailedReason = "FRRReloadFailed"
+	// HostNetworkFailedReason is used when the network interfaces of the
+	// host or of the router cannot be configured.
+	HostNetworkFailedReason = "HostNetworkFailed"
+)
+
+// NICSettingsNotPreservedReason is used when some settings of the nics
+// serving the underlay are lost moving them to the router namespace
+// or back to the host.
+const NICSettingsNotPreservedReason = "NICSettingsNotPreserved"
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
@@ -11,6 +11,235 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BFDPeerState) DeepCopyInto(out *BFDPeerState) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BFDPeerState.
+func (in *BFDPeerState) DeepCopy() *BFDPeerState {
+	if in == nil {
+		return nil
+	}
+	out := new(BFDPeerState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BFDProfile) DeepCopyInto(out *BFDProfile) {
+	*out = *in
+	if in.ReceiveInterval != nil {
+		in, out := &in.ReceiveInterval, &out.ReceiveInterval
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.TransmitInterval != nil {
+		in, out := &in.TransmitInterval, &out.TransmitInterval
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.DetectMultiplier != nil {
+		in, out := &in.DetectMultiplier, &out.DetectMultiplier
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.EchoInterval != nil {
+		in, out := &in.EchoInterval, &out.EchoInterval
+		*out = new(uint32)
+		**out = **in
+	}
+	if in.EchoMode != nil {
+		in, out := &in.EchoMode, &out.EchoMode
+		*out = new(bool)
+		**out = **in
+	}
+	if in.PassiveMode != nil {
+		in, out := &in.PassiveMode, &out.PassiveMode
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MinimumTTL != nil {
+		in, out := &in.MinimumTTL, &out.MinimumTTL
+		*out = new(uint32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BFDProfile.
+func (in *BFDProfile) DeepCopy() *BFDProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(BFDProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BGPSessionState) DeepCopyInto(out *BGPSessionState) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BGPSessionState.
+func (in *BGPSessionState) DeepCopy() *BGPSessionState {
+	if in == nil {
+		return nil
+	}
+	out := new(BGPSessionState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceState) DeepCopyInto(out *DeviceState) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceState.
+func (in *DeviceState) DeepCopy() *DeviceState {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EVPNVNIState) DeepCopyInto(out *EVPNVNIState) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EVPNVNIState.
+func (in *EVPNVNIState) DeepCopy() *EVPNVNIState {
+	if in == nil {
+		return nil
+	}
+	out := new(EVPNVNIState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostMaster) DeepCopyInto(out *HostMaster) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostMaster.
+func (in *HostMaster) DeepCopy() *HostMaster {
+	if in == nil {
+		return nil
+	}
+	out := new(HostMaster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *L2VNI) DeepCopyInto(out *L2VNI) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2VNI.
+func (in *L2VNI) DeepCopy() *L2VNI {
+	if in == nil {
+		return nil
+	}
+	out := new(L2VNI)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *L2VNI) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *L2VNIList) DeepCopyInto(out *L2VNIList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]L2VNI, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2VNIList.
+func (in *L2VNIList) DeepCopy() *L2VNIList {
+	if in == nil {
+		return nil
+	}
+	out := new(L2VNIList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *L2VNIList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *L2VNISpec) DeepCopyInto(out *L2VNISpec) {
+	*out = *in
+	if in.VRF != nil {
+		in, out := &in.VRF, &out.VRF
+		*out = new(string)
+		**out = **in
+	}
+	if in.HostMaster != nil {
+		in, out := &in.HostMaster, &out.HostMaster
+		*out = new(HostMaster)
+		**out = **in
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2VNISpec.
+func (in *L2VNISpec) DeepCopy() *L2VNISpec {
+	if in == nil {
+		return nil
+	}
+	out := new(L2VNISpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *L2VNIStatus) DeepCopyInto(out *L2VNIStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new L2VNIStatus.
+func (in *L2VNIStatus) DeepCopy() *L2VNIStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(L2VNIStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Neighbor) DeepCopyInto(out *Neighbor) {
 	*out = *in
@@ -46,6 +275,108 @@ func (in *Neighbor) DeepCopy() *Neighbor {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouterState) DeepCopyInto(out *RouterState) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouterState.
+func (in *RouterState) DeepCopy() *RouterState {
+	if in == nil {
+		return nil
+	}
+	out := new(RouterState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RouterState) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouterStateList) DeepCopyInto(out *RouterStateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RouterState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouterStateList.
+func (in *RouterStateList) DeepCopy() *RouterStateList {
+	if in == nil {
+		return nil
+	}
+	out := new(RouterStateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RouterStateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouterStateStatus) DeepCopyInto(out *RouterStateStatus) {
+	*out = *in
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+	if in.BGPSessions != nil {
+		in, out := &in.BGPSessions, &out.BGPSessions
+		*out = make([]BGPSessionState, len(*in))
+		copy(*out, *in)
+	}
+	if in.BFDPeers != nil {
+		in, out := &in.BFDPeers, &out.BFDPeers
+		*out = make([]BFDPeerState, len(*in))
+		copy(*out, *in)
+	}
+	if in.VRFs != nil {
+		in, out := &in.VRFs, &out.VRFs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.VNIs != nil {
+		in, out := &in.VNIs, &out.VNIs
+		*out = make([]EVPNVNIState, len(*in))
+		copy(*out, *in)
+	}
+	if in.Devices != nil {
+		in, out := &in.Devices, &out.Devices
+		*out = make([]DeviceState, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouterStateStatus.
+func (in *RouterStateStatus) DeepCopy() *RouterStateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RouterStateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Underlay) DeepCopyInto(out *Underlay) {
 	*out = *in
@@ -115,6 +446,23 @@ func (in *UnderlaySpec) DeepCopyInto(out *UnderlaySpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Nics != nil {
+		in, out := &in.Nics, &out.Nics
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BFDProfiles != nil {
+		in, out := &in.BFDProfiles, &out.BFDProfiles
+		*out = make([]BFDProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UnderlaySpec.
@@ -147,8 +495,8 @@ func (in *VNI) DeepCopyInto(out *VNI) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VNI.
@@ -201,9 +549,46 @@ func (in *VNIList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VNINodeStatus) DeepCopyInto(out *VNINodeStatus) {
+	*out = *in
+	if in.HostIPs != nil {
+		in, out := &in.HostIPs, &out.HostIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PEIPs != nil {
+		in, out := &in.PEIPs, &out.PEIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VNINodeStatus.
+func (in *VNINodeStatus) DeepCopy() *VNINodeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VNINodeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VNISpec) DeepCopyInto(out *VNISpec) {
 	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VNISpec.
@@ -219,6 +604,13 @@ func (in *VNISpec) DeepCopy() *VNISpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VNIStatus) DeepCopyInto(out *VNIStatus) {
 	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]VNINodeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VNIStatus.
@@ -27,20 +27,25 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	"github.com/go-logr/logr/slogr"
 	periov1alpha1 "github.com/openperouter/openperouter/api/v1alpha1"
 	"github.com/openperouter/openperouter/internal/controller"
 	"github.com/openperouter/openperouter/internal/logging"
 	"github.com/openperouter/openperouter/internal/pods"
+	"github.com/openperouter/openperouter/internal/reloaderconn"
+	"github.com/openperouter/openperouter/internal/webhooks"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -66,9 +71,14 @@ func main() {
 		nodeName      string
 		namespace     string
 		logLevel      string
-		frrConfigPath string
 		reloadPort    int
 		criSocket     string
+		webhookMode   bool
+		webhookPort   int
+		certDir       string
+		stateDir      string
+		stateInterval time.Duration
+		reloaderOpts  reloaderconn.ClientOptions
 	)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -80,9 +90,18 @@ func main() {
 	flag.StringVar(&nodeName, "nodename", "", "The name of the node the controller runs on")
 	flag.StringVar(&namespace, "namespace", "", "The namespace the controller runs in")
 	flag.StringVar(&logLevel, "loglevel", "info", "the verbosity of the process")
-	flag.StringVar(&frrConfigPath, "frrconfig", "/etc/perouter/frr/frr.conf", "the location of the frr configuration file")
 	flag.IntVar(&reloadPort, "reloadport", 9080, "the port of the reloader process")
+	flag.StringVar(&reloaderOpts.Socket, "reloader-socket", "", "the path of the unix socket the reloader listens on, on the volume shared with the router pod. If set, it is used instead of the reload port")
+	flag.StringVar(&reloaderOpts.TLS.CertFile, "reloader-tls-cert", "", "the client certificate presented to the reloader. If set, the reloader is reached with mutual TLS")
+	flag.StringVar(&reloaderOpts.TLS.KeyFile, "reloader-tls-key", "", "the key of the client certificate presented to the reloader")
+	flag.StringVar(&reloaderOpts.TLS.CAFile, "reloader-tls-ca", "", "the CA bundle the certificate of the reloader is verified against")
+	flag.StringVar(&reloaderOpts.ServerName, "reloader-tls-server-name", reloaderconn.DefaultServerName, "the name the certificate of the reloader is verified against")
 	flag.StringVar(&criSocket, "crisocket", "/var/run/containerd/containerd.sock", "the location of the cri socket")
+	flag.BoolVar(&webhookMode, "enable-webhooks", false, "If set, the validating webhooks for the openperouter resources are served")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "the port the webhook server listens on")
+	flag.StringVar(&stateDir, "statedir", "/var/lib/openperouter", "the directory where the configuration of the nics moved to the router is saved")
+	flag.DurationVar(&stateInterval, "router-state-interval", time.Minute, "how often the routing state of the node is recorded in its RouterState, 0 disables it")
+	flag.StringVar(&certDir, "webhook-cert-dir", "/tmp/k8s-webhook-server/serving-certs", "the directory containing the webhook server certificates")
 
 	flag.Parse()
 
@@ -130,11 +149,32 @@ func main() {
 		// this setup is not recommended for production.
 	}
 
+	webhookServer := webhook.NewServer(webhook.Options{
+		Port:    webhookPort,
+		CertDir: certDir,
+		TLSOpts: tlsOpts,
+	})
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
+		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
-		Cache:                  cache.Options{},
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				// the secrets the bgp passwords are read from live in the controller's namespace
+				&corev1.Secret{}: {
+					Namespaces: map[string]cache.Config{namespace: {}},
+				},
+			},
+		},
+		Client: client.Options{
+			Cache: &client.CacheOptions{
+				// the node indexes are claimed by creating configmaps, reading them from the cache
+				// could lead to the same node claiming more than one index.
+				DisableFor: []client.Object{&corev1.ConfigMap{}},
+			},
+		},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -147,20 +187,35 @@ func main() {
 		os.Exit(1)
 	}
 
+	reloaderClient, err := reloaderconn.NewClient(reloaderOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to create the reloader client")
+		os.Exit(1)
+	}
+
 	if err = (&controller.PERouterReconciler{
-		Client:      mgr.GetClient(),
-		Scheme:      mgr.GetScheme(),
-		MyNode:      nodeName,
-		FRRConfig:   frrConfigPath,
-		ReloadPort:  reloadPort,
-		PodRuntime:  podRuntime,
-		LogLevel:    logLevel,
-		Logger:      logger,
-		MyNamespace: namespace,
+		Client:              mgr.GetClient(),
+		Scheme:              mgr.GetScheme(),
+		MyNode:              nodeName,
+		ReloadPort:          reloadPort,
+		ReloaderClient:      reloaderClient,
+		PodRuntime:          podRuntime,
+		LogLevel:            logLevel,
+		Logger:              logger,
+		MyNamespace:         namespace,
+		StateDir:            stateDir,
+		Recorder:            mgr.GetEventRecorderFor("openperouter-controller"),
+		RouterStateInterval: stateInterval,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Underlay")
 		os.Exit(1)
 	}
+	if webhookMode {
+		if err := webhooks.Setup(mgr, namespace); err != nil {
+			setupLog.Error(err, "unable to create webhooks")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
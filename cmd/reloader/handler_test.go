@@ -1,64 +1,142 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/openperouter/openperouter/internal/frrconfig"
 )
 
 func TestHandler(t *testing.T) {
-	reloadSucceeds := func(_ string) error {
-		return nil
+	reloadSucceeds := func(_ context.Context, _ string) (string, error) {
+		return "reloaded", nil
 	}
 
-	reloadFails := func(_ string) error {
-		return errors.New("failed")
+	reloadFails := func(_ context.Context, _ string) (string, error) {
+		return "", errors.New("failed")
+	}
+
+	testFails := func(_ context.Context, _ string) (string, error) {
+		return "invalid config", &frrconfig.UpdateError{Action: frrconfig.Test, Output: "invalid config", Err: errors.New("exit status 1")}
+	}
+
+	validRequest := func() []byte {
+		config := "router bgp 64512\n"
+		res, _ := json.Marshal(frrconfig.ReloadRequest{Version: frrconfig.ConfigVersion(config), Config: config})
+		return res
 	}
 
 	tests := []struct {
-		name       string
-		reloadMock func(string) error
-		method     string
-		httpStatus int
+		name           string
+		reloadMock     func(context.Context, string) (string, error)
+		method         string
+		body           []byte
+		httpStatus     int
+		expectedResult *frrconfig.ReloadResult
 	}{
 		{
-			"succeeds",
-			reloadSucceeds,
-			http.MethodPost,
-			200,
+			name:       "succeeds",
+			reloadMock: reloadSucceeds,
+			method:     http.MethodPost,
+			body:       validRequest(),
+			httpStatus: http.StatusOK,
+			expectedResult: &frrconfig.ReloadResult{
+				Version: frrconfig.ConfigVersion("router bgp 64512\n"),
+				Success: true,
+				Output:  "reloaded",
+			},
+		},
+		{
+			name:       "wrong method",
+			reloadMock: reloadSucceeds,
+			method:     http.MethodGet,
+			httpStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid body",
+			reloadMock: reloadSucceeds,
+			method:     http.MethodPost,
+			body:       []byte("not json"),
+			httpStatus: http.StatusBadRequest,
 		},
 		{
-			"wrong method",
-			reloadSucceeds,
-			http.MethodGet,
-			http.StatusBadRequest,
+			name:       "version mismatch",
+			reloadMock: reloadSucceeds,
+			method:     http.MethodPost,
+			body:       []byte(`{"version": "1234", "config": "router bgp 64512\n"}`),
+			httpStatus: http.StatusBadRequest,
 		},
 		{
-			"reload fails",
-			reloadFails,
-			http.MethodPost,
-			http.StatusInternalServerError,
+			name:       "reload fails",
+			reloadMock: reloadFails,
+			method:     http.MethodPost,
+			body:       validRequest(),
+			httpStatus: http.StatusInternalServerError,
+			expectedResult: &frrconfig.ReloadResult{
+				Version: frrconfig.ConfigVersion("router bgp 64512\n"),
+				Error:   "failed",
+			},
+		},
+		{
+			name:       "test fails",
+			reloadMock: testFails,
+			method:     http.MethodPost,
+			body:       validRequest(),
+			httpStatus: http.StatusInternalServerError,
+			expectedResult: &frrconfig.ReloadResult{
+				Version:    frrconfig.ConfigVersion("router bgp 64512\n"),
+				FailedStep: frrconfig.Test,
+				Output:     "invalid config",
+				Error:      "frr update test failed: exit status 1",
+			},
 		},
 	}
 
-	t.Cleanup(func() {
-		updateConfig = frrconfig.Update
-	})
+	frrConfigPath = filepath.Join(t.TempDir(), "frr.conf")
 	for _, tc := range tests {
-		updateConfig = tc.reloadMock
 		t.Run(tc.name, func(t *testing.T) {
+			reloader, err := frrconfig.NewReloader(frrConfigPath, tc.reloadMock)
+			if err != nil {
+				t.Fatalf("failed to create the reloader: %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			reloads = frrconfig.NewQueue(reloader)
+			go reloads.Run(ctx)
 			w := httptest.NewRecorder()
-			req, _ := http.NewRequest(tc.method, "/", nil)
+			req, _ := http.NewRequest(tc.method, "/", bytes.NewReader(tc.body))
 			handler := http.HandlerFunc(reloadHandler)
 
 			handler.ServeHTTP(w, req)
 			res := w.Result()
-			res.Body.Close()
+			defer res.Body.Close()
 			if res.StatusCode != tc.httpStatus {
-				t.Fatalf("expecting %d, got %d", res.StatusCode, tc.httpStatus)
+				t.Fatalf("expecting %d, got %d", tc.httpStatus, res.StatusCode)
+			}
+			if tc.expectedResult == nil {
+				return
+			}
+			var result frrconfig.ReloadResult
+			if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+				t.Fatalf("failed to decode the result: %v", err)
+			}
+			if !reflect.DeepEqual(result, *tc.expectedResult) {
+				t.Fatalf("expecting result %+v, got %+v", *tc.expectedResult, result)
+			}
+			content, err := os.ReadFile(frrConfigPath)
+			if err != nil {
+				t.Fatalf("failed to read the config file: %v", err)
+			}
+			if string(content) != "router bgp 64512\n" {
+				t.Fatalf("unexpected config file content %q", content)
 			}
 		})
 	}
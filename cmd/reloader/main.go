@@ -20,46 +20,134 @@ import (
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/openperouter/openperouter/internal/frrconfig"
+	"github.com/openperouter/openperouter/internal/frrmetrics"
+	"github.com/openperouter/openperouter/internal/frrstate"
 	"github.com/openperouter/openperouter/internal/logging"
+	"github.com/openperouter/openperouter/internal/reloaderconn"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var frrConfigPath string
 
+// reloads serializes the reloads of the configurations received, set in main.
+var reloads *frrconfig.Queue
+
 func main() {
 	var bindAddress string
 	var logLevel string
+	var metricsInterval time.Duration
+	var metricsBindAddress string
+	var applier string
+	var serverOpts reloaderconn.ServerOptions
 	flag.StringVar(&bindAddress, "bindaddress", "0.0.0.0:9080", "The address the reloader endpoint binds to. ")
+	flag.StringVar(&serverOpts.Socket, "socket", "", "The path of the unix socket the reloader endpoint listens on, instead of the bind address")
+	flag.StringVar(&serverOpts.TLS.CertFile, "tls-cert", "", "The certificate the reloader endpoint is served with. If set, the endpoint requires mutual TLS")
+	flag.StringVar(&serverOpts.TLS.KeyFile, "tls-key", "", "The key of the certificate the reloader endpoint is served with")
+	flag.StringVar(&serverOpts.TLS.CAFile, "tls-client-ca", "", "The CA bundle the client certificates are verified against")
+	flag.StringVar(&metricsBindAddress, "metrics-bindaddress", "", "The address /metrics is served on with plain HTTP. If empty, it is served by the reloader endpoint")
 	flag.StringVar(&frrConfigPath, "frrconfig", "/etc/frr/frr.conf", "The path the frr configuration is at")
+	flag.StringVar(&applier, "applier", frrconfig.FRRReloadApplier, fmt.Sprintf("How the configuration is applied to FRR: %s runs frr-reload.py, %s computes and applies the changes in go", frrconfig.FRRReloadApplier, frrconfig.NativeApplier))
 	flag.StringVar(&logLevel, "loglevel", "info", "The log level of the process")
+	flag.DurationVar(&metricsInterval, "metrics-poll-interval", 30*time.Second, "How often FRR is queried for the metrics served on /metrics. 0 disables the metrics.")
 	flag.Parse()
 
 	_, err := logging.New(logLevel)
 	if err != nil {
 		fmt.Println("failed to init logger", err)
 	}
-	slog.Info("listening", "address", bindAddress)
+	update, err := frrconfig.UpdateFor(applier)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reloader, err := frrconfig.NewReloader(frrConfigPath, update)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reloads = frrconfig.NewQueue(reloader)
+	go reloads.Run(context.Background())
 	http.HandleFunc("/", reloadHandler)
-	log.Fatal(http.ListenAndServe(bindAddress, nil))
+	http.HandleFunc("/status", statusHandler)
+	http.Handle(frrstate.Path, frrstate.Handler(frrstate.Vtysh))
+	if metricsInterval > 0 {
+		exporter := frrmetrics.NewExporter(frrstate.Vtysh)
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporter)
+		go exporter.Run(context.Background(), metricsInterval)
+		metrics := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+		if metricsBindAddress == "" {
+			http.Handle("/metrics", metrics)
+		} else {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics)
+			slog.Info("serving metrics", "address", metricsBindAddress)
+			go func() {
+				log.Fatal(http.ListenAndServe(metricsBindAddress, mux))
+			}()
+		}
+	}
+	listener, err := reloaderconn.Listen(bindAddress, serverOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("listening", "address", listener.Addr().String(), "mtls", serverOpts.TLS.CertFile != "")
+	log.Fatal(http.Serve(listener, nil))
 }
 
-var updateConfig = frrconfig.Update
-
+// reloadHandler applies the configuration received in a frrconfig.ReloadRequest,
+// replying with a frrconfig.ReloadResult.
 func reloadHandler(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodPost {
 		http.Error(w, "invalid method", http.StatusBadRequest)
 		return
 	}
-	slog.Info("reload handler", "event", "received request")
-	err := updateConfig(frrConfigPath)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var request frrconfig.ReloadRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if request.Version != frrconfig.ConfigVersion(request.Config) {
+		http.Error(w, fmt.Sprintf("version %s does not match the config", request.Version), http.StatusBadRequest)
 		return
 	}
+	slog.Info("reload handler", "event", "received request", "version", request.Version)
+
+	result := reloads.Submit(req.Context(), request)
+	if !result.Success {
+		slog.Error("reload handler", "event", "config not applied", "version", request.Version, "error", result.Error, "rollback", result.Rollback)
+		writeResult(w, http.StatusInternalServerError, result)
+		return
+	}
+	slog.Info("reload handler", "event", "config applied", "version", request.Version)
+	writeResult(w, http.StatusOK, result)
+}
+
+// statusHandler serves the frrconfig.Status of the reloads.
+func statusHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "invalid method", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reloads.Status()); err != nil {
+		slog.Error("failed to write the reload status", "error", err)
+	}
+}
+
+func writeResult(w http.ResponseWriter, status int, result frrconfig.ReloadResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.Error("failed to write the reload result", "error", err)
+	}
 }
@@ -20,17 +20,27 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"reflect"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/openperouter/openperouter/api/v1alpha1"
 	periov1alpha1 "github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/conversion"
+	"github.com/openperouter/openperouter/internal/hostnetwork"
+	"github.com/openperouter/openperouter/internal/metrics"
+	"github.com/openperouter/openperouter/internal/nodeindex"
 	"github.com/openperouter/openperouter/internal/pods"
+	"github.com/openperouter/openperouter/internal/reloaderconn"
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -40,21 +50,41 @@ type PERouterReconciler struct {
 	Scheme      *runtime.Scheme
 	MyNode      string
 	MyNamespace string
-	FRRConfig   string
 	ReloadPort  int
 	PodRuntime  *pods.Runtime
 	LogLevel    string
 	Logger      *slog.Logger
+	StateDir    string
+	// Recorder emits the events reporting the failures, if set
+	Recorder record.EventRecorder
+	// ReloaderClient sends the requests to the reloader of the router pod
+	ReloaderClient *reloaderconn.Client
+	// RouterStateInterval is how often the routing state of the node is
+	// recorded in its RouterState. If zero, it is not recorded.
+	RouterStateInterval time.Duration
+	// sandbox is the router sandbox the host was last configured against
+	sandbox routerSandbox
+	// frrApplied is the FRR configuration last applied successfully
+	frrApplied appliedFRRConfig
+	netlink    *netlinkSource
 }
 
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
-// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=vnis,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=vnis/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=vnis/finalizers,verbs=update
+// +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=l2vnis,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=l2vnis/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=l2vnis/finalizers,verbs=update
 // +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=underlays,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=underlays/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=underlays/finalizers,verbs=update
+// +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=routerstates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=per.io.openperouter.github.io,resources=routerstates/status,verbs=get;update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -66,13 +96,25 @@ type PERouterReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.1/pkg/reconcile
 func (r *PERouterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	start := time.Now()
+	res, err := r.reconcile(ctx, req)
+	metrics.ObserveReconcile(start, err)
+	return res, err
+}
+
+func (r *PERouterReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := r.Logger.With("request", req.NamespacedName.String())
 	logger.Info("controller", "UnderlayReconciler", "start reconcile")
 	defer logger.Info("controller", "UnderlayReconciler", "end reconcile")
 
 	ctx = context.WithValue(ctx, "request", req.NamespacedName.String())
 
-	nodeIndex, err := nodeIndex(ctx, r.Client, r.MyNode)
+	var node v1.Node
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: r.MyNode}, &node); err != nil {
+		slog.Error("failed to get node", "node", r.MyNode, "error", err)
+		return ctrl.Result{}, err
+	}
+	nodeIndex, err := nodeindex.ForNode(ctx, r.Client, r.MyNamespace, &node)
 	if err != nil {
 		slog.Error("failed to fetch node index", "node", r.MyNode, "error", err)
 		return ctrl.Result{}, err
@@ -80,12 +122,18 @@ func (r *PERouterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	routerPod, err := routerPodForNode(ctx, r.Client, r.MyNode)
 	if err != nil {
 		slog.Error("failed to fetch router pod", "node", r.MyNode, "error", err)
+		r.netlink.stopWatchingRouter()
+		r.releaseDeletedObjects(ctx)
 		return ctrl.Result{}, err
 	}
 	routerPodIsReady := PodIsReady(routerPod)
 	logger.Info("router pod", "Pod", routerPod.Name, "is ready", routerPodIsReady)
 
 	if !routerPodIsReady {
+		// the namespace of the router may be going away, and watching
+		// it would keep it alive
+		r.netlink.stopWatchingRouter()
+		r.releaseDeletedObjects(ctx)
 		return ctrl.Result{}, nil
 	}
 
@@ -100,35 +148,247 @@ func (r *PERouterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		slog.Error("failed to list vnis", "error", err)
 		return ctrl.Result{}, err
 	}
-	logger.Debug("using config", "vnis", vnis.Items, "underlays", underlays.Items)
+	var l2vnis v1alpha1.L2VNIList
+	if err := r.Client.List(ctx, &l2vnis); err != nil {
+		slog.Error("failed to list l2vnis", "error", err)
+		return ctrl.Result{}, err
+	}
+	var nodes v1.NodeList
+	if err := r.Client.List(ctx, &nodes); err != nil {
+		slog.Error("failed to list nodes", "error", err)
+		return ctrl.Result{}, err
+	}
+	var secrets v1.SecretList
+	if err := r.Client.List(ctx, &secrets, client.InNamespace(r.MyNamespace)); err != nil {
+		slog.Error("failed to list secrets", "error", err)
+		return ctrl.Result{}, err
+	}
+	passwordSecrets := map[string]v1.Secret{}
+	for _, s := range secrets.Items {
+		passwordSecrets[s.Name] = s
+	}
 
-	if err := reloadFRRConfig(ctx, frrConfigData{
-		configFile: r.FRRConfig,
-		address:    routerPod.Status.PodIP,
-		port:       r.ReloadPort,
-		nodeIndex:  nodeIndex,
-		underlays:  underlays.Items,
-		logLevel:   r.LogLevel,
-		vnis:       vnis.Items,
-	}); err != nil {
-		slog.Error("failed to reload frr config", "error", err)
+	activeUnderlays := []v1alpha1.Underlay{}
+	for _, u := range underlays.Items {
+		if u.DeletionTimestamp == nil {
+			activeUnderlays = append(activeUnderlays, u)
+		}
+	}
+	nodeUnderlays, err := conversion.UnderlaysForNode(&node, activeUnderlays)
+	if err != nil {
+		slog.Error("failed to select underlays for node", "node", r.MyNode, "error", err)
+		r.reportFailure(&node, conversionFailed(err, apiObjects(activeUnderlays, nil, nil)))
 		return ctrl.Result{}, err
 	}
+	// the resources being deleted are not configured anymore, and the
+	// finalizer of the node is released once they are torn down
+	activeVNIs := []v1alpha1.VNI{}
+	for _, v := range vnis.Items {
+		if v.DeletionTimestamp == nil {
+			activeVNIs = append(activeVNIs, v)
+		}
+	}
+	activeL2VNIs := []v1alpha1.L2VNI{}
+	for _, v := range l2vnis.Items {
+		if v.DeletionTimestamp == nil {
+			activeL2VNIs = append(activeL2VNIs, v)
+		}
+	}
 
-	if err := configureInterfaces(ctx, interfacesConfiguration{
-		RouterPodUUID: string(routerPod.UID),
-		PodRuntime:    *r.PodRuntime,
-		NodeIndex:     nodeIndex,
-		Underlays:     underlays.Items,
-		Vnis:          vnis.Items,
-	}); err != nil {
-		slog.Error("failed to configure the host", "error", err)
+	nodeVNIs, err := conversion.VNIsForNode(&node, activeVNIs)
+	if err != nil {
+		slog.Error("failed to select vnis for node", "node", r.MyNode, "error", err)
+		r.reportFailure(&node, conversionFailed(err, apiObjects(nil, activeVNIs, nil)))
+		return ctrl.Result{}, err
+	}
+
+	nodeL2VNIs, err := conversion.L2VNIsForNode(&node, activeL2VNIs)
+	if err != nil {
+		slog.Error("failed to select l2vnis for node", "node", r.MyNode, "error", err)
+		r.reportFailure(&node, conversionFailed(err, apiObjects(nil, nil, activeL2VNIs)))
+		return ctrl.Result{}, err
+	}
+
+	logger.Debug("using config", "vnis", nodeVNIs, "l2vnis", nodeL2VNIs, "underlays", nodeUnderlays)
+	indexesCount, err := nodeindex.Allocated(ctx, r.Client, r.MyNamespace)
+	if err != nil {
+		slog.Error("failed to count the node indexes", "error", err)
+		return ctrl.Result{}, err
+	}
+	updateGauges(nodeIndex, indexesCount, nodeUnderlays, nodeVNIs, nodeL2VNIs)
+
+	for i := range nodeUnderlays {
+		if err := addNodeFinalizer(ctx, r.Client, r.MyNode, &nodeUnderlays[i]); err != nil {
+			slog.Error("failed to add finalizer", "underlay", nodeUnderlays[i].Name, "error", err)
+			return ctrl.Result{}, err
+		}
+	}
+	for i := range nodeVNIs {
+		if err := addNodeFinalizer(ctx, r.Client, r.MyNode, &nodeVNIs[i]); err != nil {
+			slog.Error("failed to add finalizer", "vni", nodeVNIs[i].Name, "error", err)
+			return ctrl.Result{}, err
+		}
+	}
+	for i := range nodeL2VNIs {
+		if err := addNodeFinalizer(ctx, r.Client, r.MyNode, &nodeL2VNIs[i]); err != nil {
+			slog.Error("failed to add finalizer", "l2vni", nodeL2VNIs[i].Name, "error", err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	notPreserved, configErr := r.configure(ctx, routerPod, nodeIndex, nodeUnderlays, nodeVNIs, nodeL2VNIs, passwordSecrets)
+	r.reportNotPreserved(&node, nodeUnderlays, notPreserved)
+	if configErr != nil {
+		r.reportFailure(&node, configErr)
+	}
+
+	statusErr := updateVNIsStatus(ctx, r.Client, vniStatusData{
+		node:      r.MyNode,
+		nodeIndex: nodeIndex,
+		underlays: nodeUnderlays,
+		vnis:      nodeVNIs,
+		err:       configErr,
+	})
+	if statusErr != nil {
+		slog.Error("failed to update vni status", "error", statusErr)
+	} else if statusErr = clearVNIsStatus(ctx, r.Client, r.MyNode, vnisNotIn(activeVNIs, nodeVNIs)); statusErr != nil {
+		slog.Error("failed to clear vni status", "error", statusErr)
+	}
+
+	notConfigured := notConfiguredObjects(underlays.Items, nodeUnderlays, vnis.Items, nodeVNIs, l2vnis.Items, nodeL2VNIs)
+	if configErr != nil {
+		// the objects being deleted are released even if the node failed
+		// to tear them down, otherwise a broken node would keep them forever
+		notConfigured = deletedObjects(notConfigured)
+	}
+	if err := r.releaseFinalizers(ctx, nodes.Items, notConfigured); err != nil {
+		slog.Error("failed to release finalizers", "error", err)
 		return ctrl.Result{}, err
 	}
 
+	if configErr != nil {
+		return ctrl.Result{}, configErr
+	}
+	if statusErr != nil {
+		return ctrl.Result{}, statusErr
+	}
 	return ctrl.Result{}, nil
 }
 
+// releaseDeletedObjects releases the finalizer of the current node from
+// the objects being deleted, when the node can't be configured. The
+// configuration of a missing router pod is gone with its namespace.
+func (r *PERouterReconciler) releaseDeletedObjects(ctx context.Context) {
+	if err := releaseDeletedFinalizers(ctx, r.Client, r.MyNode); err != nil {
+		slog.Error("failed to release the finalizers of the deleted objects", "node", r.MyNode, "error", err)
+	}
+}
+
+// releaseFinalizers removes the finalizer of the current node from the
+// given objects, as they are not configured on it anymore.
+func (r *PERouterReconciler) releaseFinalizers(ctx context.Context, nodes []v1.Node, objs []client.Object) error {
+	for _, o := range objs {
+		if err := releaseNodeFinalizers(ctx, r.Client, r.MyNode, nodes, o); err != nil {
+			return fmt.Errorf("failed to release finalizer of %s: %w", client.ObjectKeyFromObject(o), err)
+		}
+	}
+	return nil
+}
+
+// notConfiguredObjects returns the underlays, the vnis and the l2vnis
+// that are not among the ones configured on the node.
+func notConfiguredObjects(underlays, nodeUnderlays []v1alpha1.Underlay,
+	vnis, nodeVNIs []v1alpha1.VNI, l2vnis, nodeL2VNIs []v1alpha1.L2VNI) []client.Object {
+	res := []client.Object{}
+	configured := map[string]bool{}
+	for _, u := range nodeUnderlays {
+		configured[u.Name] = true
+	}
+	for i := range underlays {
+		if !configured[underlays[i].Name] {
+			res = append(res, &underlays[i])
+		}
+	}
+	toRelease := vnisNotIn(vnis, nodeVNIs)
+	for i := range toRelease {
+		res = append(res, &toRelease[i])
+	}
+	configured = map[string]bool{}
+	for _, v := range nodeL2VNIs {
+		configured[v.Name] = true
+	}
+	for i := range l2vnis {
+		if !configured[l2vnis[i].Name] {
+			res = append(res, &l2vnis[i])
+		}
+	}
+	return res
+}
+
+// configure applies the given configuration to FRR and to the network
+// namespace of the router pod. The settings of the underlay nics that
+// could not be preserved are returned.
+func (r *PERouterReconciler) configure(ctx context.Context, routerPod *v1.Pod, nodeIndex int,
+	underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI, l2vnis []v1alpha1.L2VNI,
+	passwordSecrets map[string]v1.Secret) ([]hostnetwork.NICSettings, error) {
+	instance := frrInstance(routerPod)
+	appliedHash := ""
+	if r.frrApplied.instance == instance {
+		appliedHash = r.frrApplied.hash
+	}
+	hash, err := reloadFRRConfig(ctx, frrConfigData{
+		appliedHash: appliedHash,
+		address:     routerPod.Status.PodIP,
+		port:        r.ReloadPort,
+		reloader:    r.ReloaderClient,
+		nodeIndex:   nodeIndex,
+		underlays:   underlays,
+		logLevel:    r.LogLevel,
+		vnis:        vnis,
+		l2vnis:      l2vnis,
+		secrets:     passwordSecrets,
+	})
+	if err != nil {
+		slog.Error("failed to reload frr config", "error", err)
+		// the router may be partially configured
+		r.frrApplied = appliedFRRConfig{}
+		return nil, err
+	}
+	r.frrApplied = appliedFRRConfig{instance: instance, hash: hash}
+	if err := setFRRConfigHashAnnotation(ctx, r.Client, routerPod, hash); err != nil {
+		// the annotation is informative only, the configuration goes on
+		slog.Error("failed to annotate the router pod", "error", err)
+	}
+
+	targetNS, err := r.PodRuntime.NetworkNamespace(ctx, string(routerPod.UID))
+	if err != nil {
+		slog.Error("failed to retrieve namespace for router pod", "pod", routerPod.Name, "error", err)
+		return nil, fmt.Errorf("failed to retrieve namespace for pod %s: %w", routerPod.UID, err)
+	}
+	sandbox := routerSandbox{podUID: string(routerPod.UID), namespace: targetNS}
+	sandboxChanged := sandbox != r.sandbox
+	if sandboxChanged {
+		slog.Info("router sandbox changed, rebuilding the dataplane", "old", r.sandbox, "new", sandbox)
+	}
+
+	notPreserved, err := configureInterfaces(ctx, interfacesConfiguration{
+		TargetNS:       targetNS,
+		NodeIndex:      nodeIndex,
+		Underlays:      underlays,
+		Vnis:           vnis,
+		L2Vnis:         l2vnis,
+		StateDir:       r.StateDir,
+		SandboxChanged: sandboxChanged,
+	})
+	if err != nil {
+		slog.Error("failed to configure the host", "error", err)
+		return notPreserved, err
+	}
+	r.sandbox = sandbox
+	r.netlink.watchRouter(targetNS)
+	return notPreserved, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *PERouterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	filterNonRouterPods := predicate.NewPredicateFuncs(func(object client.Object) bool {
@@ -145,6 +405,8 @@ func (r *PERouterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				return true
 			}
 			return false
+		case *v1.Secret: // interested only in the secrets the passwords are read from
+			return o.Namespace == r.MyNamespace
 		default:
 			return true
 		}
@@ -154,8 +416,18 @@ func (r *PERouterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	filterUpdates := predicate.Funcs{
 		UpdateFunc: func(e event.UpdateEvent) bool {
 			switch o := e.ObjectNew.(type) {
-			case *v1.Node:
-				return false
+			case *v1.Node: // the underlays and vnis applied depend on the labels of the node
+				if o.Name != r.MyNode {
+					return false
+				}
+				old := e.ObjectOld.(*v1.Node)
+				return !reflect.DeepEqual(old.Labels, o.Labels)
+			case *periov1alpha1.VNI: // status updates are written by the controllers themselves
+				old := e.ObjectOld.(*periov1alpha1.VNI)
+				return old.Generation != o.Generation || old.DeletionTimestamp.IsZero() != o.DeletionTimestamp.IsZero()
+			case *periov1alpha1.L2VNI:
+				old := e.ObjectOld.(*periov1alpha1.L2VNI)
+				return old.Generation != o.Generation || old.DeletionTimestamp.IsZero() != o.DeletionTimestamp.IsZero()
 			case *v1.Pod: // handle only status updates
 				old := e.ObjectOld.(*v1.Pod)
 				if PodIsReady(old) != PodIsReady(o) {
@@ -170,10 +442,45 @@ func (r *PERouterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if err := setPodNodeNameIndex(mgr); err != nil {
 		return err
 	}
+	// the changes to the interfaces are not filtered by the predicates, as
+	// they are notified through a raw source
+	r.netlink = newNetlinkSource(r.MyNode)
+	if err := mgr.Add(r.netlink); err != nil {
+		return fmt.Errorf("failed to add the netlink source: %w", err)
+	}
+	// the objects deleted while the controller is shutting down, as when
+	// uninstalling, are released with a client not depending on the cache
+	shutdownClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		return fmt.Errorf("failed to create the shutdown client: %w", err)
+	}
+	if err := mgr.Add(&shutdownReleaser{
+		client:  shutdownClient,
+		node:    r.MyNode,
+		timeout: shutdownReleaseTimeout,
+	}); err != nil {
+		return fmt.Errorf("failed to add the shutdown releaser: %w", err)
+	}
+	if r.RouterStateInterval > 0 {
+		if err := mgr.Add(&routerStateReporter{
+			client:     mgr.GetClient(),
+			node:       r.MyNode,
+			reloadPort: r.ReloadPort,
+			reloader:   r.ReloaderClient,
+			podRuntime: r.PodRuntime,
+			interval:   r.RouterStateInterval,
+		}); err != nil {
+			return fmt.Errorf("failed to add the router state reporter: %w", err)
+		}
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&periov1alpha1.Underlay{}).
 		Watches(&v1.Pod{}, &handler.EnqueueRequestForObject{}).
 		Watches(&periov1alpha1.VNI{}, &handler.EnqueueRequestForObject{}).
+		Watches(&periov1alpha1.L2VNI{}, &handler.EnqueueRequestForObject{}).
+		Watches(&v1.Secret{}, &handler.EnqueueRequestForObject{}).
+		Watches(&v1.Node{}, &handler.EnqueueRequestForObject{}).
+		WatchesRawSource(source.Channel(r.netlink.events, &handler.EnqueueRequestForObject{})).
 		WithEventFilter(filterNonRouterPods).
 		WithEventFilter(filterUpdates).
 		Named("routercontroller").
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/hostnetwork"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reconcileError is a failure in configuring the node, together with
+// the reason it is reported with and the resources that caused it.
+type reconcileError struct {
+	reason  string
+	objects []client.Object
+	err     error
+}
+
+func (e reconcileError) Error() string {
+	return e.err.Error()
+}
+
+func (e reconcileError) Unwrap() error {
+	return e.err
+}
+
+func conversionFailed(err error, objects []client.Object) error {
+	return reconcileError{reason: v1alpha1.ConversionFailedReason, objects: objects, err: err}
+}
+
+func frrReloadFailed(err error, objects []client.Object) error {
+	return reconcileError{reason: v1alpha1.FRRReloadFailedReason, objects: objects, err: err}
+}
+
+func hostNetworkFailed(err error, objects ...client.Object) error {
+	return reconcileError{reason: v1alpha1.HostNetworkFailedReason, objects: objects, err: err}
+}
+
+// failureReason returns the reason the given error is reported with,
+// or false if it is not a reconcileError.
+func failureReason(err error) (string, bool) {
+	var reconcileErr reconcileError
+	if !errors.As(err, &reconcileErr) {
+		return "", false
+	}
+	return reconcileErr.reason, true
+}
+
+// reportFailure emits a warning event for the given error on the node and on
+// the resources that caused it. Only reconcileErrors are reported.
+func (r *PERouterReconciler) reportFailure(node *v1.Node, err error) {
+	var reconcileErr reconcileError
+	if r.Recorder == nil || !errors.As(err, &reconcileErr) {
+		return
+	}
+	r.Recorder.Event(node, v1.EventTypeWarning, reconcileErr.reason, err.Error())
+	for _, o := range reconcileErr.objects {
+		r.Recorder.Eventf(o, v1.EventTypeWarning, reconcileErr.reason, "node %s: %s", node.Name, err.Error())
+	}
+}
+
+// reportNotPreserved emits a warning event on the node and on the underlays
+// for each nic whose settings were not preserved moving it to the router
+// namespace or back to the host.
+func (r *PERouterReconciler) reportNotPreserved(node *v1.Node, underlays []v1alpha1.Underlay, notPreserved []hostnetwork.NICSettings) {
+	if r.Recorder == nil {
+		return
+	}
+	for _, n := range notPreserved {
+		msg := fmt.Sprintf("settings of nic %s not preserved: %s", n.Nic, strings.Join(n.Settings, ", "))
+		r.Recorder.Event(node, v1.EventTypeWarning, v1alpha1.NICSettingsNotPreservedReason, msg)
+		for i := range underlays {
+			r.Recorder.Eventf(&underlays[i], v1.EventTypeWarning, v1alpha1.NICSettingsNotPreservedReason, "node %s: %s", node.Name, msg)
+		}
+	}
+}
+
+// apiObjects returns the given resources as a list of objects.
+func apiObjects(underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI, l2vnis []v1alpha1.L2VNI) []client.Object {
+	res := []client.Object{}
+	for i := range underlays {
+		res = append(res, &underlays[i])
+	}
+	for i := range vnis {
+		res = append(res, &vnis[i])
+	}
+	for i := range l2vnis {
+		res = append(res, &l2vnis[i])
+	}
+	return res
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/hostnetwork"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReportFailure(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	vnis := []v1alpha1.VNI{
+		{ObjectMeta: metav1.ObjectMeta{Name: "red", Namespace: testNamespace}},
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedEvents []string
+	}{
+		{
+			name: "host network failure on a vni",
+			err:  hostNetworkFailed(errors.New("failed to setup vni"), &vnis[0]),
+			expectedEvents: []string{
+				"Warning HostNetworkFailed failed to setup vni",
+				"Warning HostNetworkFailed node node1: failed to setup vni",
+			},
+		},
+		{
+			name: "wrapped conversion failure",
+			err:  fmt.Errorf("wrapped: %w", conversionFailed(errors.New("invalid vni"), apiObjects(nil, vnis, nil))),
+			expectedEvents: []string{
+				"Warning ConversionFailed node node1: wrapped: invalid vni",
+				"Warning ConversionFailed wrapped: invalid vni",
+			},
+		},
+		{
+			name:           "not a reconcile error",
+			err:            errors.New("failed to list"),
+			expectedEvents: []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &PERouterReconciler{Recorder: recorder}
+			r.reportFailure(node, tc.err)
+			close(recorder.Events)
+			events := []string{}
+			for e := range recorder.Events {
+				events = append(events, e)
+			}
+			sort.Strings(events)
+			if strings.Join(events, "\n") != strings.Join(tc.expectedEvents, "\n") {
+				t.Fatalf("expecting events %v, got %v", tc.expectedEvents, events)
+			}
+		})
+	}
+}
+
+func TestReportNotPreserved(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	underlays := []v1alpha1.Underlay{
+		{ObjectMeta: metav1.ObjectMeta{Name: "underlay", Namespace: testNamespace}},
+	}
+
+	tests := []struct {
+		name           string
+		underlays      []v1alpha1.Underlay
+		notPreserved   []hostnetwork.NICSettings
+		expectedEvents []string
+	}{
+		{
+			name:      "settings not preserved moving the nic",
+			underlays: underlays,
+			notPreserved: []hostnetwork.NICSettings{
+				{Nic: "eth1", Settings: []string{"mtu 9000: invalid argument", "sysctl net/ipv6/conf/eth1/forwarding=1: permission denied"}},
+			},
+			expectedEvents: []string{
+				"Warning NICSettingsNotPreserved node node1: settings of nic eth1 not preserved: mtu 9000: invalid argument, sysctl net/ipv6/conf/eth1/forwarding=1: permission denied",
+				"Warning NICSettingsNotPreserved settings of nic eth1 not preserved: mtu 9000: invalid argument, sysctl net/ipv6/conf/eth1/forwarding=1: permission denied",
+			},
+		},
+		{
+			name: "settings not restored with no underlay",
+			notPreserved: []hostnetwork.NICSettings{
+				{Nic: "eth1", Settings: []string{"mtu 9000: invalid argument"}},
+			},
+			expectedEvents: []string{
+				"Warning NICSettingsNotPreserved settings of nic eth1 not preserved: mtu 9000: invalid argument",
+			},
+		},
+		{
+			name:           "all the settings preserved",
+			underlays:      underlays,
+			expectedEvents: []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &PERouterReconciler{Recorder: recorder}
+			r.reportNotPreserved(node, tc.underlays, tc.notPreserved)
+			close(recorder.Events)
+			events := []string{}
+			for e := range recorder.Events {
+				events = append(events, e)
+			}
+			sort.Strings(events)
+			if strings.Join(events, "\n") != strings.Join(tc.expectedEvents, "\n") {
+				t.Fatalf("expecting events %v, got %v", tc.expectedEvents, events)
+			}
+		})
+	}
+}
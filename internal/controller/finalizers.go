@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// nodeFinalizerPrefix is the prefix of the finalizers the controllers set on
+// the resources they configure, one per node, so that a resource is removed
+// only after all the nodes tore down the configuration related to it.
+const nodeFinalizerPrefix = "openperouter.io/node-"
+
+// shutdownReleaseTimeout is how long the controller waits for the
+// finalizers to be released when shutting down.
+const shutdownReleaseTimeout = 10 * time.Second
+
+// maxFinalizerNameLen is the maximum length of the name part of a finalizer,
+// which must be a qualified name.
+const maxFinalizerNameLen = 63
+
+// nodeFinalizer returns the finalizer of the given node. The name
+// of the node is hashed when it does not fit in a finalizer.
+func nodeFinalizer(node string) string {
+	const namePrefix = "node-"
+	name := node
+	if len(namePrefix+name) > maxFinalizerNameLen {
+		name = fmt.Sprintf("%x", sha256.Sum256([]byte(node)))[:32]
+	}
+	return nodeFinalizerPrefix + name
+}
+
+// addNodeFinalizer sets the finalizer of the given node on the given object.
+func addNodeFinalizer(ctx context.Context, cli client.Client, node string, obj client.Object) error {
+	finalizer := nodeFinalizer(node)
+	if controllerutil.ContainsFinalizer(obj, finalizer) {
+		return nil
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		toUpdate := obj.DeepCopyObject().(client.Object)
+		if err := cli.Get(ctx, client.ObjectKeyFromObject(obj), toUpdate); err != nil {
+			return err
+		}
+		if !controllerutil.AddFinalizer(toUpdate, finalizer) {
+			return nil
+		}
+		return cli.Update(ctx, toUpdate)
+	})
+}
+
+// releaseNodeFinalizers removes from the given object the finalizer of the given
+// node. If the object is being deleted, the finalizers of the nodes not
+// existing anymore are removed too, as nobody else would remove them.
+func releaseNodeFinalizers(ctx context.Context, cli client.Client, node string, nodes []v1.Node, obj client.Object) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		toUpdate := obj.DeepCopyObject().(client.Object)
+		if err := cli.Get(ctx, client.ObjectKeyFromObject(obj), toUpdate); err != nil {
+			return err
+		}
+		toRelease := finalizersToRelease(toUpdate, node, nodes)
+		if len(toRelease) == 0 {
+			return nil
+		}
+		for _, f := range toRelease {
+			controllerutil.RemoveFinalizer(toUpdate, f)
+		}
+		return cli.Update(ctx, toUpdate)
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// finalizersToRelease returns the finalizers of the given object the
+// given node is in charge of removing.
+func finalizersToRelease(obj client.Object, node string, nodes []v1.Node) []string {
+	existing := map[string]bool{}
+	for _, n := range nodes {
+		existing[nodeFinalizer(n.Name)] = true
+	}
+	res := []string{}
+	for _, f := range obj.GetFinalizers() {
+		if f == nodeFinalizer(node) {
+			res = append(res, f)
+			continue
+		}
+		if obj.GetDeletionTimestamp() != nil &&
+			strings.HasPrefix(f, nodeFinalizerPrefix) && !existing[f] {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// deletedObjects returns the given objects that are being deleted.
+func deletedObjects(objs []client.Object) []client.Object {
+	res := []client.Object{}
+	for _, o := range objs {
+		if o.GetDeletionTimestamp() != nil {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+// releaseDeletedFinalizers removes the finalizer of the given node from
+// the underlays, the vnis and the l2vnis being deleted.
+func releaseDeletedFinalizers(ctx context.Context, cli client.Client, node string) error {
+	var underlays v1alpha1.UnderlayList
+	if err := cli.List(ctx, &underlays); err != nil {
+		return fmt.Errorf("failed to list underlays: %w", err)
+	}
+	var vnis v1alpha1.VNIList
+	if err := cli.List(ctx, &vnis); err != nil {
+		return fmt.Errorf("failed to list vnis: %w", err)
+	}
+	var l2vnis v1alpha1.L2VNIList
+	if err := cli.List(ctx, &l2vnis); err != nil {
+		return fmt.Errorf("failed to list l2vnis: %w", err)
+	}
+	var nodes v1.NodeList
+	if err := cli.List(ctx, &nodes); err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	objs := notConfiguredObjects(underlays.Items, nil, vnis.Items, nil, l2vnis.Items, nil)
+	for _, o := range deletedObjects(objs) {
+		if err := releaseNodeFinalizers(ctx, cli, node, nodes.Items, o); err != nil {
+			return fmt.Errorf("failed to release finalizer of %s: %w", client.ObjectKeyFromObject(o), err)
+		}
+	}
+	return nil
+}
+
+// shutdownReleaser releases the finalizers of the current node from the
+// objects being deleted when the controller stops, so that uninstalling
+// does not leave them stuck with nobody in charge of removing them.
+type shutdownReleaser struct {
+	client  client.Client
+	node    string
+	timeout time.Duration
+}
+
+// Start waits for the controller to stop and then releases the finalizers.
+func (s *shutdownReleaser) Start(ctx context.Context) error {
+	<-ctx.Done()
+	releaseCtx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	slog.Info("releasing the finalizers of the deleted objects on shutdown", "node", s.node)
+	if err := releaseDeletedFinalizers(releaseCtx, s.client, s.node); err != nil {
+		slog.Error("failed to release the finalizers on shutdown", "node", s.node, "error", err)
+	}
+	return nil
+}
+
+func (s *shutdownReleaser) NeedLeaderElection() bool {
+	return false
+}
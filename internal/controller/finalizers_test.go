@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/reloaderconn"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNamespace = "openperouter-system"
+
+func TestNodeFinalizer(t *testing.T) {
+	if f := nodeFinalizer("node1"); f != "openperouter.io/node-node1" {
+		t.Fatalf("unexpected finalizer %s", f)
+	}
+	long := strings.Repeat("a", 100)
+	f := nodeFinalizer(long)
+	name := strings.TrimPrefix(f, "openperouter.io/")
+	if len(name) > maxFinalizerNameLen {
+		t.Fatalf("finalizer name %s exceeds %d characters", name, maxFinalizerNameLen)
+	}
+	if f != nodeFinalizer(long) {
+		t.Fatalf("finalizer for the same node is not stable")
+	}
+}
+
+func TestNodeFinalizers(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	ctx := context.Background()
+	nodes := []v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "node0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+	}
+	vni := &v1alpha1.VNI{ObjectMeta: metav1.ObjectMeta{Name: "red", Namespace: testNamespace}}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vni).Build()
+
+	expectFinalizers := func(expected ...string) {
+		t.Helper()
+		var current v1alpha1.VNI
+		if err := cli.Get(ctx, client.ObjectKeyFromObject(vni), &current); err != nil {
+			t.Fatalf("failed to get vni: %v", err)
+		}
+		if len(expected) == 0 && len(current.Finalizers) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(current.Finalizers, expected) {
+			t.Fatalf("expecting finalizers %v, got %v", expected, current.Finalizers)
+		}
+	}
+
+	for _, n := range []string{"node0", "node1", "node2", "node0"} {
+		if err := addNodeFinalizer(ctx, cli, n, vni); err != nil {
+			t.Fatalf("failed to add finalizer for %s: %v", n, err)
+		}
+	}
+	expectFinalizers(nodeFinalizer("node0"), nodeFinalizer("node1"), nodeFinalizer("node2"))
+
+	// node2 does not exist, but the vni is not being deleted
+	if err := releaseNodeFinalizers(ctx, cli, "node0", nodes, vni); err != nil {
+		t.Fatalf("failed to release finalizers: %v", err)
+	}
+	expectFinalizers(nodeFinalizer("node1"), nodeFinalizer("node2"))
+
+	if err := cli.Delete(ctx, vni); err != nil {
+		t.Fatalf("failed to delete vni: %v", err)
+	}
+	if err := cli.Get(ctx, client.ObjectKeyFromObject(vni), vni); err != nil {
+		t.Fatalf("failed to get vni: %v", err)
+	}
+	// the finalizer of node2 is released by node1 too, as node2 does not exist
+	if err := releaseNodeFinalizers(ctx, cli, "node1", nodes, vni); err != nil {
+		t.Fatalf("failed to release finalizers: %v", err)
+	}
+	err := cli.Get(ctx, client.ObjectKeyFromObject(vni), vni)
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expecting vni to be deleted, got %v", err)
+	}
+	// releasing the finalizers of a deleted object is not an error
+	if err := releaseNodeFinalizers(ctx, cli, "node1", nodes, vni); err != nil {
+		t.Fatalf("failed to release finalizers: %v", err)
+	}
+}
+
+func TestReconcileReleasesDeletedObjects(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	failingReloader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "reload failed", http.StatusInternalServerError)
+	}))
+	defer failingReloader.Close()
+	host, portString, err := net.SplitHostPort(failingReloader.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to parse the server address: %v", err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		t.Fatalf("failed to parse the server port: %v", err)
+	}
+	reloader, err := reloaderconn.NewClient(reloaderconn.ClientOptions{})
+	if err != nil {
+		t.Fatalf("failed to create the reloader client: %v", err)
+	}
+
+	routerPod := func(ready bool) *v1.Pod {
+		status := v1.ConditionFalse
+		if ready {
+			status = v1.ConditionTrue
+		}
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "router", Namespace: testNamespace, Labels: map[string]string{"app": "router"}},
+			Spec:       v1.PodSpec{NodeName: "node1"},
+			Status: v1.PodStatus{
+				PodIP: host,
+				Conditions: []v1.PodCondition{
+					{Type: v1.PodReady, Status: status},
+					{Type: v1.ContainersReady, Status: status},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		routerPod *v1.Pod
+	}{
+		{
+			name: "router pod missing",
+		},
+		{
+			name:      "router pod not ready",
+			routerPod: routerPod(false),
+		},
+		{
+			name:      "configure failed",
+			routerPod: routerPod(true),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := metav1.Now()
+			finalizers := []string{nodeFinalizer("node1"), nodeFinalizer("node2")}
+			deleted := &v1alpha1.VNI{ObjectMeta: metav1.ObjectMeta{Name: "red", Namespace: testNamespace,
+				Finalizers: finalizers, DeletionTimestamp: &now}}
+			active := &v1alpha1.L2VNI{ObjectMeta: metav1.ObjectMeta{Name: "blue", Namespace: testNamespace,
+				Finalizers: finalizers}}
+			objs := []client.Object{
+				&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", UID: "node1-uid"}},
+				&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2", UID: "node2-uid"}},
+				deleted, active,
+			}
+			if tc.routerPod != nil {
+				objs = append(objs, tc.routerPod)
+			}
+			cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+				WithIndex(&v1.Pod{}, "spec.NodeName", func(o client.Object) []string {
+					return []string{o.(*v1.Pod).Spec.NodeName}
+				}).Build()
+			r := &PERouterReconciler{
+				Client:         cli,
+				MyNode:         "node1",
+				MyNamespace:    testNamespace,
+				ReloadPort:     port,
+				ReloaderClient: reloader,
+				Logger:         slog.Default(),
+				netlink:        newNetlinkSource("node1"),
+			}
+			_, _ = r.reconcile(ctx, ctrl.Request{})
+
+			var vni v1alpha1.VNI
+			if err := cli.Get(ctx, client.ObjectKeyFromObject(deleted), &vni); err != nil {
+				t.Fatalf("failed to get vni: %v", err)
+			}
+			if !reflect.DeepEqual(vni.Finalizers, []string{nodeFinalizer("node2")}) {
+				t.Fatalf("expecting the finalizer of node1 to be released from the deleted vni, got %v", vni.Finalizers)
+			}
+			var l2vni v1alpha1.L2VNI
+			if err := cli.Get(ctx, client.ObjectKeyFromObject(active), &l2vni); err != nil {
+				t.Fatalf("failed to get l2vni: %v", err)
+			}
+			if !reflect.DeepEqual(l2vni.Finalizers, finalizers) {
+				t.Fatalf("expecting the finalizers of the l2vni not being deleted to be kept, got %v", l2vni.Finalizers)
+			}
+		})
+	}
+}
+
+func TestShutdownReleaser(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	now := metav1.Now()
+	underlay := &v1alpha1.Underlay{ObjectMeta: metav1.ObjectMeta{Name: "underlay", Namespace: testNamespace,
+		Finalizers: []string{nodeFinalizer("node1")}, DeletionTimestamp: &now}}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(underlay,
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}).Build()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- (&shutdownReleaser{client: cli, node: "node1", timeout: time.Second}).Start(ctx)
+	}()
+	if err := cli.Get(context.Background(), client.ObjectKeyFromObject(underlay), &v1alpha1.Underlay{}); err != nil {
+		t.Fatalf("expecting the underlay to be kept while running, got %v", err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := cli.Get(context.Background(), client.ObjectKeyFromObject(underlay), &v1alpha1.Underlay{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expecting the underlay to be deleted on shutdown, got %v", err)
+	}
+}
@@ -4,35 +4,128 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/openperouter/openperouter/api/v1alpha1"
 	"github.com/openperouter/openperouter/internal/conversion"
 	"github.com/openperouter/openperouter/internal/frr"
 	"github.com/openperouter/openperouter/internal/frrconfig"
+	"github.com/openperouter/openperouter/internal/metrics"
+	"github.com/openperouter/openperouter/internal/reloaderconn"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// frrConfigHashAnnotation is set on the router pod with the hash of the
+// FRR configuration last applied to it.
+const frrConfigHashAnnotation = "openperouter.io/frr-config-hash"
+
 type frrConfigData struct {
-	configFile string
-	address    string
-	port       int
-	nodeIndex  int
-	logLevel   string
-	underlays  []v1alpha1.Underlay
-	vnis       []v1alpha1.VNI
+	address   string
+	port      int
+	reloader  *reloaderconn.Client
+	nodeIndex int
+	logLevel  string
+	underlays []v1alpha1.Underlay
+	vnis      []v1alpha1.VNI
+	l2vnis    []v1alpha1.L2VNI
+	secrets   map[string]v1.Secret
+
+	// appliedHash is the hash of the configuration last applied to
+	// the router, if any. The reload is skipped when it matches the
+	// one of the rendered configuration.
+	appliedHash string
 }
 
-func reloadFRRConfig(ctx context.Context, data frrConfigData) error {
+// LogValue implements slog.LogValuer, logging only the non sensitive
+// fields: the secrets hold the passwords of the BGP sessions.
+func (d frrConfigData) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("address", d.address),
+		slog.Int("port", d.port),
+		slog.Int("nodeIndex", d.nodeIndex),
+		slog.Any("underlays", objectNames(apiObjects(d.underlays, nil, nil))),
+		slog.Any("vnis", objectNames(apiObjects(nil, d.vnis, nil))),
+		slog.Any("l2vnis", objectNames(apiObjects(nil, nil, d.l2vnis))),
+		slog.String("appliedHash", d.appliedHash),
+	)
+}
+
+func objectNames(objects []client.Object) []string {
+	res := make([]string, 0, len(objects))
+	for _, o := range objects {
+		res = append(res, o.GetName())
+	}
+	return res
+}
+
+// reloadFRRConfig renders the FRR configuration and has the router reload it,
+// returning the hash of the configuration applied.
+func reloadFRRConfig(ctx context.Context, data frrConfigData) (string, error) {
 	slog.DebugContext(ctx, "reloading FRR config", "config", data)
-	frrConfig, err := conversion.APItoFRR(data.nodeIndex, data.underlays, data.vnis, data.logLevel)
+	start := time.Now()
+	frrConfig, err := conversion.APItoFRR(data.nodeIndex, data.underlays, data.vnis, data.l2vnis, data.logLevel, data.secrets)
 	if err != nil {
-		return fmt.Errorf("failed to generate the frr configuration: %w", err)
+		metrics.FRRConversionFailure()
+		return "", conversionFailed(fmt.Errorf("failed to generate the frr configuration: %w", err),
+			apiObjects(data.underlays, data.vnis, data.l2vnis))
 	}
 
 	url := fmt.Sprintf("%s:%d", data.address, data.port)
-	updater := frrconfig.UpdaterForAddress(url, data.configFile)
-	err = frr.ApplyConfig(ctx, &frrConfig, updater)
+	updater := frrconfig.UpdaterForAddress(url, data.reloader)
+	hash := ""
+	skipped := false
+	err = frr.ApplyConfig(ctx, &frrConfig, func(ctx context.Context, config string) error {
+		hash = frrconfig.ConfigVersion(config)
+		if hash == data.appliedHash {
+			skipped = true
+			return nil
+		}
+		return updater(ctx, config)
+	})
+	if skipped {
+		slog.DebugContext(ctx, "frr config unchanged, skipping the reload", "hash", hash)
+		metrics.FRRReloadSkipped()
+		return hash, nil
+	}
+	metrics.ObserveFRRReload(start, err)
 	if err != nil {
-		return fmt.Errorf("failed to update the frr configuration: %w", err)
+		return "", frrReloadFailed(fmt.Errorf("failed to update the frr configuration: %w", err),
+			apiObjects(data.underlays, data.vnis, data.l2vnis))
+	}
+	return hash, nil
+}
+
+// frrInstance identifies the FRR instance running in a router pod. It
+// changes when the pod is recreated and when its containers restart, as
+// FRR starts again from the startup configuration.
+func frrInstance(pod *v1.Pod) string {
+	restarts := int32(0)
+	for _, c := range pod.Status.ContainerStatuses {
+		restarts += c.RestartCount
+	}
+	return fmt.Sprintf("%s/%d", pod.UID, restarts)
+}
+
+// appliedFRRConfig is the hash of the configuration applied to
+// an FRR instance.
+type appliedFRRConfig struct {
+	instance string
+	hash     string
+}
+
+// setFRRConfigHashAnnotation records the given hash on the router pod.
+func setFRRConfigHashAnnotation(ctx context.Context, cli client.Client, pod *v1.Pod, hash string) error {
+	if pod.Annotations[frrConfigHashAnnotation] == hash {
+		return nil
+	}
+	patch := client.MergeFrom(pod.DeepCopy())
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[frrConfigHashAnnotation] = hash
+	if err := cli.Patch(ctx, pod, patch); err != nil {
+		return fmt.Errorf("failed to annotate pod %s with the frr config hash: %w", pod.Name, err)
 	}
 	return nil
 }
@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/frrconfig"
+	"github.com/openperouter/openperouter/internal/reloaderconn"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReloadFRRConfigSkipsUnchanged(t *testing.T) {
+	reloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reloads++
+		var request frrconfig.ReloadRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		_ = json.NewEncoder(w).Encode(frrconfig.ReloadResult{Version: request.Version, Success: true})
+	}))
+	defer server.Close()
+	host, portString, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to parse the server address: %v", err)
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		t.Fatalf("failed to parse the server port: %v", err)
+	}
+
+	client, err := reloaderconn.NewClient(reloaderconn.ClientOptions{})
+	if err != nil {
+		t.Fatalf("failed to create the reloader client: %v", err)
+	}
+
+	data := frrConfigData{
+		address:  host,
+		port:     port,
+		reloader: client,
+		logLevel: "debug",
+	}
+	hash, err := reloadFRRConfig(context.Background(), data)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if reloads != 1 {
+		t.Fatalf("expected 1 reload, got %d", reloads)
+	}
+
+	data.appliedHash = hash
+	sameHash, err := reloadFRRConfig(context.Background(), data)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if reloads != 1 {
+		t.Fatalf("expected the reload to be skipped, got %d reloads", reloads)
+	}
+	if sameHash != hash {
+		t.Fatalf("expected hash %s, got %s", hash, sameHash)
+	}
+
+	data.logLevel = "informational"
+	newHash, err := reloadFRRConfig(context.Background(), data)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if reloads != 2 {
+		t.Fatalf("expected 2 reloads, got %d", reloads)
+	}
+	if newHash == hash {
+		t.Fatalf("expected the hash to change with the configuration")
+	}
+}
+
+func TestFRRConfigDataLogValue(t *testing.T) {
+	data := frrConfigData{
+		address:   "10.0.0.1",
+		nodeIndex: 2,
+		underlays: []v1alpha1.Underlay{{ObjectMeta: metav1.ObjectMeta{Name: "underlay"}}},
+		vnis:      []v1alpha1.VNI{{ObjectMeta: metav1.ObjectMeta{Name: "red"}}},
+		secrets: map[string]v1.Secret{
+			"bgp-password": {Data: map[string][]byte{"password": []byte("topsecret")}},
+		},
+	}
+	var b bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger.Debug("reloading FRR config", "config", data)
+
+	logged := b.String()
+	if strings.Contains(logged, "topsecret") || strings.Contains(logged, "bgp-password") {
+		t.Fatalf("expected the secrets not to be logged, got %s", logged)
+	}
+	for _, expected := range []string{"10.0.0.1", `"underlay"`, `"red"`} {
+		if !strings.Contains(logged, expected) {
+			t.Fatalf("expected %s to be logged, got %s", expected, logged)
+		}
+	}
+}
+
+func TestFRRInstance(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{UID: "uid1"}}
+	instance := frrInstance(pod)
+
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "frr", RestartCount: 1}}
+	if frrInstance(pod) == instance {
+		t.Fatalf("expected the instance to change when a container restarts")
+	}
+	restarted := frrInstance(pod)
+	pod.UID = "uid2"
+	if frrInstance(pod) == restarted {
+		t.Fatalf("expected the instance to change when the pod is recreated")
+	}
+}
+
+func TestSetFRRConfigHashAnnotation(t *testing.T) {
+	ctx := context.Background()
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "router", Namespace: testNamespace}}
+	cli := fake.NewClientBuilder().WithObjects(pod).Build()
+
+	if err := setFRRConfigHashAnnotation(ctx, cli, pod.DeepCopy(), "abcd"); err != nil {
+		t.Fatalf("failed to set annotation: %v", err)
+	}
+	var current v1.Pod
+	if err := cli.Get(ctx, client.ObjectKeyFromObject(pod), &current); err != nil {
+		t.Fatalf("failed to get pod: %v", err)
+	}
+	if current.Annotations[frrConfigHashAnnotation] != "abcd" {
+		t.Fatalf("unexpected annotations %v", current.Annotations)
+	}
+}
@@ -8,38 +8,107 @@ import (
 	"github.com/openperouter/openperouter/api/v1alpha1"
 	"github.com/openperouter/openperouter/internal/conversion"
 	"github.com/openperouter/openperouter/internal/hostnetwork"
-	"github.com/openperouter/openperouter/internal/pods"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type interfacesConfiguration struct {
-	RouterPodUUID string `json:"routerPodUUID,omitempty"`
-	PodRuntime    pods.Runtime
-	NodeIndex     int                 `json:"nodeIndex,omitempty"`
-	Underlays     []v1alpha1.Underlay `json:"underlays,omitempty"`
-	Vnis          []v1alpha1.VNI      `json:"vnis,omitempty"`
+	TargetNS  string              `json:"targetNS,omitempty"`
+	NodeIndex int                 `json:"nodeIndex,omitempty"`
+	Underlays []v1alpha1.Underlay `json:"underlays,omitempty"`
+	Vnis      []v1alpha1.VNI      `json:"vnis,omitempty"`
+	L2Vnis    []v1alpha1.L2VNI    `json:"l2vnis,omitempty"`
+	StateDir  string              `json:"stateDir,omitempty"`
+	// SandboxChanged is set when the namespace of the router is not the
+	// one the host was last configured against, which means the leftovers
+	// of the old router must be cleaned up.
+	SandboxChanged bool `json:"sandboxChanged,omitempty"`
 }
 
-func configureInterfaces(ctx context.Context, config interfacesConfiguration) error {
-	targetNS, err := config.PodRuntime.NetworkNamespace(ctx, config.RouterPodUUID)
-	if err != nil {
-		return fmt.Errorf("failed to retrieve namespace for pod %s: %w", config.RouterPodUUID, err)
-	}
+// routerSandbox identifies the network namespace of a router pod.
+type routerSandbox struct {
+	podUID    string
+	namespace string
+}
 
+// configureInterfaces configures the host and the network namespace of the
+// router. The settings of the underlay nics that could not be preserved
+// are returned, also when failing.
+func configureInterfaces(ctx context.Context, config interfacesConfiguration) ([]hostnetwork.NICSettings, error) {
+	targetNS := config.TargetNS
 	slog.InfoContext(ctx, "configure interface start", "namespace", targetNS)
 	defer slog.InfoContext(ctx, "configure interface end", "namespace", targetNS)
-	underlayParams, vnis, err := conversion.APItoHostConfig(config.NodeIndex, targetNS, config.Underlays, config.Vnis)
+	underlayParams, vnis, l2vnis, err := conversion.APItoHostConfig(config.NodeIndex, targetNS, config.Underlays, config.Vnis, config.L2Vnis)
 	if err != nil {
-		return fmt.Errorf("failed to convert config to host configuration: %w", err)
+		return nil, conversionFailed(fmt.Errorf("failed to convert config to host configuration: %w", err),
+			apiObjects(config.Underlays, config.Vnis, config.L2Vnis))
 	}
 
-	slog.InfoContext(ctx, "setting up underlay")
-	if err := hostnetwork.SetupUnderlay(ctx, underlayParams); err != nil {
-		return fmt.Errorf("failed to setup underlay: %w", err)
+	underlayParams.StateDir = config.StateDir
+	if config.SandboxChanged {
+		// the veths whose pe leg was in the old namespace are recreated
+		slog.InfoContext(ctx, "router sandbox changed, removing orphaned veths")
+		if err := hostnetwork.RemoveOrphanedVeths(ctx, targetNS); err != nil {
+			return nil, hostNetworkFailed(fmt.Errorf("failed to remove orphaned veths: %w", err))
+		}
+	}
+	notPreserved := []hostnetwork.NICSettings{}
+	if len(config.Underlays) > 0 {
+		slog.InfoContext(ctx, "setting up underlay")
+		notPreserved, err = hostnetwork.SetupUnderlay(ctx, underlayParams)
+		if err != nil {
+			return notPreserved, hostNetworkFailed(fmt.Errorf("failed to setup underlay: %w", err),
+				apiObjects(config.Underlays, nil, nil)...)
+		}
 	}
 	for _, vni := range vnis {
 		slog.InfoContext(ctx, "setting up VNI", "vni", vni.VRF)
 		if err := hostnetwork.SetupVNI(ctx, vni); err != nil {
-			return fmt.Errorf("failed to setup vni: %w", err)
+			return notPreserved, hostNetworkFailed(fmt.Errorf("failed to setup vni: %w", err),
+				vniForVRF(config.Vnis, vni.VRF)...)
+		}
+	}
+	// the l2vnis are set up after the vnis, as they may be attached to their vrfs
+	for _, l2vni := range l2vnis {
+		slog.InfoContext(ctx, "setting up L2VNI", "vni", l2vni.VNI)
+		if err := hostnetwork.SetupL2VNI(ctx, l2vni); err != nil {
+			return notPreserved, hostNetworkFailed(fmt.Errorf("failed to setup l2vni: %w", err),
+				l2VNIForVNI(config.L2Vnis, l2vni.VNI)...)
+		}
+	}
+
+	// with no vnis configured, all of them are removed
+	slog.InfoContext(ctx, "removing non configured VNIs")
+	if err := hostnetwork.RemoveNonConfiguredVNIs(targetNS, vnis, l2vnis); err != nil {
+		return notPreserved, hostNetworkFailed(fmt.Errorf("failed to remove non configured vnis: %w", err))
+	}
+
+	if len(config.Underlays) == 0 {
+		slog.InfoContext(ctx, "removing underlay")
+		notPreserved, err = hostnetwork.RemoveUnderlay(ctx, targetNS, config.StateDir)
+		if err != nil {
+			return notPreserved, hostNetworkFailed(fmt.Errorf("failed to remove underlay: %w", err))
+		}
+	}
+	return notPreserved, nil
+}
+
+// vniForVRF returns the vni with the given vrf, as a list of objects
+// to report a failure on.
+func vniForVRF(vnis []v1alpha1.VNI, vrf string) []client.Object {
+	for i := range vnis {
+		if vnis[i].Spec.VRF == vrf {
+			return []client.Object{&vnis[i]}
+		}
+	}
+	return nil
+}
+
+// l2VNIForVNI returns the l2vni with the given vni, as a list of objects
+// to report a failure on.
+func l2VNIForVNI(l2vnis []v1alpha1.L2VNI, vni int) []client.Object {
+	for i := range l2vnis {
+		if int(l2vnis[i].Spec.VNI) == vni {
+			return []client.Object{&l2vnis[i]}
 		}
 	}
 	return nil
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"log/slog"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/ipam"
+	"github.com/openperouter/openperouter/internal/metrics"
+)
+
+// updateGauges reports the state of the configuration of the node.
+func updateGauges(nodeIndex, indexesCount int, underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI, l2vnis []v1alpha1.L2VNI) {
+	metrics.SetNodeIndex(nodeIndex)
+	metrics.SetConfiguredVNIs(len(vnis), len(l2vnis))
+	metrics.SetIPAMPoolsUtilization(poolsUtilization(indexesCount, underlays, vnis))
+}
+
+// poolsUtilization returns the fraction of each pool needed to assign the
+// addresses to the nodes, given the number of node indexes allocated.
+// The addresses are derived from the index of the node, so each allocated
+// index takes one vtep ip, while each local cidr needs one more address
+// for the router side.
+func poolsUtilization(indexesCount int, underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI) []metrics.PoolUtilization {
+	res := []metrics.PoolUtilization{}
+	add := func(pool, owner, cidr string, needed int) {
+		if cidr == "" {
+			return
+		}
+		available, err := ipam.IPsInCIDR(cidr)
+		if err != nil || available == 0 {
+			slog.Debug("failed to compute pool utilization", "cidr", cidr, "error", err)
+			return
+		}
+		res = append(res, metrics.PoolUtilization{
+			Pool:  pool,
+			Owner: owner,
+			CIDR:  cidr,
+			Ratio: float64(needed) / float64(available),
+		})
+	}
+	for _, u := range underlays {
+		add("vtep", u.Name, u.Spec.VTEPCIDR, indexesCount)
+	}
+	for _, v := range vnis {
+		add("localcidr", v.Name, v.Spec.LocalCIDR, indexesCount+1)
+		add("localcidr", v.Name, v.Spec.LocalCIDRV6, indexesCount+1)
+	}
+	return res
+}
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPoolsUtilization(t *testing.T) {
+	underlays := []v1alpha1.Underlay{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "underlay"},
+			Spec:       v1alpha1.UnderlaySpec{VTEPCIDR: "100.65.0.0/28"},
+		},
+	}
+	vnis := []v1alpha1.VNI{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "red"},
+			Spec: v1alpha1.VNISpec{
+				LocalCIDR: "192.169.10.0/29",
+			},
+		},
+	}
+
+	res := poolsUtilization(3, underlays, vnis)
+	expected := []metrics.PoolUtilization{
+		{Pool: "vtep", Owner: "underlay", CIDR: "100.65.0.0/28", Ratio: 3.0 / 16},
+		{Pool: "localcidr", Owner: "red", CIDR: "192.169.10.0/29", Ratio: 4.0 / 8},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expecting %v, got %v", expected, res)
+	}
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/openperouter/openperouter/internal/hostnetwork"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	// netlinkDebounce is how long the changes are accumulated before
+	// triggering a reconcile, so that a burst of changes (including the
+	// ones caused by the reconcile itself) results in a single one.
+	netlinkDebounce = time.Second
+	// netlinkRetryInterval is how long to wait before subscribing again
+	// after a subscription failed.
+	netlinkRetryInterval = 5 * time.Second
+)
+
+// netlinkSource watches the links, the addresses and the routes managed by
+// the controller, both in the host and in the router's namespace, and emits
+// an event for the node every time one of them changes, so that any
+// out of band change is reverted by a reconcile.
+type netlinkSource struct {
+	node   string
+	events chan event.GenericEvent
+	kick   chan struct{}
+
+	mu sync.Mutex
+	// ctx is the context the source was started with, nil until then.
+	ctx        context.Context
+	routerNS   string
+	stopRouter context.CancelFunc
+}
+
+func newNetlinkSource(node string) *netlinkSource {
+	return &netlinkSource{
+		node:   node,
+		events: make(chan event.GenericEvent),
+		kick:   make(chan struct{}, 1),
+	}
+}
+
+// Start watches the host namespace and the router one, if set, until the
+// context is done. It implements manager.Runnable.
+func (s *netlinkSource) Start(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	if s.routerNS != "" {
+		s.startWatchingRouter()
+	}
+	s.mu.Unlock()
+
+	go s.watch(ctx, "host", func(ctx context.Context) error {
+		return hostnetwork.WatchHost(ctx, s.notify)
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.kick:
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(netlinkDebounce):
+		}
+		// the changes notified while waiting are covered by this event
+		select {
+		case <-s.kick:
+		default:
+		}
+		node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: s.node}}
+		select {
+		case <-ctx.Done():
+			return nil
+		case s.events <- event.GenericEvent{Object: node}:
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, as each
+// node watches its own interfaces.
+func (s *netlinkSource) NeedLeaderElection() bool {
+	return false
+}
+
+// watchRouter starts watching the given router namespace, replacing the
+// one watched before, if any.
+func (s *netlinkSource) watchRouter(targetNS string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if targetNS == s.routerNS {
+		return
+	}
+	if s.stopRouter != nil {
+		s.stopRouter()
+		s.stopRouter = nil
+	}
+	s.routerNS = targetNS
+	if s.ctx == nil {
+		return
+	}
+	s.startWatchingRouter()
+}
+
+// stopWatchingRouter stops watching the router namespace. This must happen
+// as soon as the router goes away, as the namespace is kept alive (and with
+// it the underlay nic) while it is watched.
+func (s *netlinkSource) stopWatchingRouter() {
+	s.watchRouter("")
+}
+
+// startWatchingRouter must be called with the lock held.
+func (s *netlinkSource) startWatchingRouter() {
+	if s.routerNS == "" {
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.stopRouter = cancel
+	targetNS := s.routerNS
+	go s.watch(ctx, targetNS, func(ctx context.Context) error {
+		return hostnetwork.WatchRouterNamespace(ctx, targetNS, s.notify)
+	})
+}
+
+// watch runs the given watch function until the context is done,
+// restarting it when it fails.
+func (s *netlinkSource) watch(ctx context.Context, namespace string, watchFunc func(context.Context) error) {
+	slog.Info("netlink watch start", "namespace", namespace)
+	defer slog.Info("netlink watch end", "namespace", namespace)
+	for {
+		err := watchFunc(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("netlink watch failed, retrying", "namespace", namespace, "error", err)
+		// some changes may have been missed
+		s.notify("netlink watch restarted")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(netlinkRetryInterval):
+		}
+	}
+}
+
+func (s *netlinkSource) notify(reason string) {
+	slog.Debug("netlink change", "node", s.node, "reason", reason)
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
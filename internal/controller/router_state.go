@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/frrstate"
+	"github.com/openperouter/openperouter/internal/hostnetwork"
+	"github.com/openperouter/openperouter/internal/pods"
+	"github.com/openperouter/openperouter/internal/reloaderconn"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// routerStateReporter periodically records the routing state of the router
+// and the devices managed on the node in the RouterState of the node.
+type routerStateReporter struct {
+	client     client.Client
+	node       string
+	reloadPort int
+	reloader   *reloaderconn.Client
+	podRuntime *pods.Runtime
+	interval   time.Duration
+}
+
+// Start reports the state every interval until the context is done.
+// It implements manager.Runnable.
+func (s *routerStateReporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := s.report(ctx); err != nil {
+			slog.Error("failed to report the router state", "node", s.node, "error", err)
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, as each
+// node reports its own state.
+func (s *routerStateReporter) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *routerStateReporter) report(ctx context.Context) error {
+	var node v1.Node
+	if err := s.client.Get(ctx, types.NamespacedName{Name: s.node}, &node); err != nil {
+		return fmt.Errorf("failed to get node %s: %w", s.node, err)
+	}
+	state, devices, err := s.routerState(ctx)
+	return updateRouterState(ctx, s.client, &node, func(status *v1alpha1.RouterStateStatus) {
+		if err != nil {
+			// the routing state retrieved last is kept, as it is the
+			// best information available
+			status.Error = err.Error()
+		} else {
+			*status = routerStateStatus(state, devices)
+		}
+		now := metav1.Now()
+		status.LastUpdateTime = &now
+	})
+}
+
+// routerState retrieves the routing state from the router pod running on
+// the node, and the devices managed in the host and in the router's namespace.
+func (s *routerStateReporter) routerState(ctx context.Context) (frrstate.State, []hostnetwork.Device, error) {
+	routerPod, err := routerPodForNode(ctx, s.client, s.node)
+	if err != nil {
+		return frrstate.State{}, nil, err
+	}
+	if !PodIsReady(routerPod) {
+		return frrstate.State{}, nil, errors.New("the router pod is not ready")
+	}
+	state, err := frrstate.ForAddress(ctx, fmt.Sprintf("%s:%d", routerPod.Status.PodIP, s.reloadPort), s.reloader)
+	if err != nil {
+		return frrstate.State{}, nil, err
+	}
+	targetNS, err := s.podRuntime.NetworkNamespace(ctx, string(routerPod.UID))
+	if err != nil {
+		return frrstate.State{}, nil, fmt.Errorf("failed to retrieve namespace for pod %s: %w", routerPod.UID, err)
+	}
+	devices, err := hostnetwork.ManagedDevices(targetNS)
+	if err != nil {
+		return frrstate.State{}, nil, err
+	}
+	return state, devices, nil
+}
+
+// updateRouterState applies the given change to the status of the
+// RouterState of the given node, creating it if it does not exist.
+// The RouterState is owned by the node, so that it is deleted with it.
+func updateRouterState(ctx context.Context, cli client.Client, node *v1.Node, update func(*v1alpha1.RouterStateStatus)) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var toUpdate v1alpha1.RouterState
+		err := cli.Get(ctx, types.NamespacedName{Name: node.Name}, &toUpdate)
+		if apierrors.IsNotFound(err) {
+			toUpdate = v1alpha1.RouterState{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: node.Name,
+					OwnerReferences: []metav1.OwnerReference{
+						*metav1.NewControllerRef(node, v1.SchemeGroupVersion.WithKind("Node")),
+					},
+				},
+			}
+			err = cli.Create(ctx, &toUpdate)
+		}
+		if err != nil {
+			return err
+		}
+		update(&toUpdate.Status)
+		return cli.Status().Update(ctx, &toUpdate)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the router state of node %s: %w", node.Name, err)
+	}
+	return nil
+}
+
+// routerStateStatus returns the status reporting the given routing
+// state and devices.
+func routerStateStatus(state frrstate.State, devices []hostnetwork.Device) v1alpha1.RouterStateStatus {
+	res := v1alpha1.RouterStateStatus{
+		TotalSessions: len(state.Neighbors),
+		VNICount:      len(state.VNIs),
+		VRFs:          state.VRFs,
+	}
+	for _, n := range state.Neighbors {
+		if n.Connected {
+			res.EstablishedSessions++
+		}
+		res.BGPSessions = append(res.BGPSessions, v1alpha1.BGPSessionState{
+			Peer:             n.IP.String(),
+			VRF:              n.VRF,
+			Established:      n.Connected,
+			RemoteAS:         n.RemoteAS,
+			PrefixesSent:     n.PrefixSent,
+			PrefixesReceived: n.PrefixReceived,
+		})
+	}
+	for _, p := range state.BFDPeers {
+		res.BFDPeers = append(res.BFDPeers, v1alpha1.BFDPeerState{
+			Peer:   p.Peer,
+			Local:  p.Local,
+			VRF:    p.Vrf,
+			Status: p.Status,
+		})
+	}
+	for _, v := range state.VNIs {
+		res.VNIs = append(res.VNIs, v1alpha1.EVPNVNIState{
+			VNI:  v.VNI,
+			Type: v.Type,
+			VRF:  v.TenantVRF,
+		})
+	}
+	for _, d := range devices {
+		res.Devices = append(res.Devices, v1alpha1.DeviceState{
+			Name:      d.Name,
+			Type:      d.Type,
+			Namespace: d.Namespace,
+			OperState: d.OperState,
+		})
+	}
+	return res
+}
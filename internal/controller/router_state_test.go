@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/frr"
+	"github.com/openperouter/openperouter/internal/frrstate"
+	"github.com/openperouter/openperouter/internal/hostnetwork"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRouterStateStatus(t *testing.T) {
+	state := frrstate.State{
+		VRFs: []string{"default", "red"},
+		Neighbors: []*frr.Neighbor{
+			{IP: net.ParseIP("192.168.11.2"), VRF: "default", Connected: true, RemoteAS: "64612", PrefixSent: 2, PrefixReceived: 3},
+			{IP: net.ParseIP("192.169.10.0"), VRF: "red", RemoteAS: "64515"},
+		},
+		BFDPeers: []frr.BFDPeer{{Peer: "192.168.11.2", Local: "192.168.11.3", Vrf: "default", Status: "up"}},
+		VNIs:     []frr.EVPNVNI{{VNI: 100, Type: "L3", VxlanIf: "br-pe-100", TenantVRF: "red"}},
+	}
+	devices := []hostnetwork.Device{
+		{Name: "hostred", Type: "veth", Namespace: hostnetwork.HostNamespace, OperState: "up"},
+	}
+
+	res := routerStateStatus(state, devices)
+	expected := v1alpha1.RouterStateStatus{
+		EstablishedSessions: 1,
+		TotalSessions:       2,
+		VNICount:            1,
+		BGPSessions: []v1alpha1.BGPSessionState{
+			{Peer: "192.168.11.2", VRF: "default", Established: true, RemoteAS: "64612", PrefixesSent: 2, PrefixesReceived: 3},
+			{Peer: "192.169.10.0", VRF: "red", RemoteAS: "64515"},
+		},
+		BFDPeers: []v1alpha1.BFDPeerState{{Peer: "192.168.11.2", Local: "192.168.11.3", VRF: "default", Status: "up"}},
+		VRFs:     []string{"default", "red"},
+		VNIs:     []v1alpha1.EVPNVNIState{{VNI: 100, Type: "L3", VRF: "red"}},
+		Devices:  []v1alpha1.DeviceState{{Name: "hostred", Type: "veth", Namespace: "host", OperState: "up"}},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, res)
+	}
+}
+
+func TestUpdateRouterState(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	ctx := context.Background()
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", UID: "node0-uid"}}
+	cli := fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&v1alpha1.RouterState{}).Build()
+
+	err := updateRouterState(ctx, cli, node, func(status *v1alpha1.RouterStateStatus) {
+		status.VNICount = 2
+	})
+	if err != nil {
+		t.Fatalf("failed to update router state: %v", err)
+	}
+	err = updateRouterState(ctx, cli, node, func(status *v1alpha1.RouterStateStatus) {
+		status.Error = "failed"
+	})
+	if err != nil {
+		t.Fatalf("failed to update router state: %v", err)
+	}
+
+	var current v1alpha1.RouterState
+	if err := cli.Get(ctx, types.NamespacedName{Name: "node0"}, &current); err != nil {
+		t.Fatalf("failed to get router state: %v", err)
+	}
+	if current.Status.VNICount != 2 || current.Status.Error != "failed" {
+		t.Fatalf("unexpected status %+v", current.Status)
+	}
+	if len(current.OwnerReferences) != 1 || current.OwnerReferences[0].UID != node.UID {
+		t.Fatalf("expected router state to be owned by the node, got %+v", current.OwnerReferences)
+	}
+}
@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/conversion"
+	"github.com/openperouter/openperouter/internal/ipam"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type vniStatusData struct {
+	node      string
+	nodeIndex int
+	underlays []v1alpha1.Underlay
+	vnis      []v1alpha1.VNI
+	err       error
+}
+
+// updateVNIsStatus reports the addresses allocated to the current node
+// and the outcome of the configuration in the status of each VNI.
+func updateVNIsStatus(ctx context.Context, cli client.Client, data vniStatusData) error {
+	for _, vni := range data.vnis {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			var toUpdate v1alpha1.VNI
+			if err := cli.Get(ctx, client.ObjectKeyFromObject(&vni), &toUpdate); err != nil {
+				return err
+			}
+			nodeStatus := vniNodeStatus(toUpdate, data)
+			if !setNodeStatus(&toUpdate.Status, nodeStatus) {
+				return nil
+			}
+			return cli.Status().Update(ctx, &toUpdate)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update status for vni %s: %w", vni.Name, err)
+		}
+	}
+	return nil
+}
+
+// clearVNIsStatus removes the entry related to the given node from the
+// status of the given vnis, as they don't apply to the node anymore.
+func clearVNIsStatus(ctx context.Context, cli client.Client, node string, vnis []v1alpha1.VNI) error {
+	for _, vni := range vnis {
+		if nodeStatusFor(vni.Status, node) == nil {
+			continue
+		}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			var toUpdate v1alpha1.VNI
+			if err := cli.Get(ctx, client.ObjectKeyFromObject(&vni), &toUpdate); err != nil {
+				return err
+			}
+			if !removeNodeStatus(&toUpdate.Status, node) {
+				return nil
+			}
+			return cli.Status().Update(ctx, &toUpdate)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to clear status for vni %s: %w", vni.Name, err)
+		}
+	}
+	return nil
+}
+
+// vnisNotIn returns the vnis of all that are not contained in selected.
+func vnisNotIn(all, selected []v1alpha1.VNI) []v1alpha1.VNI {
+	names := map[string]bool{}
+	for _, v := range selected {
+		names[v.Name] = true
+	}
+	res := []v1alpha1.VNI{}
+	for _, v := range all {
+		if !names[v.Name] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// vniNodeStatus returns the status of the given vni for the current node.
+func vniNodeStatus(vni v1alpha1.VNI, data vniStatusData) v1alpha1.VNINodeStatus {
+	res := v1alpha1.VNINodeStatus{
+		Node: data.node,
+	}
+	if existing := nodeStatusFor(vni.Status, data.node); existing != nil {
+		res.Conditions = append(res.Conditions, existing.Conditions...)
+	}
+
+	configErr := data.err
+	vethsIPs, err := conversion.VethIPsForVNI(vni, data.nodeIndex)
+	if err == nil {
+		for _, veths := range vethsIPs {
+			res.HostIPs = append(res.HostIPs, veths.HostSide.IP.String())
+			res.PEIPs = append(res.PEIPs, veths.ContainerSide.IP.String())
+		}
+	} else if configErr == nil {
+		configErr = err
+	}
+
+	// the underlays selecting the same node share the same vtep cidr
+	if len(data.underlays) > 0 {
+		vtepCIDR := data.underlays[0].Spec.VTEPCIDR
+		vtepIP, err := ipam.VTEPIp(vtepCIDR, data.nodeIndex)
+		if err == nil {
+			ip, _, _ := net.ParseCIDR(vtepIP)
+			res.VTEPIP = ip.String()
+		} else if configErr == nil {
+			configErr = fmt.Errorf("failed to get vtep ip, cidr %s: %w", vtepCIDR, err)
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               v1alpha1.VNIReadyCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             v1alpha1.VNIConfiguredReason,
+		ObservedGeneration: vni.Generation,
+	}
+	if configErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = v1alpha1.VNIFailedReason
+		if reason, ok := failureReason(configErr); ok {
+			condition.Reason = reason
+		}
+		condition.Message = configErr.Error()
+	}
+	meta.SetStatusCondition(&res.Conditions, condition)
+	return res
+}
+
+// setNodeStatus adds or replaces the entry related to the node of the
+// given status. It returns true if the status was changed.
+func setNodeStatus(status *v1alpha1.VNIStatus, nodeStatus v1alpha1.VNINodeStatus) bool {
+	existing := nodeStatusFor(*status, nodeStatus.Node)
+	if existing == nil {
+		status.Nodes = append(status.Nodes, nodeStatus)
+		return true
+	}
+	if equality.Semantic.DeepEqual(*existing, nodeStatus) {
+		return false
+	}
+	*existing = nodeStatus
+	return true
+}
+
+// removeNodeStatus removes the entry related to the given node from
+// the given status. It returns true if the status was changed.
+func removeNodeStatus(status *v1alpha1.VNIStatus, node string) bool {
+	for i := range status.Nodes {
+		if status.Nodes[i].Node == node {
+			status.Nodes = append(status.Nodes[:i], status.Nodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func nodeStatusFor(status v1alpha1.VNIStatus, node string) *v1alpha1.VNINodeStatus {
+	for i := range status.Nodes {
+		if status.Nodes[i].Node == node {
+			return &status.Nodes[i]
+		}
+	}
+	return nil
+}
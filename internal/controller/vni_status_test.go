@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVNINodeStatus(t *testing.T) {
+	underlays := []v1alpha1.Underlay{
+		{Spec: v1alpha1.UnderlaySpec{VTEPCIDR: "100.65.0.0/24"}},
+	}
+	vni := v1alpha1.VNI{
+		ObjectMeta: metav1.ObjectMeta{Name: "red"},
+		Spec: v1alpha1.VNISpec{
+			LocalCIDR:   "192.169.10.0/24",
+			LocalCIDRV6: "2001:db8:10::/64",
+		},
+	}
+
+	tests := []struct {
+		name              string
+		data              vniStatusData
+		expectedHostIPs   []string
+		expectedPEIPs     []string
+		expectedVTEPIP    string
+		expectedCondition metav1.ConditionStatus
+		expectedReason    string
+		expectedMessage   string
+	}{
+		{
+			name: "configured",
+			data: vniStatusData{
+				node:      "node1",
+				nodeIndex: 1,
+				underlays: underlays,
+			},
+			expectedHostIPs:   []string{"192.169.10.2", "2001:db8:10::2"},
+			expectedPEIPs:     []string{"192.169.10.0", "2001:db8:10::"},
+			expectedVTEPIP:    "100.65.0.1",
+			expectedCondition: metav1.ConditionTrue,
+			expectedReason:    v1alpha1.VNIConfiguredReason,
+		},
+		{
+			name: "failed",
+			data: vniStatusData{
+				node:      "node1",
+				nodeIndex: 0,
+				underlays: underlays,
+				err:       errors.New("failed to reload"),
+			},
+			expectedHostIPs:   []string{"192.169.10.1", "2001:db8:10::1"},
+			expectedPEIPs:     []string{"192.169.10.0", "2001:db8:10::"},
+			expectedVTEPIP:    "100.65.0.0",
+			expectedCondition: metav1.ConditionFalse,
+			expectedReason:    v1alpha1.VNIFailedReason,
+			expectedMessage:   "failed to reload",
+		},
+		{
+			name: "failed with reason",
+			data: vniStatusData{
+				node:      "node1",
+				nodeIndex: 0,
+				underlays: underlays,
+				err:       frrReloadFailed(errors.New("failed to reload"), nil),
+			},
+			expectedHostIPs:   []string{"192.169.10.1", "2001:db8:10::1"},
+			expectedPEIPs:     []string{"192.169.10.0", "2001:db8:10::"},
+			expectedVTEPIP:    "100.65.0.0",
+			expectedCondition: metav1.ConditionFalse,
+			expectedReason:    v1alpha1.FRRReloadFailedReason,
+			expectedMessage:   "failed to reload",
+		},
+		{
+			name: "two underlays",
+			data: vniStatusData{
+				node:      "node1",
+				nodeIndex: 1,
+				underlays: append(underlays, v1alpha1.Underlay{Spec: v1alpha1.UnderlaySpec{VTEPCIDR: "100.65.0.0/24", Nics: []string{"eth2"}}}),
+			},
+			expectedHostIPs:   []string{"192.169.10.2", "2001:db8:10::2"},
+			expectedPEIPs:     []string{"192.169.10.0", "2001:db8:10::"},
+			expectedVTEPIP:    "100.65.0.1",
+			expectedCondition: metav1.ConditionTrue,
+			expectedReason:    v1alpha1.VNIConfiguredReason,
+		},
+		{
+			name: "no underlay",
+			data: vniStatusData{
+				node:      "node1",
+				nodeIndex: 0,
+			},
+			expectedHostIPs:   []string{"192.169.10.1", "2001:db8:10::1"},
+			expectedPEIPs:     []string{"192.169.10.0", "2001:db8:10::"},
+			expectedCondition: metav1.ConditionTrue,
+			expectedReason:    v1alpha1.VNIConfiguredReason,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := vniNodeStatus(vni, tc.data)
+			if res.Node != tc.data.node {
+				t.Fatalf("expecting node %s, got %s", tc.data.node, res.Node)
+			}
+			if !reflect.DeepEqual(res.HostIPs, tc.expectedHostIPs) {
+				t.Fatalf("expecting host ips %v, got %v", tc.expectedHostIPs, res.HostIPs)
+			}
+			if !reflect.DeepEqual(res.PEIPs, tc.expectedPEIPs) {
+				t.Fatalf("expecting pe ips %v, got %v", tc.expectedPEIPs, res.PEIPs)
+			}
+			if res.VTEPIP != tc.expectedVTEPIP {
+				t.Fatalf("expecting vtep ip %s, got %s", tc.expectedVTEPIP, res.VTEPIP)
+			}
+			condition := meta.FindStatusCondition(res.Conditions, v1alpha1.VNIReadyCondition)
+			if condition == nil {
+				t.Fatalf("ready condition not found")
+			}
+			if condition.Status != tc.expectedCondition {
+				t.Fatalf("expecting condition %s, got %s", tc.expectedCondition, condition.Status)
+			}
+			if condition.Reason != tc.expectedReason {
+				t.Fatalf("expecting reason %s, got %s", tc.expectedReason, condition.Reason)
+			}
+			if condition.Message != tc.expectedMessage {
+				t.Fatalf("expecting message %q, got %q", tc.expectedMessage, condition.Message)
+			}
+		})
+	}
+}
+
+func TestSetNodeStatus(t *testing.T) {
+	status := v1alpha1.VNIStatus{}
+	first := v1alpha1.VNINodeStatus{Node: "node1", HostIPs: []string{"192.169.10.1"}}
+	if !setNodeStatus(&status, first) {
+		t.Fatalf("expecting status to change when adding a node")
+	}
+	if setNodeStatus(&status, first) {
+		t.Fatalf("expecting status not to change when setting the same node status")
+	}
+	second := v1alpha1.VNINodeStatus{Node: "node2", HostIPs: []string{"192.169.10.2"}}
+	if !setNodeStatus(&status, second) {
+		t.Fatalf("expecting status to change when adding a node")
+	}
+	first.HostIPs = []string{"192.169.10.3"}
+	if !setNodeStatus(&status, first) {
+		t.Fatalf("expecting status to change when updating a node")
+	}
+	if len(status.Nodes) != 2 {
+		t.Fatalf("expecting 2 nodes, got %v", status.Nodes)
+	}
+	if status.Nodes[0].HostIPs[0] != "192.169.10.3" {
+		t.Fatalf("expecting node1 to be updated, got %v", status.Nodes[0])
+	}
+}
+
+func TestRemoveNodeStatus(t *testing.T) {
+	status := v1alpha1.VNIStatus{
+		Nodes: []v1alpha1.VNINodeStatus{
+			{Node: "node1", HostIPs: []string{"192.169.10.1"}},
+			{Node: "node2", HostIPs: []string{"192.169.10.2"}},
+		},
+	}
+	if removeNodeStatus(&status, "node3") {
+		t.Fatalf("expecting status not to change when removing a missing node")
+	}
+	if !removeNodeStatus(&status, "node1") {
+		t.Fatalf("expecting status to change when removing a node")
+	}
+	if len(status.Nodes) != 1 || status.Nodes[0].Node != "node2" {
+		t.Fatalf("expecting only node2 to be left, got %v", status.Nodes)
+	}
+}
@@ -2,12 +2,14 @@ package conversion
 
 import (
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/openperouter/openperouter/api/v1alpha1"
 	"github.com/openperouter/openperouter/internal/frr"
 	"github.com/openperouter/openperouter/internal/ipam"
 	"github.com/openperouter/openperouter/internal/ipfamily"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/ptr"
 )
@@ -20,36 +22,53 @@ func (e FRRConversionError) Error() string {
 	return e.msg
 }
 
-func APItoFRR(nodeIndex int, underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI, logLevel string) (frr.Config, error) {
-	if len(underlays) > 1 {
-		return frr.Config{}, FRRConversionError{msg: "can't have more than one underlay"}
-	}
+// APItoFRR converts the given resources to the FRR configuration of the node with the given index.
+// The passwordSecrets map contains the secrets the neighbors' passwords are read from, indexed by name.
+// The l2vnis are not rendered explicitly, as FRR advertises the type-2 routes of all
+// the vxlan interfaces it finds via advertise-all-vni.
+// Having no vnis is a valid configuration, where only the underlay is configured, and
+// having no underlays results in an empty configuration.
+func APItoFRR(nodeIndex int, underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI, l2vnis []v1alpha1.L2VNI, logLevel string, passwordSecrets map[string]v1.Secret) (frr.Config, error) {
 	if len(underlays) == 0 {
-		return frr.Config{}, FRRConversionError{msg: "no underlays defined"}
-	}
-	if len(vnis) == 0 {
-		return frr.Config{}, FRRConversionError{msg: "no vnis defined"}
+		return frr.Config{Loglevel: logLevel}, nil
 	}
 
-	underlay := underlays[0]
-	vtepIP, err := ipam.VTEPIp(underlay.Spec.VTEPCIDR, nodeIndex)
+	underlay, err := mergeUnderlays(underlays)
+	if err != nil {
+		return frr.Config{}, FRRConversionError{msg: err.Error()}
+	}
+	vtepIP, err := ipam.VTEPIp(underlay.VTEPCIDR, nodeIndex)
 	if err != nil {
-		return frr.Config{}, fmt.Errorf("failed to get vtep ip, cidr %s, nodeIntex %d", underlay.Spec.VTEPCIDR, nodeIndex)
+		return frr.Config{}, fmt.Errorf("failed to get vtep ip, cidr %s, nodeIntex %d", underlay.VTEPCIDR, nodeIndex)
+	}
+
+	bfdProfiles := []frr.BFDProfile{}
+	definedProfiles := map[string]bool{}
+	for _, p := range underlay.BFDProfiles {
+		bfdProfiles = append(bfdProfiles, bfdProfileToFRR(p))
+		definedProfiles[p.Name] = true
 	}
 
 	underlayNeighbors := []frr.NeighborConfig{}
-	for _, n := range underlay.Spec.Neighbors {
-		frrNeigh, err := neighborToFRR(n)
+	for _, n := range underlay.Neighbors {
+		if n.BFDProfile != "" && !definedProfiles[n.BFDProfile] {
+			return frr.Config{}, FRRConversionError{msg: fmt.Sprintf("neighbor %s references bfd profile %s which is not defined", neighborName(n), n.BFDProfile)}
+		}
+		frrNeigh, err := neighborToFRR(n, passwordSecrets)
 		if err != nil {
 			return frr.Config{}, fmt.Errorf("failed to translate underlay neighbor %s to frr, err: %w", neighborName(n), err)
 		}
 		underlayNeighbors = append(underlayNeighbors, *frrNeigh)
 	}
 	underlayConfig := frr.UnderlayConfig{
-		MyASN:     underlay.Spec.ASN,
+		MyASN:     underlay.ASN,
 		VTEP:      vtepIP,
 		Neighbors: underlayNeighbors,
 	}
+	// each nic is an independent path toward the other vteps
+	if len(underlay.Nics) > 1 {
+		underlayConfig.MaximumPaths = len(underlay.Nics)
+	}
 	vniConfigs := []frr.VNIConfig{}
 	for _, vni := range vnis {
 		frrVNI, err := vniToFRR(vni, nodeIndex)
@@ -60,34 +79,52 @@ func APItoFRR(nodeIndex int, underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI,
 	}
 
 	return frr.Config{
-		Underlay: underlayConfig,
-		VNIs:     vniConfigs,
-		Loglevel: logLevel,
+		Underlay:    underlayConfig,
+		VNIs:        vniConfigs,
+		BFDProfiles: bfdProfiles,
+		Loglevel:    logLevel,
 	}, nil
 }
 
-func vniToFRR(vni v1alpha1.VNI, nodeIndex int) (frr.VNIConfig, error) {
-	veths, err := ipam.VethIPs(vni.Spec.LocalCIDR, nodeIndex)
-	if err != nil {
-		return frr.VNIConfig{}, fmt.Errorf("failed to get veths ips for vni %s: %w", vni.Name, err)
+func bfdProfileToFRR(p v1alpha1.BFDProfile) frr.BFDProfile {
+	return frr.BFDProfile{
+		Name:             p.Name,
+		ReceiveInterval:  p.ReceiveInterval,
+		TransmitInterval: p.TransmitInterval,
+		DetectMultiplier: p.DetectMultiplier,
+		EchoInterval:     p.EchoInterval,
+		EchoMode:         ptr.Deref(p.EchoMode, false),
+		PassiveMode:      ptr.Deref(p.PassiveMode, false),
+		MinimumTTL:       p.MinimumTTL,
 	}
+}
 
-	vniNeighbor := &frr.NeighborConfig{
-		Addr: veths.HostSide.IP.String(),
-		ASN:  vni.Spec.LocalASN,
+func vniToFRR(vni v1alpha1.VNI, nodeIndex int) (frr.VNIConfig, error) {
+	vethsIPs, err := VethIPsForVNI(vni, nodeIndex)
+	if err != nil {
+		return frr.VNIConfig{}, err
 	}
 
 	res := frr.VNIConfig{
-		ASN:           vni.Spec.ASN,
-		VNI:           int(vni.Spec.VNI),
-		VRF:           vni.Spec.VRF,
-		LocalNeighbor: vniNeighbor,
-		ToAdvertise:   []string{vniNeighbor.Addr + "/32"}, // TODO Hack
+		ASN: vni.Spec.ASN,
+		VNI: int(vni.Spec.VNI),
+		VRF: vni.Spec.VRF,
+	}
+	for _, veths := range vethsIPs {
+		hostIP := veths.HostSide.IP
+		res.LocalNeighbors = append(res.LocalNeighbors, frr.NeighborConfig{
+			Addr:     hostIP.String(),
+			ASN:      vni.Spec.LocalASN,
+			IPFamily: ipfamily.ForAddress(hostIP),
+		})
+		_, bits := veths.HostSide.Mask.Size()
+		hostNet := net.IPNet{IP: hostIP, Mask: net.CIDRMask(bits, bits)}
+		res.ToAdvertise = append(res.ToAdvertise, hostNet.String()) // TODO Hack
 	}
 	return res, nil
 }
 
-func neighborToFRR(n v1alpha1.Neighbor) (*frr.NeighborConfig, error) {
+func neighborToFRR(n v1alpha1.Neighbor, passwordSecrets map[string]v1.Secret) (*frr.NeighborConfig, error) {
 	neighborFamily, err := ipfamily.ForAddresses(n.Address)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find ipfamily for %s, %w", n.Address, err)
@@ -115,14 +152,39 @@ func neighborToFRR(n v1alpha1.Neighbor) (*frr.NeighborConfig, error) {
 		res.ConnectTime = ptr.To(uint64(n.ConnectTime.Duration / time.Second))
 	}
 
-	/* TODO passwords
 	res.Password, err = passwordForNeighbor(n, passwordSecrets)
 	if err != nil {
 		return nil, err
-	} */
+	}
 	return res, nil
 }
 
+// passwordForNeighbor returns the password to be used for the given neighbor, either
+// from the neighbor itself or from the basic-auth secret it refers to.
+func passwordForNeighbor(n v1alpha1.Neighbor, passwordSecrets map[string]v1.Secret) (string, error) {
+	if n.Password != "" && n.PasswordSecret != "" {
+		return "", fmt.Errorf("neighbor %s specifies both cleartext password and secret ref", neighborName(n))
+	}
+	if n.Password != "" {
+		return n.Password, nil
+	}
+	if n.PasswordSecret == "" {
+		return "", nil
+	}
+	secret, ok := passwordSecrets[n.PasswordSecret]
+	if !ok {
+		return "", fmt.Errorf("secret %s not found for neighbor %s", n.PasswordSecret, neighborName(n))
+	}
+	if secret.Type != v1.SecretTypeBasicAuth {
+		return "", fmt.Errorf("secret type mismatch on %s/%s, type %s is expected", secret.Namespace, secret.Name, v1.SecretTypeBasicAuth)
+	}
+	password, ok := secret.Data[v1.BasicAuthPasswordKey]
+	if !ok {
+		return "", fmt.Errorf("password not specified in the secret %s/%s", secret.Namespace, secret.Name)
+	}
+	return string(password), nil
+}
+
 func neighborName(n v1alpha1.Neighbor) string {
 	return fmt.Sprintf("%d@%s", n.ASN, n.Address)
 }
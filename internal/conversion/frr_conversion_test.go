@@ -0,0 +1,199 @@
+package conversion
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/frr"
+	"github.com/openperouter/openperouter/internal/ipfamily"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPasswordForNeighbor(t *testing.T) {
+	secrets := map[string]v1.Secret{
+		"valid": {
+			ObjectMeta: metav1.ObjectMeta{Name: "valid", Namespace: "openperouter-system"},
+			Type:       v1.SecretTypeBasicAuth,
+			Data:       map[string][]byte{"password": []byte("fromsecret")},
+		},
+		"wrongtype": {
+			ObjectMeta: metav1.ObjectMeta{Name: "wrongtype", Namespace: "openperouter-system"},
+			Type:       v1.SecretTypeOpaque,
+			Data:       map[string][]byte{"password": []byte("fromsecret")},
+		},
+		"nopassword": {
+			ObjectMeta: metav1.ObjectMeta{Name: "nopassword", Namespace: "openperouter-system"},
+			Type:       v1.SecretTypeBasicAuth,
+			Data:       map[string][]byte{"username": []byte("user")},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		neighbor         v1alpha1.Neighbor
+		expectedPassword string
+		expectedErr      string
+	}{
+		{
+			name:     "no password",
+			neighbor: v1alpha1.Neighbor{ASN: 64512, Address: "192.168.1.2"},
+		},
+		{
+			name:             "cleartext password",
+			neighbor:         v1alpha1.Neighbor{ASN: 64512, Address: "192.168.1.2", Password: "cleartext"},
+			expectedPassword: "cleartext",
+		},
+		{
+			name:             "password from secret",
+			neighbor:         v1alpha1.Neighbor{ASN: 64512, Address: "192.168.1.2", PasswordSecret: "valid"},
+			expectedPassword: "fromsecret",
+		},
+		{
+			name:        "both set",
+			neighbor:    v1alpha1.Neighbor{ASN: 64512, Address: "192.168.1.2", Password: "cleartext", PasswordSecret: "valid"},
+			expectedErr: "specifies both",
+		},
+		{
+			name:        "secret not found",
+			neighbor:    v1alpha1.Neighbor{ASN: 64512, Address: "192.168.1.2", PasswordSecret: "missing"},
+			expectedErr: "not found",
+		},
+		{
+			name:        "wrong secret type",
+			neighbor:    v1alpha1.Neighbor{ASN: 64512, Address: "192.168.1.2", PasswordSecret: "wrongtype"},
+			expectedErr: "type mismatch",
+		},
+		{
+			name:        "password missing in secret",
+			neighbor:    v1alpha1.Neighbor{ASN: 64512, Address: "192.168.1.2", PasswordSecret: "nopassword"},
+			expectedErr: "password not specified",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := neighborToFRR(tc.neighbor, secrets)
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("expecting error containing %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+			if res.Password != tc.expectedPassword {
+				t.Fatalf("expecting password %q, got %q", tc.expectedPassword, res.Password)
+			}
+		})
+	}
+}
+
+func TestVNIToFRRDualStack(t *testing.T) {
+	vni := v1alpha1.VNI{
+		ObjectMeta: metav1.ObjectMeta{Name: "red"},
+		Spec: v1alpha1.VNISpec{
+			ASN:         64514,
+			VRF:         "red",
+			VNI:         100,
+			LocalASN:    64515,
+			LocalCIDR:   "192.169.10.0/24",
+			LocalCIDRV6: "2001:db8:10::/64",
+		},
+	}
+
+	res, err := vniToFRR(vni, 1)
+	if err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+	if len(res.LocalNeighbors) != 2 {
+		t.Fatalf("expecting 2 local neighbors, got %v", res.LocalNeighbors)
+	}
+	expectedNeighbors := []struct {
+		addr   string
+		family ipfamily.Family
+	}{
+		{"192.169.10.2", ipfamily.IPv4},
+		{"2001:db8:10::2", ipfamily.IPv6},
+	}
+	for i, n := range expectedNeighbors {
+		if res.LocalNeighbors[i].Addr != n.addr || res.LocalNeighbors[i].IPFamily != n.family {
+			t.Fatalf("expecting neighbor %s %s, got %v", n.addr, n.family, res.LocalNeighbors[i])
+		}
+		if res.LocalNeighbors[i].ASN != 64515 {
+			t.Fatalf("expecting neighbor asn 64515, got %d", res.LocalNeighbors[i].ASN)
+		}
+	}
+	expectedAdvertise := []string{"192.169.10.2/32", "2001:db8:10::2/128"}
+	if !reflect.DeepEqual(res.ToAdvertise, expectedAdvertise) {
+		t.Fatalf("expecting to advertise %v, got %v", expectedAdvertise, res.ToAdvertise)
+	}
+}
+
+func TestAPItoFRRWithL2VNIsOnly(t *testing.T) {
+	underlays := []v1alpha1.Underlay{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "underlay"},
+			Spec: v1alpha1.UnderlaySpec{
+				ASN:      64514,
+				VTEPCIDR: "100.65.0.0/24",
+				Nics:     []string{"eth1"},
+				Neighbors: []v1alpha1.Neighbor{
+					{ASN: 64512, Address: "192.168.11.2"},
+				},
+			},
+		},
+	}
+	l2vnis := []v1alpha1.L2VNI{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "l2"},
+			Spec:       v1alpha1.L2VNISpec{VNI: 110},
+		},
+	}
+
+	res, err := APItoFRR(0, underlays, nil, l2vnis, "debug", nil)
+	if err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+	if len(res.VNIs) != 0 {
+		t.Fatalf("expecting no l3 vnis, got %v", res.VNIs)
+	}
+	if res.Underlay.VTEP != "100.65.0.0/32" {
+		t.Fatalf("expecting vtep 100.65.0.0/32, got %s", res.Underlay.VTEP)
+	}
+}
+
+func TestAPItoFRRTearDown(t *testing.T) {
+	underlays := []v1alpha1.Underlay{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "underlay"},
+			Spec: v1alpha1.UnderlaySpec{
+				ASN:      64514,
+				VTEPCIDR: "100.65.0.0/24",
+				Nics:     []string{"eth1"},
+				Neighbors: []v1alpha1.Neighbor{
+					{ASN: 64512, Address: "192.168.11.2"},
+				},
+			},
+		},
+	}
+
+	res, err := APItoFRR(0, underlays, nil, nil, "debug", nil)
+	if err != nil {
+		t.Fatalf("expecting no error with no vnis, got %v", err)
+	}
+	if len(res.VNIs) != 0 || res.Underlay.MyASN != 64514 {
+		t.Fatalf("expecting only the underlay to be configured, got %v", res)
+	}
+
+	res, err = APItoFRR(0, nil, nil, nil, "debug", nil)
+	if err != nil {
+		t.Fatalf("expecting no error with no underlays, got %v", err)
+	}
+	if !reflect.DeepEqual(res, frr.Config{Loglevel: "debug"}) {
+		t.Fatalf("expecting empty config with no underlays, got %v", res)
+	}
+}
@@ -6,49 +6,78 @@ import (
 	"github.com/openperouter/openperouter/api/v1alpha1"
 	"github.com/openperouter/openperouter/internal/hostnetwork"
 	"github.com/openperouter/openperouter/internal/ipam"
+	"github.com/openperouter/openperouter/internal/ipfamily"
 )
 
 // TODO Validate
 // TODO UnitTest
-func APItoHostConfig(nodeIndex int, targetNS string, underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI) (hostnetwork.UnderlayParams, []hostnetwork.VNIParams, error) {
-	if len(underlays) > 1 {
-		return hostnetwork.UnderlayParams{}, nil, fmt.Errorf("can't have more than one underlay")
-	}
-	if len(underlays) == 0 || len(vnis) == 0 {
-		return hostnetwork.UnderlayParams{}, nil, nil
+func APItoHostConfig(nodeIndex int, targetNS string, underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI, l2vnis []v1alpha1.L2VNI) (hostnetwork.UnderlayParams, []hostnetwork.VNIParams, []hostnetwork.L2VNIParams, error) {
+	// without an underlay, no vni can be configured
+	if len(underlays) == 0 {
+		return hostnetwork.UnderlayParams{}, nil, nil, nil
 	}
 
-	underlay := underlays[0]
+	underlay, err := mergeUnderlays(underlays)
+	if err != nil {
+		return hostnetwork.UnderlayParams{}, nil, nil, err
+	}
 
-	vtepIP, err := ipam.VTEPIp(underlay.Spec.VTEPCIDR, nodeIndex)
+	vtepIP, err := ipam.VTEPIp(underlay.VTEPCIDR, nodeIndex)
 	if err != nil {
-		return hostnetwork.UnderlayParams{}, nil, fmt.Errorf("failed to get vtep ip, cidr %s, nodeIntex %d", underlay.Spec.VTEPCIDR, nodeIndex)
+		return hostnetwork.UnderlayParams{}, nil, nil, fmt.Errorf("failed to get vtep ip, cidr %s, nodeIntex %d", underlay.VTEPCIDR, nodeIndex)
 	}
 
 	underlayParams := hostnetwork.UnderlayParams{
-		MainNic:  underlay.Spec.Nic,
+		Nics:     underlay.Nics,
 		TargetNS: targetNS,
 		VtepIP:   vtepIP,
 	}
 
 	vniParams := []hostnetwork.VNIParams{}
 	for _, vni := range vnis {
-		vethIPs, err := ipam.VethIPs(vni.Spec.LocalCIDR, nodeIndex)
+		vethsIPs, err := VethIPsForVNI(vni, nodeIndex)
 		if err != nil {
-			return hostnetwork.UnderlayParams{}, nil, fmt.Errorf("failed to get veth ips, cidr %s, nodeIndex %d", vni.Spec.LocalCIDR, nodeIndex)
+			return hostnetwork.UnderlayParams{}, nil, nil, err
 		}
 
 		v := hostnetwork.VNIParams{
-			VRF:        vni.Spec.VRF,
-			TargetNS:   targetNS,
-			VTEPIP:     vtepIP,
-			VNI:        int(vni.Spec.VNI),
-			VethHostIP: vethIPs.HostSide.String(),
-			VethNSIP:   vethIPs.ContainerSide.String(),
-			VXLanPort:  int(vni.Spec.VXLanPort),
+			VRF:       vni.Spec.VRF,
+			TargetNS:  targetNS,
+			VTEPIP:    vtepIP,
+			VNI:       int(vni.Spec.VNI),
+			VXLanPort: int(vni.Spec.VXLanPort),
+		}
+		for _, veths := range vethsIPs {
+			if ipfamily.ForAddress(veths.HostSide.IP) == ipfamily.IPv6 {
+				v.VethHostIPv6 = veths.HostSide.String()
+				v.VethNSIPv6 = veths.ContainerSide.String()
+				continue
+			}
+			v.VethHostIPv4 = veths.HostSide.String()
+			v.VethNSIPv4 = veths.ContainerSide.String()
 		}
 		vniParams = append(vniParams, v)
 	}
 
-	return underlayParams, vniParams, nil
+	l2vniParams := []hostnetwork.L2VNIParams{}
+	for _, l2vni := range l2vnis {
+		v := hostnetwork.L2VNIParams{
+			TargetNS:  targetNS,
+			VTEPIP:    vtepIP,
+			VNI:       int(l2vni.Spec.VNI),
+			VXLanPort: int(l2vni.Spec.VXLanPort),
+		}
+		if l2vni.Spec.VRF != nil {
+			v.VRF = *l2vni.Spec.VRF
+		}
+		if l2vni.Spec.HostMaster != nil {
+			v.HostMaster = &hostnetwork.HostMaster{
+				Name:       l2vni.Spec.HostMaster.Name,
+				AutoCreate: l2vni.Spec.HostMaster.AutoCreate,
+			}
+		}
+		l2vniParams = append(l2vniParams, v)
+	}
+
+	return underlayParams, vniParams, l2vniParams, nil
 }
@@ -0,0 +1,34 @@
+package conversion
+
+import (
+	"fmt"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/ipam"
+)
+
+// localCIDRs returns the non empty local cidrs of the given vni.
+func localCIDRs(vni v1alpha1.VNI) []string {
+	res := []string{}
+	if vni.Spec.LocalCIDR != "" {
+		res = append(res, vni.Spec.LocalCIDR)
+	}
+	if vni.Spec.LocalCIDRV6 != "" {
+		res = append(res, vni.Spec.LocalCIDRV6)
+	}
+	return res
+}
+
+// VethIPsForVNI returns the ips of the veth legs allocated to the node with
+// the given index, one for each of the local cidrs of the vni.
+func VethIPsForVNI(vni v1alpha1.VNI, nodeIndex int) ([]ipam.Veths, error) {
+	res := []ipam.Veths{}
+	for _, cidr := range localCIDRs(vni) {
+		veths, err := ipam.VethIPs(cidr, nodeIndex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get veths ips for vni %s, cidr %s, nodeIndex %d: %w", vni.Name, cidr, nodeIndex, err)
+		}
+		res = append(res, veths)
+	}
+	return res, nil
+}
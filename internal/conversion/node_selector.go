@@ -0,0 +1,68 @@
+package conversion
+
+import (
+	"fmt"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// UnderlaysForNode returns the underlays whose node selector matches the given node.
+func UnderlaysForNode(node *v1.Node, underlays []v1alpha1.Underlay) ([]v1alpha1.Underlay, error) {
+	res := []v1alpha1.Underlay{}
+	for _, u := range underlays {
+		matches, err := selectsNode(u.Spec.NodeSelector, node)
+		if err != nil {
+			return nil, fmt.Errorf("underlay %s: %w", u.Name, err)
+		}
+		if matches {
+			res = append(res, u)
+		}
+	}
+	return res, nil
+}
+
+// VNIsForNode returns the vnis whose node selector matches the given node.
+func VNIsForNode(node *v1.Node, vnis []v1alpha1.VNI) ([]v1alpha1.VNI, error) {
+	res := []v1alpha1.VNI{}
+	for _, v := range vnis {
+		matches, err := selectsNode(v.Spec.NodeSelector, node)
+		if err != nil {
+			return nil, fmt.Errorf("vni %s: %w", v.Name, err)
+		}
+		if matches {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+// L2VNIsForNode returns the l2vnis whose node selector matches the given node.
+func L2VNIsForNode(node *v1.Node, l2vnis []v1alpha1.L2VNI) ([]v1alpha1.L2VNI, error) {
+	res := []v1alpha1.L2VNI{}
+	for _, v := range l2vnis {
+		matches, err := selectsNode(v.Spec.NodeSelector, node)
+		if err != nil {
+			return nil, fmt.Errorf("l2vni %s: %w", v.Name, err)
+		}
+		if matches {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+// selectsNode tells if the given selector matches the labels of the node.
+// A nil selector matches all the nodes.
+func selectsNode(selector *metav1.LabelSelector, node *v1.Node) (bool, error) {
+	if selector == nil {
+		return true, nil
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid node selector: %w", err)
+	}
+	return s.Matches(labels.Set(node.Labels)), nil
+}
@@ -0,0 +1,90 @@
+package conversion
+
+import (
+	"testing"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUnderlaysForNode(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node1",
+			Labels: map[string]string{"rack": "rack1"},
+		},
+	}
+	underlay := func(name string, selector *metav1.LabelSelector) v1alpha1.Underlay {
+		return v1alpha1.Underlay{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.UnderlaySpec{NodeSelector: selector},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		underlays   []v1alpha1.Underlay
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:      "no selector",
+			underlays: []v1alpha1.Underlay{underlay("all", nil)},
+			expected:  []string{"all"},
+		},
+		{
+			name: "matching and non matching selectors",
+			underlays: []v1alpha1.Underlay{
+				underlay("rack1", &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack1"}}),
+				underlay("rack2", &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack2"}}),
+			},
+			expected: []string{"rack1"},
+		},
+		{
+			name: "match expressions",
+			underlays: []v1alpha1.Underlay{
+				underlay("notrack2", &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "rack", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"rack2"}},
+				}}),
+			},
+			expected: []string{"notrack2"},
+		},
+		{
+			name: "invalid selector",
+			underlays: []v1alpha1.Underlay{
+				underlay("invalid", &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "rack", Operator: "foo"},
+				}}),
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := UnderlaysForNode(node, tc.underlays)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expecting error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+			names := []string{}
+			for _, u := range res {
+				names = append(names, u.Name)
+			}
+			if len(names) != len(tc.expected) {
+				t.Fatalf("expecting %v, got %v", tc.expected, names)
+			}
+			for i := range names {
+				if names[i] != tc.expected[i] {
+					t.Fatalf("expecting %v, got %v", tc.expected, names)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package conversion
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+)
+
+// mergeUnderlays merges the underlays applied to the same node into a
+// single underlay spec. The underlays must share the same asn and vtep cidr,
+// while their nics, neighbors and bfd profiles are added together.
+func mergeUnderlays(underlays []v1alpha1.Underlay) (v1alpha1.UnderlaySpec, error) {
+	if len(underlays) == 0 {
+		return v1alpha1.UnderlaySpec{}, fmt.Errorf("no underlays to merge")
+	}
+	first := underlays[0]
+	res := v1alpha1.UnderlaySpec{
+		ASN:      first.Spec.ASN,
+		VTEPCIDR: first.Spec.VTEPCIDR,
+	}
+	nics := map[string]string{}
+	neighbors := map[string]string{}
+	profiles := map[string]v1alpha1.BFDProfile{}
+	for _, u := range underlays {
+		if u.Spec.ASN != res.ASN {
+			return v1alpha1.UnderlaySpec{}, fmt.Errorf("underlays %s and %s have different asns %d and %d", first.Name, u.Name, res.ASN, u.Spec.ASN)
+		}
+		if u.Spec.VTEPCIDR != res.VTEPCIDR {
+			return v1alpha1.UnderlaySpec{}, fmt.Errorf("underlays %s and %s have different vtep cidrs %s and %s", first.Name, u.Name, res.VTEPCIDR, u.Spec.VTEPCIDR)
+		}
+		for _, n := range underlayNics(u.Spec) {
+			if other, ok := nics[n]; ok {
+				return v1alpha1.UnderlaySpec{}, fmt.Errorf("underlay %s: nic %s is already used by %s", u.Name, n, other)
+			}
+			nics[n] = u.Name
+			res.Nics = append(res.Nics, n)
+		}
+		for _, n := range u.Spec.Neighbors {
+			if other, ok := neighbors[n.Address]; ok {
+				return v1alpha1.UnderlaySpec{}, fmt.Errorf("underlay %s: neighbor %s is already defined by %s", u.Name, n.Address, other)
+			}
+			neighbors[n.Address] = u.Name
+			res.Neighbors = append(res.Neighbors, n)
+		}
+		for _, p := range u.Spec.BFDProfiles {
+			if other, ok := profiles[p.Name]; ok {
+				if !reflect.DeepEqual(other, p) {
+					return v1alpha1.UnderlaySpec{}, fmt.Errorf("underlay %s: bfd profile %s is defined with different values by another underlay", u.Name, p.Name)
+				}
+				continue
+			}
+			profiles[p.Name] = p
+			res.BFDProfiles = append(res.BFDProfiles, p)
+		}
+	}
+	return res, nil
+}
+
+// underlayNics returns the nics of the given underlay spec, including
+// the deprecated single nic.
+func underlayNics(spec v1alpha1.UnderlaySpec) []string {
+	if spec.Nic == "" {
+		return spec.Nics
+	}
+	return append([]string{spec.Nic}, spec.Nics...)
+}
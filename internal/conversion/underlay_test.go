@@ -0,0 +1,154 @@
+package conversion
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestMergeUnderlays(t *testing.T) {
+	underlay := func(name string, asn uint32, cidr string, nics []string, neighbors ...string) v1alpha1.Underlay {
+		res := v1alpha1.Underlay{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.UnderlaySpec{
+				ASN:      asn,
+				VTEPCIDR: cidr,
+				Nics:     nics,
+			},
+		}
+		for _, n := range neighbors {
+			res.Spec.Neighbors = append(res.Spec.Neighbors, v1alpha1.Neighbor{ASN: 64512, Address: n})
+		}
+		return res
+	}
+	withProfile := func(u v1alpha1.Underlay, p v1alpha1.BFDProfile) v1alpha1.Underlay {
+		u.Spec.BFDProfiles = append(u.Spec.BFDProfiles, p)
+		return u
+	}
+	withNic := func(u v1alpha1.Underlay, nic string) v1alpha1.Underlay {
+		u.Spec.Nic = nic
+		return u
+	}
+
+	tests := []struct {
+		name              string
+		underlays         []v1alpha1.Underlay
+		expectedNics      []string
+		expectedNeighbors []string
+		expectedProfiles  int
+		expectedErr       string
+	}{
+		{
+			name: "single underlay",
+			underlays: []v1alpha1.Underlay{
+				underlay("first", 64514, "100.65.0.0/24", []string{"eth1", "eth2"}, "192.168.11.2", "192.168.12.2"),
+			},
+			expectedNics:      []string{"eth1", "eth2"},
+			expectedNeighbors: []string{"192.168.11.2", "192.168.12.2"},
+		},
+		{
+			name: "two underlays",
+			underlays: []v1alpha1.Underlay{
+				underlay("first", 64514, "100.65.0.0/24", []string{"eth1"}, "192.168.11.2"),
+				underlay("second", 64514, "100.65.0.0/24", []string{"eth2"}, "192.168.12.2"),
+			},
+			expectedNics:      []string{"eth1", "eth2"},
+			expectedNeighbors: []string{"192.168.11.2", "192.168.12.2"},
+		},
+		{
+			name: "deprecated nic",
+			underlays: []v1alpha1.Underlay{
+				withNic(underlay("first", 64514, "100.65.0.0/24", nil, "192.168.11.2"), "eth1"),
+				underlay("second", 64514, "100.65.0.0/24", []string{"eth2"}, "192.168.12.2"),
+			},
+			expectedNics:      []string{"eth1", "eth2"},
+			expectedNeighbors: []string{"192.168.11.2", "192.168.12.2"},
+		},
+		{
+			name: "same bfd profile",
+			underlays: []v1alpha1.Underlay{
+				withProfile(underlay("first", 64514, "100.65.0.0/24", []string{"eth1"}, "192.168.11.2"),
+					v1alpha1.BFDProfile{Name: "fast", ReceiveInterval: ptr.To[uint32](100)}),
+				withProfile(underlay("second", 64514, "100.65.0.0/24", []string{"eth2"}, "192.168.12.2"),
+					v1alpha1.BFDProfile{Name: "fast", ReceiveInterval: ptr.To[uint32](100)}),
+			},
+			expectedNics:      []string{"eth1", "eth2"},
+			expectedNeighbors: []string{"192.168.11.2", "192.168.12.2"},
+			expectedProfiles:  1,
+		},
+		{
+			name: "conflicting bfd profile",
+			underlays: []v1alpha1.Underlay{
+				withProfile(underlay("first", 64514, "100.65.0.0/24", []string{"eth1"}, "192.168.11.2"),
+					v1alpha1.BFDProfile{Name: "fast", ReceiveInterval: ptr.To[uint32](100)}),
+				withProfile(underlay("second", 64514, "100.65.0.0/24", []string{"eth2"}, "192.168.12.2"),
+					v1alpha1.BFDProfile{Name: "fast", ReceiveInterval: ptr.To[uint32](200)}),
+			},
+			expectedErr: "different values",
+		},
+		{
+			name: "different asn",
+			underlays: []v1alpha1.Underlay{
+				underlay("first", 64514, "100.65.0.0/24", []string{"eth1"}, "192.168.11.2"),
+				underlay("second", 64515, "100.65.0.0/24", []string{"eth2"}, "192.168.12.2"),
+			},
+			expectedErr: "different asns",
+		},
+		{
+			name: "different vtep cidr",
+			underlays: []v1alpha1.Underlay{
+				underlay("first", 64514, "100.65.0.0/24", []string{"eth1"}, "192.168.11.2"),
+				underlay("second", 64514, "100.66.0.0/24", []string{"eth2"}, "192.168.12.2"),
+			},
+			expectedErr: "different vtep cidrs",
+		},
+		{
+			name: "same nic",
+			underlays: []v1alpha1.Underlay{
+				underlay("first", 64514, "100.65.0.0/24", []string{"eth1"}, "192.168.11.2"),
+				underlay("second", 64514, "100.65.0.0/24", []string{"eth1"}, "192.168.12.2"),
+			},
+			expectedErr: "nic eth1 is already used by first",
+		},
+		{
+			name: "same neighbor",
+			underlays: []v1alpha1.Underlay{
+				underlay("first", 64514, "100.65.0.0/24", []string{"eth1"}, "192.168.11.2"),
+				underlay("second", 64514, "100.65.0.0/24", []string{"eth2"}, "192.168.11.2"),
+			},
+			expectedErr: "neighbor 192.168.11.2 is already defined by first",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := mergeUnderlays(tc.underlays)
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("expecting error containing %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+			if !reflect.DeepEqual(res.Nics, tc.expectedNics) {
+				t.Fatalf("expecting nics %v, got %v", tc.expectedNics, res.Nics)
+			}
+			neighbors := []string{}
+			for _, n := range res.Neighbors {
+				neighbors = append(neighbors, n.Address)
+			}
+			if !reflect.DeepEqual(neighbors, tc.expectedNeighbors) {
+				t.Fatalf("expecting neighbors %v, got %v", tc.expectedNeighbors, neighbors)
+			}
+			if len(res.BFDProfiles) != tc.expectedProfiles {
+				t.Fatalf("expecting %d bfd profiles, got %v", tc.expectedProfiles, res.BFDProfiles)
+			}
+		})
+	}
+}
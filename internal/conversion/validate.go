@@ -0,0 +1,363 @@
+package conversion
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/hostnetwork"
+	"github.com/openperouter/openperouter/internal/ipam"
+	"github.com/openperouter/openperouter/internal/ipfamily"
+	v1 "k8s.io/api/core/v1"
+)
+
+// maxInterfaceNameLen is the maximum length of a linux interface name (IFNAMSIZ - 1).
+const maxInterfaceNameLen = 15
+
+const maxVNI = 1<<24 - 1
+
+// Validate checks that the given set of underlays, vnis and l2vnis is consistent
+// and that it can be applied to a cluster with the given nodes, whose allocated
+// indexes span the given number of indexes.
+// All the errors found are returned, joined, with at most one error per resource
+// for each check.
+func Validate(underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI, l2vnis []v1alpha1.L2VNI, nodes []v1.Node, indexes int) error {
+	// the nodes with no index yet are going to claim one
+	indexes = max(indexes, len(nodes))
+	return errors.Join(
+		validateUnderlays(underlays, indexes),
+		validateVNIs(vnis, indexes),
+		validateL2VNIs(l2vnis, vnis),
+		validateNodeSelection(underlays, vnis, l2vnis, nodes),
+		validateNoOverlap(underlays, vnis),
+	)
+}
+
+// validateUnderlays checks each underlay, given the number of node
+// indexes the vtep cidr must provide an address for.
+func validateUnderlays(underlays []v1alpha1.Underlay, indexes int) error {
+	errs := []error{}
+	for _, u := range underlays {
+		if err := validateUnderlay(u, indexes); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateUnderlay(u v1alpha1.Underlay, indexes int) error {
+	if u.Spec.Nic != "" && len(u.Spec.Nics) > 0 {
+		return fmt.Errorf("underlay %s: nic and nics can't be set together, nic is deprecated", u.Name)
+	}
+	underlayNics := underlayNics(u.Spec)
+	if len(underlayNics) == 0 {
+		return fmt.Errorf("underlay %s: at least one nic must be set", u.Name)
+	}
+	nics := map[string]bool{}
+	for _, n := range underlayNics {
+		if n == "" || len(n) > maxInterfaceNameLen {
+			return fmt.Errorf("underlay %s: invalid nic name %q", u.Name, n)
+		}
+		if nics[n] {
+			return fmt.Errorf("underlay %s: duplicate nic %s", u.Name, n)
+		}
+		nics[n] = true
+	}
+	if err := validateCIDRSize(u.Spec.VTEPCIDR, indexes); err != nil {
+		return fmt.Errorf("underlay %s: invalid vtep cidr: %w", u.Name, err)
+	}
+	profiles := map[string]bool{}
+	for _, p := range u.Spec.BFDProfiles {
+		if p.Name == "" {
+			return fmt.Errorf("underlay %s: bfd profile name must be set", u.Name)
+		}
+		if profiles[p.Name] {
+			return fmt.Errorf("underlay %s: duplicate bfd profile %s", u.Name, p.Name)
+		}
+		profiles[p.Name] = true
+	}
+	for _, n := range u.Spec.Neighbors {
+		if err := validateNeighbor(n); err != nil {
+			return fmt.Errorf("underlay %s: %w", u.Name, err)
+		}
+		if n.BFDProfile != "" && !profiles[n.BFDProfile] {
+			return fmt.Errorf("underlay %s: neighbor %s references bfd profile %s which is not defined", u.Name, neighborName(n), n.BFDProfile)
+		}
+	}
+	return nil
+}
+
+// validateNodeSelection checks that the node selectors are valid and
+// that the underlays selecting the same node can be merged together.
+func validateNodeSelection(underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI, l2vnis []v1alpha1.L2VNI, nodes []v1.Node) error {
+	errs := []error{}
+	for _, u := range underlays {
+		if _, err := selectsNode(u.Spec.NodeSelector, &v1.Node{}); err != nil {
+			errs = append(errs, fmt.Errorf("underlay %s: %w", u.Name, err))
+		}
+	}
+	for _, v := range vnis {
+		if _, err := selectsNode(v.Spec.NodeSelector, &v1.Node{}); err != nil {
+			errs = append(errs, fmt.Errorf("vni %s: %w", v.Name, err))
+		}
+	}
+	for _, v := range l2vnis {
+		if _, err := selectsNode(v.Spec.NodeSelector, &v1.Node{}); err != nil {
+			errs = append(errs, fmt.Errorf("l2vni %s: %w", v.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		// the selections can't be computed with invalid selectors
+		return errors.Join(errs...)
+	}
+	invalidL2VNIs := map[string]bool{}
+	for i := range nodes {
+		if err := validateL2VNIVRFs(&nodes[i], vnis, l2vnis, invalidL2VNIs); err != nil {
+			errs = append(errs, err)
+		}
+		selected, err := UnderlaysForNode(&nodes[i], underlays)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(selected) < 2 {
+			continue
+		}
+		if _, err := mergeUnderlays(selected); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", nodes[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateL2VNIVRFs checks that the vrfs of the l2vnis selecting the given
+// node are defined by a vni selecting it too, as the l2vnis are attached to
+// the vrfs of the node. The l2vnis in invalid are already reported, and the
+// ones reported here are added to it.
+func validateL2VNIVRFs(node *v1.Node, vnis []v1alpha1.VNI, l2vnis []v1alpha1.L2VNI, invalid map[string]bool) error {
+	selectedVNIs, err := VNIsForNode(node, vnis)
+	if err != nil {
+		return err
+	}
+	selectedL2VNIs, err := L2VNIsForNode(node, l2vnis)
+	if err != nil {
+		return err
+	}
+	definedVRFs := map[string]bool{}
+	for _, v := range vnis {
+		definedVRFs[v.Spec.VRF] = true
+	}
+	nodeVRFs := map[string]bool{}
+	for _, v := range selectedVNIs {
+		nodeVRFs[v.Spec.VRF] = true
+	}
+	errs := []error{}
+	for _, l := range selectedL2VNIs {
+		// the vrfs not defined at all are reported by validateL2VNI
+		if l.Spec.VRF == nil || !definedVRFs[*l.Spec.VRF] || nodeVRFs[*l.Spec.VRF] || invalid[l.Name] {
+			continue
+		}
+		invalid[l.Name] = true
+		errs = append(errs, fmt.Errorf("l2vni %s: vrf %s is not defined on node %s, no vni with it selects the node", l.Name, *l.Spec.VRF, node.Name))
+	}
+	return errors.Join(errs...)
+}
+
+func validateNeighbor(n v1alpha1.Neighbor) error {
+	if n.ASN == 0 {
+		return fmt.Errorf("neighbor %s does not have ASN", n.Address)
+	}
+	if net.ParseIP(n.Address) == nil {
+		return fmt.Errorf("neighbor %s: invalid address %s", neighborName(n), n.Address)
+	}
+	if n.Password != "" && n.PasswordSecret != "" {
+		return fmt.Errorf("neighbor %s specifies both cleartext password and secret ref", neighborName(n))
+	}
+	if _, _, err := parseTimers(n.HoldTime, n.KeepaliveTime); err != nil {
+		return fmt.Errorf("invalid timers for neighbor %s, err: %w", neighborName(n), err)
+	}
+	return nil
+}
+
+// validateVNIs checks each vni, given the number of node indexes the
+// local cidrs must provide an address for.
+func validateVNIs(vnis []v1alpha1.VNI, indexes int) error {
+	errs := []error{}
+	existingVNIs := map[uint32]string{}
+	existingVRFs := map[string]string{}
+	for _, vni := range vnis {
+		if err := validateVNI(vni, indexes, existingVNIs, existingVRFs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateVNI checks the given vni, tracking its vni id and its vrf
+// in the given maps to detect the duplicates.
+func validateVNI(vni v1alpha1.VNI, indexes int, existingVNIs map[uint32]string, existingVRFs map[string]string) error {
+	if vni.Spec.VNI == 0 || vni.Spec.VNI > maxVNI {
+		return fmt.Errorf("vni %s: invalid vni %d, must be between 1 and %d", vni.Name, vni.Spec.VNI, maxVNI)
+	}
+	if other, ok := existingVNIs[vni.Spec.VNI]; ok {
+		return fmt.Errorf("vni %s: duplicate vni %d, already used by %s", vni.Name, vni.Spec.VNI, other)
+	}
+	existingVNIs[vni.Spec.VNI] = vni.Name
+
+	if err := validateVRFName(vni.Spec.VRF); err != nil {
+		return fmt.Errorf("vni %s: %w", vni.Name, err)
+	}
+	if other, ok := existingVRFs[vni.Spec.VRF]; ok {
+		return fmt.Errorf("vni %s: duplicate vrf %s, already used by %s", vni.Name, vni.Spec.VRF, other)
+	}
+	existingVRFs[vni.Spec.VRF] = vni.Name
+
+	if err := validateLocalCIDR(vni.Spec.LocalCIDR, vni.Spec.LocalCIDRV6, indexes); err != nil {
+		return fmt.Errorf("vni %s: %w", vni.Name, err)
+	}
+	return nil
+}
+
+// validateL2VNIs checks the given l2vnis. The vni ids must be unique
+// across both the l2vnis and the vnis, and the vrf an l2vni is
+// tied to must be the vrf of one of the vnis.
+func validateL2VNIs(l2vnis []v1alpha1.L2VNI, vnis []v1alpha1.VNI) error {
+	existingVNIs := map[uint32]string{}
+	existingVRFs := map[string]bool{}
+	for _, vni := range vnis {
+		existingVNIs[vni.Spec.VNI] = "vni " + vni.Name
+		existingVRFs[vni.Spec.VRF] = true
+	}
+	errs := []error{}
+	for _, l2vni := range l2vnis {
+		if err := validateL2VNI(l2vni, existingVNIs, existingVRFs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateL2VNI checks the given l2vni, tracking its vni id in the
+// given map to detect the duplicates.
+func validateL2VNI(l2vni v1alpha1.L2VNI, existingVNIs map[uint32]string, existingVRFs map[string]bool) error {
+	if l2vni.Spec.VNI == 0 || l2vni.Spec.VNI > maxVNI {
+		return fmt.Errorf("l2vni %s: invalid vni %d, must be between 1 and %d", l2vni.Name, l2vni.Spec.VNI, maxVNI)
+	}
+	if other, ok := existingVNIs[l2vni.Spec.VNI]; ok {
+		return fmt.Errorf("l2vni %s: duplicate vni %d, already used by %s", l2vni.Name, l2vni.Spec.VNI, other)
+	}
+	existingVNIs[l2vni.Spec.VNI] = "l2vni " + l2vni.Name
+
+	if l2vni.Spec.VRF != nil && !existingVRFs[*l2vni.Spec.VRF] {
+		return fmt.Errorf("l2vni %s: vrf %s is not defined by any vni", l2vni.Name, *l2vni.Spec.VRF)
+	}
+	if l2vni.Spec.HostMaster != nil {
+		if l2vni.Spec.HostMaster.Name == "" {
+			return fmt.Errorf("l2vni %s: host master name must be set", l2vni.Name)
+		}
+		if len(l2vni.Spec.HostMaster.Name) > maxInterfaceNameLen {
+			return fmt.Errorf("l2vni %s: host master %s exceeds %d characters", l2vni.Name, l2vni.Spec.HostMaster.Name, maxInterfaceNameLen)
+		}
+	}
+	return nil
+}
+
+func validateLocalCIDR(ipv4, ipv6 string, indexes int) error {
+	if ipv4 == "" && ipv6 == "" {
+		return fmt.Errorf("at least one local cidr must be set")
+	}
+	for _, c := range []struct {
+		cidr   string
+		family ipfamily.Family
+	}{
+		{ipv4, ipfamily.IPv4},
+		{ipv6, ipfamily.IPv6},
+	} {
+		if c.cidr == "" {
+			continue
+		}
+		// the first ip of the local cidr is assigned to the router side of the veth
+		if err := validateCIDRSize(c.cidr, indexes+1); err != nil {
+			return fmt.Errorf("invalid local cidr: %w", err)
+		}
+		if ipfamily.ForCIDRString(c.cidr) != c.family {
+			return fmt.Errorf("invalid local cidr: %s is not %s", c.cidr, c.family)
+		}
+	}
+	return nil
+}
+
+func validateVRFName(vrf string) error {
+	if vrf == "" {
+		return fmt.Errorf("vrf must be set")
+	}
+	hostVeth := hostnetwork.HostVethPrefix + vrf
+	if len(hostVeth) > maxInterfaceNameLen {
+		return fmt.Errorf("vrf %s is too long, the host side veth %s exceeds %d characters", vrf, hostVeth, maxInterfaceNameLen)
+	}
+	return nil
+}
+
+// validateCIDRSize checks that the given cidr is valid and can provide
+// at least the given number of addresses.
+func validateCIDRSize(cidr string, addresses int) error {
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return fmt.Errorf("failed to parse cidr %s: %w", cidr, err)
+	}
+	available, err := ipam.IPsInCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	if available < uint64(addresses) {
+		return fmt.Errorf("cidr %s has %d addresses, at least %d are required", cidr, available, addresses)
+	}
+	return nil
+}
+
+func validateNoOverlap(underlays []v1alpha1.Underlay, vnis []v1alpha1.VNI) error {
+	type namedCIDR struct {
+		owner string
+		cidr  *net.IPNet
+	}
+	errs := []error{}
+	cidrs := []namedCIDR{}
+	vtepCIDRs := map[string]bool{}
+	for _, u := range underlays {
+		// the underlays applied to the same nodes share the same vtep cidr
+		if vtepCIDRs[u.Spec.VTEPCIDR] {
+			continue
+		}
+		vtepCIDRs[u.Spec.VTEPCIDR] = true
+		_, cidr, err := net.ParseCIDR(u.Spec.VTEPCIDR)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("underlay %s: failed to parse cidr %s: %w", u.Name, u.Spec.VTEPCIDR, err))
+			continue
+		}
+		cidrs = append(cidrs, namedCIDR{owner: "underlay " + u.Name, cidr: cidr})
+	}
+	for _, v := range vnis {
+		for _, c := range localCIDRs(v) {
+			_, cidr, err := net.ParseCIDR(c)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("vni %s: failed to parse cidr %s: %w", v.Name, c, err))
+				continue
+			}
+			cidrs = append(cidrs, namedCIDR{owner: "vni " + v.Name, cidr: cidr})
+		}
+	}
+
+	for i := range cidrs {
+		for j := i + 1; j < len(cidrs); j++ {
+			if cidrsOverlap(cidrs[i].cidr, cidrs[j].cidr) {
+				errs = append(errs, fmt.Errorf("cidr %s of %s overlaps with cidr %s of %s",
+					cidrs[i].cidr, cidrs[i].owner, cidrs[j].cidr, cidrs[j].owner))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
@@ -0,0 +1,567 @@
+package conversion
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestValidate(t *testing.T) {
+	underlay := func(mutate func(*v1alpha1.Underlay)) v1alpha1.Underlay {
+		res := v1alpha1.Underlay{
+			ObjectMeta: metav1.ObjectMeta{Name: "underlay"},
+			Spec: v1alpha1.UnderlaySpec{
+				ASN:      64514,
+				VTEPCIDR: "100.65.0.0/24",
+				Nics:     []string{"eth1"},
+				Neighbors: []v1alpha1.Neighbor{
+					{ASN: 64512, Address: "192.168.11.2"},
+				},
+			},
+		}
+		if mutate != nil {
+			mutate(&res)
+		}
+		return res
+	}
+	nodes := func(count int) []v1.Node {
+		res := []v1.Node{}
+		for i := 0; i < count; i++ {
+			res = append(res, v1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   fmt.Sprintf("node%d", i),
+					Labels: map[string]string{"rack": fmt.Sprintf("rack%d", i%2)},
+				},
+			})
+		}
+		return res
+	}
+	vni := func(name, vrf string, id uint32, cidr string) v1alpha1.VNI {
+		return v1alpha1.VNI{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.VNISpec{
+				ASN:       64514,
+				VRF:       vrf,
+				VNI:       id,
+				LocalCIDR: cidr,
+				LocalASN:  64515,
+			},
+		}
+	}
+
+	l2vni := func(name string, id uint32, vrf string) v1alpha1.L2VNI {
+		res := v1alpha1.L2VNI{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.L2VNISpec{
+				VNI: id,
+			},
+		}
+		if vrf != "" {
+			res.Spec.VRF = ptr.To(vrf)
+		}
+		return res
+	}
+
+	tests := []struct {
+		name        string
+		underlays   []v1alpha1.Underlay
+		vnis        []v1alpha1.VNI
+		l2vnis      []v1alpha1.L2VNI
+		nodes       []v1.Node
+		indexes     int
+		expectedErr string
+	}{
+		{
+			name:      "valid",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/24"),
+				vni("blue", "blue", 101, "192.169.11.0/24"),
+			},
+			nodes: nodes(3),
+		},
+		{
+			name: "multiple underlays with different nics",
+			underlays: []v1alpha1.Underlay{
+				underlay(nil),
+				underlay(func(u *v1alpha1.Underlay) {
+					u.Name = "second"
+					u.Spec.Nics = []string{"eth2"}
+					u.Spec.Neighbors = []v1alpha1.Neighbor{{ASN: 64513, Address: "192.168.12.2"}}
+				}),
+			},
+			nodes: nodes(3),
+		},
+		{
+			name: "multiple underlays with the same nic",
+			underlays: []v1alpha1.Underlay{
+				underlay(nil),
+				underlay(func(u *v1alpha1.Underlay) {
+					u.Name = "second"
+					u.Spec.Neighbors = []v1alpha1.Neighbor{{ASN: 64513, Address: "192.168.12.2"}}
+				}),
+			},
+			nodes:       nodes(3),
+			expectedErr: "nic eth1 is already used by underlay",
+		},
+		{
+			name: "multiple nics",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.Nics = []string{"eth1", "eth2"}
+			})},
+			nodes: nodes(3),
+		},
+		{
+			name: "deprecated nic",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.Nics = nil
+				u.Spec.Nic = "eth1"
+			})},
+			nodes: nodes(3),
+		},
+		{
+			name: "deprecated nic and nics",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.Nic = "eth2"
+			})},
+			nodes:       nodes(3),
+			expectedErr: "nic and nics can't be set together",
+		},
+		{
+			name: "duplicate nics",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.Nics = []string{"eth1", "eth1"}
+			})},
+			nodes:       nodes(3),
+			expectedErr: "duplicate nic",
+		},
+		{
+			name: "underlays selecting different nodes",
+			underlays: []v1alpha1.Underlay{
+				underlay(func(u *v1alpha1.Underlay) {
+					u.Name = "rack0"
+					u.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack0"}}
+				}),
+				underlay(func(u *v1alpha1.Underlay) {
+					u.Name = "rack1"
+					u.Spec.VTEPCIDR = "100.66.0.0/24"
+					u.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack1"}}
+				}),
+			},
+			nodes: nodes(3),
+		},
+		{
+			name: "underlays selecting the same node",
+			underlays: []v1alpha1.Underlay{
+				underlay(func(u *v1alpha1.Underlay) {
+					u.Name = "rack0"
+					u.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack0"}}
+				}),
+				underlay(func(u *v1alpha1.Underlay) {
+					u.Name = "all"
+					u.Spec.VTEPCIDR = "100.66.0.0/24"
+					u.Spec.Nics = []string{"eth2"}
+				}),
+			},
+			nodes:       nodes(3),
+			expectedErr: "node node0: underlays rack0 and all have different vtep cidrs",
+		},
+		{
+			name:      "invalid node selector",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				func() v1alpha1.VNI {
+					res := vni("red", "red", 100, "192.169.10.0/24")
+					res.Spec.NodeSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "rack", Operator: "foo"},
+					}}
+					return res
+				}(),
+			},
+			nodes:       nodes(3),
+			expectedErr: "invalid node selector",
+		},
+		{
+			name: "invalid vtep cidr",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.VTEPCIDR = "100.65.0.0/33"
+			})},
+			nodes:       nodes(3),
+			expectedErr: "invalid vtep cidr",
+		},
+		{
+			name: "vtep cidr too small",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.VTEPCIDR = "100.65.0.0/31"
+			})},
+			nodes:       nodes(3),
+			expectedErr: "at least 3 are required",
+		},
+		{
+			name: "missing nic",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.Nics = nil
+			})},
+			nodes:       nodes(3),
+			expectedErr: "nic must be set",
+		},
+		{
+			name: "invalid timers",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.Neighbors[0].HoldTime = &metav1.Duration{Duration: 10 * time.Second}
+			})},
+			nodes:       nodes(3),
+			expectedErr: "invalid timers",
+		},
+		{
+			name: "keepalive higher than hold time",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.Neighbors[0].HoldTime = &metav1.Duration{Duration: 10 * time.Second}
+				u.Spec.Neighbors[0].KeepaliveTime = &metav1.Duration{Duration: 20 * time.Second}
+			})},
+			nodes:       nodes(3),
+			expectedErr: "invalid keepaliveTime",
+		},
+		{
+			name: "neighbor without asn",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.Neighbors[0].ASN = 0
+			})},
+			nodes:       nodes(3),
+			expectedErr: "does not have ASN",
+		},
+		{
+			name: "invalid neighbor address",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.Neighbors[0].Address = "192.168.11"
+			})},
+			nodes:       nodes(3),
+			expectedErr: "invalid address",
+		},
+		{
+			name: "valid bfd profile",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.BFDProfiles = []v1alpha1.BFDProfile{{Name: "fast"}}
+				u.Spec.Neighbors[0].BFDProfile = "fast"
+			})},
+			nodes: nodes(3),
+		},
+		{
+			name: "missing bfd profile",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.BFDProfiles = []v1alpha1.BFDProfile{{Name: "fast"}}
+				u.Spec.Neighbors[0].BFDProfile = "slow"
+			})},
+			nodes:       nodes(3),
+			expectedErr: "bfd profile slow which is not defined",
+		},
+		{
+			name: "duplicate bfd profile",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.BFDProfiles = []v1alpha1.BFDProfile{{Name: "fast"}, {Name: "fast"}}
+			})},
+			nodes:       nodes(3),
+			expectedErr: "duplicate bfd profile",
+		},
+		{
+			name:      "duplicate vni",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/24"),
+				vni("blue", "blue", 100, "192.169.11.0/24"),
+			},
+			nodes:       nodes(3),
+			expectedErr: "duplicate vni",
+		},
+		{
+			name:      "duplicate vrf",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/24"),
+				vni("blue", "red", 101, "192.169.11.0/24"),
+			},
+			nodes:       nodes(3),
+			expectedErr: "duplicate vrf",
+		},
+		{
+			name:      "vrf too long",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "averyverylongvrf", 100, "192.169.10.0/24"),
+			},
+			nodes:       nodes(3),
+			expectedErr: "is too long",
+		},
+		{
+			name:      "invalid vni",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 1<<24, "192.169.10.0/24"),
+			},
+			nodes:       nodes(3),
+			expectedErr: "invalid vni",
+		},
+		{
+			name:      "local cidr too small",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/30"),
+			},
+			nodes:       nodes(4),
+			expectedErr: "at least 5 are required",
+		},
+		{
+			name:      "dual stack local cidr",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				func() v1alpha1.VNI {
+					res := vni("red", "red", 100, "192.169.10.0/24")
+					res.Spec.LocalCIDRV6 = "2001:db8:10::/64"
+					return res
+				}(),
+			},
+			nodes: nodes(3),
+		},
+		{
+			name:      "ipv6 only local cidr",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				func() v1alpha1.VNI {
+					res := vni("red", "red", 100, "")
+					res.Spec.LocalCIDRV6 = "2001:db8:10::/64"
+					return res
+				}(),
+			},
+			nodes: nodes(3),
+		},
+		{
+			name:      "no local cidr",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, ""),
+			},
+			nodes:       nodes(3),
+			expectedErr: "at least one local cidr must be set",
+		},
+		{
+			name:      "ipv6 cidr as ipv4 local cidr",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "2001:db8:10::/64"),
+			},
+			nodes:       nodes(3),
+			expectedErr: "is not ipv4",
+		},
+		{
+			name:      "overlapping ipv6 local cidrs",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				func() v1alpha1.VNI {
+					res := vni("red", "red", 100, "192.169.10.0/24")
+					res.Spec.LocalCIDRV6 = "2001:db8:10::/64"
+					return res
+				}(),
+				func() v1alpha1.VNI {
+					res := vni("blue", "blue", 101, "192.169.11.0/24")
+					res.Spec.LocalCIDRV6 = "2001:db8::/32"
+					return res
+				}(),
+			},
+			nodes:       nodes(3),
+			expectedErr: "overlaps",
+		},
+		{
+			name:      "overlapping local cidrs",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/24"),
+				vni("blue", "blue", 101, "192.169.0.0/16"),
+			},
+			nodes:       nodes(3),
+			expectedErr: "overlaps",
+		},
+		{
+			name:      "local cidr overlapping with vtep cidr",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "100.65.0.128/25"),
+			},
+			nodes:       nodes(3),
+			expectedErr: "overlaps",
+		},
+		{
+			name:      "valid l2vnis",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/24"),
+			},
+			l2vnis: []v1alpha1.L2VNI{
+				l2vni("l2red", 110, "red"),
+				l2vni("l2", 111, ""),
+			},
+			nodes: nodes(3),
+		},
+		{
+			name:      "l2vni only",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			l2vnis: []v1alpha1.L2VNI{
+				l2vni("l2", 111, ""),
+			},
+			nodes: nodes(3),
+		},
+		{
+			name:      "l2vni with the same vni of a vni",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/24"),
+			},
+			l2vnis: []v1alpha1.L2VNI{
+				l2vni("l2", 100, ""),
+			},
+			nodes:       nodes(3),
+			expectedErr: "duplicate vni",
+		},
+		{
+			name:      "duplicate l2vni",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			l2vnis: []v1alpha1.L2VNI{
+				l2vni("l2", 110, ""),
+				l2vni("l2bis", 110, ""),
+			},
+			nodes:       nodes(3),
+			expectedErr: "duplicate vni",
+		},
+		{
+			name:      "l2vni with undefined vrf",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/24"),
+			},
+			l2vnis: []v1alpha1.L2VNI{
+				l2vni("l2", 110, "blue"),
+			},
+			nodes:       nodes(3),
+			expectedErr: "is not defined",
+		},
+		{
+			name:      "l2vni with a vrf selecting the same nodes",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				func() v1alpha1.VNI {
+					res := vni("red", "red", 100, "192.169.10.0/24")
+					res.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack0"}}
+					return res
+				}(),
+			},
+			l2vnis: []v1alpha1.L2VNI{
+				func() v1alpha1.L2VNI {
+					res := l2vni("l2", 110, "red")
+					res.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack0"}}
+					return res
+				}(),
+			},
+			nodes: nodes(3),
+		},
+		{
+			name:      "l2vni with a vrf not on all its nodes",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				func() v1alpha1.VNI {
+					res := vni("red", "red", 100, "192.169.10.0/24")
+					res.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack0"}}
+					return res
+				}(),
+			},
+			l2vnis: []v1alpha1.L2VNI{
+				l2vni("l2", 110, "red"),
+			},
+			nodes:       nodes(3),
+			expectedErr: "l2vni l2: vrf red is not defined on node node1",
+		},
+		{
+			name:      "l2vni with invalid vni",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			l2vnis: []v1alpha1.L2VNI{
+				l2vni("l2", 0, ""),
+			},
+			nodes:       nodes(3),
+			expectedErr: "invalid vni",
+		},
+		{
+			name:      "l2vni with too long host master",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			l2vnis: []v1alpha1.L2VNI{
+				func() v1alpha1.L2VNI {
+					res := l2vni("l2", 110, "")
+					res.Spec.HostMaster = &v1alpha1.HostMaster{Name: "averyveryverylongbridge"}
+					return res
+				}(),
+			},
+			nodes:       nodes(3),
+			expectedErr: "exceeds",
+		},
+		{
+			name: "vtep cidr too small for the allocated indexes",
+			underlays: []v1alpha1.Underlay{underlay(func(u *v1alpha1.Underlay) {
+				u.Spec.VTEPCIDR = "100.65.0.0/30"
+			})},
+			nodes:       nodes(3),
+			indexes:     5,
+			expectedErr: "at least 5 are required",
+		},
+		{
+			name:      "local cidr too small for the allocated indexes",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/29"),
+			},
+			nodes:       nodes(3),
+			indexes:     8,
+			expectedErr: "at least 9 are required",
+		},
+		{
+			name:      "local cidr sized on the nodes with no index yet",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 100, "192.169.10.0/30"),
+			},
+			nodes:       nodes(4),
+			indexes:     2,
+			expectedErr: "at least 5 are required",
+		},
+		{
+			name:      "errors of different resources reported together",
+			underlays: []v1alpha1.Underlay{underlay(nil)},
+			vnis: []v1alpha1.VNI{
+				vni("red", "red", 0, "192.169.10.0/24"),
+				vni("blue", "blue", 101, ""),
+			},
+			nodes:       nodes(3),
+			expectedErr: "vni red: invalid vni 0, must be between 1 and 16777215\nvni blue: at least one local cidr must be set",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.underlays, tc.vnis, tc.l2vnis, tc.nodes, tc.indexes)
+			if tc.expectedErr == "" && err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+			if tc.expectedErr == "" {
+				return
+			}
+			if err == nil {
+				t.Fatalf("expecting error containing %q, got nil", tc.expectedErr)
+			}
+			if !strings.Contains(err.Error(), tc.expectedErr) {
+				t.Fatalf("expecting error containing %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
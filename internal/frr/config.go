@@ -20,24 +20,27 @@ var (
 )
 
 type Config struct {
-	Loglevel string
-	Hostname string
-	Underlay UnderlayConfig
-	VNIs     []VNIConfig
+	Loglevel    string
+	Hostname    string
+	Underlay    UnderlayConfig
+	VNIs        []VNIConfig
+	BFDProfiles []BFDProfile
 }
 
 type UnderlayConfig struct {
 	MyASN     uint32
 	VTEP      string
 	Neighbors []NeighborConfig
+	// MaximumPaths is the number of paths used for ecmp, if set.
+	MaximumPaths int
 }
 
 type VNIConfig struct {
-	ASN           uint32
-	ToAdvertise   []string
-	LocalNeighbor *NeighborConfig
-	VRF           string
-	VNI           int
+	ASN            uint32
+	ToAdvertise    []string
+	LocalNeighbors []NeighborConfig
+	VRF            string
+	VNI            int
 }
 
 type BFDProfile struct {
@@ -69,6 +72,32 @@ func (n *NeighborConfig) ID() string {
 	return n.Addr
 }
 
+// redactedConfig is a Config logged with its passwords hidden.
+type redactedConfig Config
+
+// LogValue implements slog.LogValuer, hiding the passwords of the neighbors.
+func (c Config) LogValue() slog.Value {
+	res := redactedConfig(c)
+	res.Underlay.Neighbors = redactPasswords(c.Underlay.Neighbors)
+	res.VNIs = make([]VNIConfig, len(c.VNIs))
+	for i, v := range c.VNIs {
+		v.LocalNeighbors = redactPasswords(v.LocalNeighbors)
+		res.VNIs[i] = v
+	}
+	return slog.AnyValue(res)
+}
+
+func redactPasswords(neighbors []NeighborConfig) []NeighborConfig {
+	res := make([]NeighborConfig, len(neighbors))
+	for i, n := range neighbors {
+		if n.Password != "" {
+			n.Password = "<redacted>"
+		}
+		res[i] = n
+	}
+	return res
+}
+
 // templateConfig uses the template library to template
 // 'globalConfigTemplate' using 'data'.
 func templateConfig(data interface{}) (string, error) {
@@ -105,6 +134,7 @@ func templateConfig(data interface{}) (string, error) {
 			"activateNeighborFor": func(ipFamily string, neighbourFamily ipfamily.Family) bool {
 				return string(neighbourFamily) == ipFamily
 			},
+			"familyForCIDR": ipfamily.ForCIDRString,
 		}).ParseFS(templates, "templates/*")
 	if err != nil {
 		return "", err
@@ -96,6 +96,11 @@ func testFileIsValid(fileName string) error {
 	if testing.Short() {
 		return nil
 	}
+	return testFRRReload(fileName, "--test")
+}
+
+// testFRRReload runs frr-reload.py in the given mode against the given file.
+func testFRRReload(fileName, mode string) error {
 	cmd := exec.Command("cp", fileName, filepath.Join(frrDir, "frr.conf"))
 	res, err := cmd.CombinedOutput()
 	if err != nil {
@@ -107,7 +112,7 @@ func testFileIsValid(fileName string) error {
 		return errors.Join(err, errors.New("failed to copy frr.conf inside the container"))
 	}
 	buf := new(bytes.Buffer)
-	code, err := containerHandle.Exec([]string{"python3", "/usr/lib/frr/frr-reload.py", "--test", "--stdout", "/etc/frr/frr.conf"},
+	code, err := containerHandle.Exec([]string{"python3", "/usr/lib/frr/frr-reload.py", mode, "--stdout", "/etc/frr/frr.conf"},
 		dockertest.ExecOptions{
 			StdErr: buf,
 		})
@@ -132,3 +137,49 @@ func TestDockerFRRFails(t *testing.T) {
 		t.Fatalf("Validity check of invalid file passed")
 	}
 }
+
+// TestRunningConfigs applies the configurations of the golden tests to FRR
+// and checks that its running configuration is the one the native applier
+// of the frrconfig package is tested against. Run it with -update to
+// refresh them.
+func TestRunningConfigs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping FRR integration")
+	}
+
+	for _, name := range []string{
+		"TestBasic",
+		"TestBFDProfiles",
+		"TestDualStackVNI",
+		"TestEmpty",
+		"TestIPv6VTEP",
+		"TestMultipleUnderlayPaths",
+		"TestNeighborPassword",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := testFRRReload(filepath.Join(testData, name+".golden"), "--reload"); err != nil {
+				t.Fatalf("failed to apply %s: %v", name, err)
+			}
+			buf := new(bytes.Buffer)
+			code, err := containerHandle.Exec([]string{"vtysh", "-c", "show running-config"},
+				dockertest.ExecOptions{StdOut: buf})
+			if err != nil || code != 0 {
+				t.Fatalf("failed to get the running config - res %d %v", code, err)
+			}
+
+			runningFile := filepath.Join("..", "frrconfig", "testdata", name+".running")
+			if *update {
+				if err := os.WriteFile(runningFile, buf.Bytes(), 0600); err != nil {
+					t.Fatalf("failed to update %s: %v", runningFile, err)
+				}
+			}
+			expected, err := os.ReadFile(runningFile)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", runningFile, err)
+			}
+			if buf.String() != string(expected) {
+				t.Fatalf("unexpected running config for %s, got:\n%s", name, buf.String())
+			}
+		})
+	}
+}
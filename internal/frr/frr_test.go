@@ -3,9 +3,11 @@
 package frr
 
 import (
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,6 +17,7 @@ import (
 
 	"github.com/openperouter/openperouter/internal/ipfamily"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/ptr"
 )
 
 const testData = "testdata/"
@@ -42,16 +45,285 @@ func TestBasic(t *testing.T) {
 				VRF: "red",
 				ASN: 64512,
 				VNI: 100,
-				LocalNeighbor: &NeighborConfig{
+				LocalNeighbors: []NeighborConfig{
+					{
+						ASN:      64512,
+						Addr:     "192.168.1.2",
+						IPFamily: ipfamily.IPv4,
+					},
+				},
+				ToAdvertise: []string{
+					"192.169.10.2/24",
+				},
+			},
+		},
+	}
+	if err := ApplyConfig(context.TODO(), &config, updater); err != nil {
+		t.Fatalf("Failed to apply config: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestNeighborPassword(t *testing.T) {
+	configFile := testSetup(t)
+	updater := testUpdater(configFile)
+
+	config := Config{
+		Underlay: UnderlayConfig{
+			MyASN: 64512,
+			VTEP:  "100.64.0.1/32",
+			Neighbors: []NeighborConfig{
+				{
 					ASN:      64512,
 					Addr:     "192.168.1.2",
 					IPFamily: ipfamily.IPv4,
+					Password: "secret",
+				},
+			},
+		},
+		VNIs: []VNIConfig{
+			{
+				VRF: "red",
+				ASN: 64512,
+				VNI: 100,
+				LocalNeighbors: []NeighborConfig{
+					{
+						ASN:      64512,
+						Addr:     "192.168.1.2",
+						IPFamily: ipfamily.IPv4,
+					},
+				},
+				ToAdvertise: []string{
+					"192.169.10.2/24",
+				},
+			},
+		},
+	}
+	if err := ApplyConfig(context.TODO(), &config, updater); err != nil {
+		t.Fatalf("Failed to apply config: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestConfigLogValue(t *testing.T) {
+	config := &Config{
+		Underlay: UnderlayConfig{
+			Neighbors: []NeighborConfig{{Addr: "192.168.1.2", Password: "topsecret"}},
+		},
+		VNIs: []VNIConfig{{
+			VRF:            "red",
+			LocalNeighbors: []NeighborConfig{{Addr: "192.169.10.0", Password: "vnisecret"}},
+		}},
+	}
+	var b bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger.Debug("frr generate config", "config", *config)
+	logger.Error("failed to write frr config", "config", config)
+
+	logged := b.String()
+	if strings.Contains(logged, "topsecret") || strings.Contains(logged, "vnisecret") {
+		t.Fatalf("expected the passwords not to be logged, got %s", logged)
+	}
+	if !strings.Contains(logged, "192.169.10.0") {
+		t.Fatalf("expected the neighbors to be logged, got %s", logged)
+	}
+	if config.Underlay.Neighbors[0].Password != "topsecret" || config.VNIs[0].LocalNeighbors[0].Password != "vnisecret" {
+		t.Fatalf("expected the config not to be changed")
+	}
+}
+
+func TestBFDProfiles(t *testing.T) {
+	configFile := testSetup(t)
+	updater := testUpdater(configFile)
+
+	config := Config{
+		Underlay: UnderlayConfig{
+			MyASN: 64512,
+			VTEP:  "100.64.0.1/32",
+			Neighbors: []NeighborConfig{
+				{
+					ASN:        64512,
+					Addr:       "192.168.1.2",
+					IPFamily:   ipfamily.IPv4,
+					BFDProfile: "fast",
+				},
+				{
+					ASN:        64512,
+					Addr:       "192.168.1.3",
+					IPFamily:   ipfamily.IPv4,
+					BFDProfile: "defaults",
+				},
+			},
+		},
+		VNIs: []VNIConfig{
+			{
+				VRF: "red",
+				ASN: 64512,
+				VNI: 100,
+				LocalNeighbors: []NeighborConfig{
+					{
+						ASN:      64512,
+						Addr:     "192.168.1.2",
+						IPFamily: ipfamily.IPv4,
+					},
 				},
 				ToAdvertise: []string{
 					"192.169.10.2/24",
 				},
 			},
 		},
+		BFDProfiles: []BFDProfile{
+			{
+				Name:             "fast",
+				ReceiveInterval:  ptr.To[uint32](100),
+				TransmitInterval: ptr.To[uint32](100),
+				DetectMultiplier: ptr.To[uint32](3),
+				EchoInterval:     ptr.To[uint32](50),
+				EchoMode:         true,
+				PassiveMode:      true,
+				MinimumTTL:       ptr.To[uint32](254),
+			},
+			{
+				Name: "defaults",
+			},
+		},
+	}
+	if err := ApplyConfig(context.TODO(), &config, updater); err != nil {
+		t.Fatalf("Failed to apply config: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestIPv6VTEP(t *testing.T) {
+	configFile := testSetup(t)
+	updater := testUpdater(configFile)
+
+	config := Config{
+		Underlay: UnderlayConfig{
+			MyASN: 64512,
+			VTEP:  "2001:db8::1/128",
+			Neighbors: []NeighborConfig{
+				{
+					ASN:      64512,
+					Addr:     "2001:db8:1::2",
+					IPFamily: ipfamily.IPv6,
+				},
+			},
+		},
+		VNIs: []VNIConfig{
+			{
+				VRF: "red",
+				ASN: 64512,
+				VNI: 100,
+				LocalNeighbors: []NeighborConfig{
+					{
+						ASN:      64512,
+						Addr:     "192.168.1.2",
+						IPFamily: ipfamily.IPv4,
+					},
+				},
+				ToAdvertise: []string{
+					"192.169.10.2/24",
+				},
+			},
+		},
+	}
+	if err := ApplyConfig(context.TODO(), &config, updater); err != nil {
+		t.Fatalf("Failed to apply config: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestDualStackVNI(t *testing.T) {
+	configFile := testSetup(t)
+	updater := testUpdater(configFile)
+
+	config := Config{
+		Underlay: UnderlayConfig{
+			MyASN: 64512,
+			VTEP:  "100.64.0.1/32",
+			Neighbors: []NeighborConfig{
+				{
+					ASN:      64512,
+					Addr:     "192.168.1.2",
+					IPFamily: ipfamily.IPv4,
+				},
+			},
+		},
+		VNIs: []VNIConfig{
+			{
+				VRF: "red",
+				ASN: 64512,
+				VNI: 100,
+				LocalNeighbors: []NeighborConfig{
+					{
+						ASN:      64515,
+						Addr:     "192.169.10.2",
+						IPFamily: ipfamily.IPv4,
+					},
+					{
+						ASN:      64515,
+						Addr:     "2001:db8:10::2",
+						IPFamily: ipfamily.IPv6,
+					},
+				},
+				ToAdvertise: []string{
+					"192.169.10.2/32",
+					"2001:db8:10::2/128",
+				},
+			},
+		},
+	}
+	if err := ApplyConfig(context.TODO(), &config, updater); err != nil {
+		t.Fatalf("Failed to apply config: %s", err)
+	}
+
+	testCheckConfigFile(t)
+}
+
+func TestMultipleUnderlayPaths(t *testing.T) {
+	configFile := testSetup(t)
+	updater := testUpdater(configFile)
+
+	config := Config{
+		Underlay: UnderlayConfig{
+			MyASN: 64514,
+			VTEP:  "100.64.0.1/32",
+			Neighbors: []NeighborConfig{
+				{
+					ASN:      64512,
+					Addr:     "192.168.11.2",
+					IPFamily: ipfamily.IPv4,
+				},
+				{
+					ASN:      64513,
+					Addr:     "192.168.12.2",
+					IPFamily: ipfamily.IPv4,
+				},
+			},
+			MaximumPaths: 2,
+		},
+		VNIs: []VNIConfig{
+			{
+				VRF: "red",
+				ASN: 64514,
+				VNI: 100,
+				LocalNeighbors: []NeighborConfig{
+					{
+						ASN:      64515,
+						Addr:     "192.169.10.2",
+						IPFamily: ipfamily.IPv4,
+					},
+				},
+				ToAdvertise: []string{
+					"192.169.10.2/32",
+				},
+			},
+		},
 	}
 	if err := ApplyConfig(context.TODO(), &config, updater); err != nil {
 		t.Fatalf("Failed to apply config: %s", err)
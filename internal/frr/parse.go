@@ -50,16 +50,18 @@ type FRRNeighbor struct {
 }
 
 type MessageStats struct {
-	OpensSent          int `json:"opensSent"`
-	OpensReceived      int `json:"opensRecv"`
-	NotificationsSent  int `json:"notificationsSent"`
-	UpdatesSent        int `json:"updatesSent"`
-	UpdatesReceived    int `json:"updatesRecv"`
-	KeepalivesSent     int `json:"keepalivesSent"`
-	KeepalivesReceived int `json:"keepalivesRecv"`
-	RouteRefreshSent   int `json:"routeRefreshSent"`
-	TotalSent          int `json:"totalSent"`
-	TotalReceived      int `json:"totalRecv"`
+	OpensSent             int `json:"opensSent"`
+	OpensReceived         int `json:"opensRecv"`
+	NotificationsSent     int `json:"notificationsSent"`
+	NotificationsReceived int `json:"notificationsRecv"`
+	UpdatesSent           int `json:"updatesSent"`
+	UpdatesReceived       int `json:"updatesRecv"`
+	KeepalivesSent        int `json:"keepalivesSent"`
+	KeepalivesReceived    int `json:"keepalivesRecv"`
+	RouteRefreshSent      int `json:"routeRefreshSent"`
+	RouteRefreshReceived  int `json:"routeRefreshRecv"`
+	TotalSent             int `json:"totalSent"`
+	TotalReceived         int `json:"totalRecv"`
 }
 
 type IPInfo struct {
@@ -173,6 +175,7 @@ func ParseNeighbours(vtyshRes string) ([]*Neighbor, error) {
 		}
 		res = append(res, &Neighbor{
 			IP:             ip,
+			VRF:            n.VRFName,
 			Connected:      connected,
 			LocalAS:        strconv.Itoa(n.LocalAs),
 			RemoteAS:       strconv.Itoa(n.RemoteAs),
@@ -240,6 +243,33 @@ func ParseBFDPeers(vtyshRes string) ([]BFDPeer, error) {
 	return parseRes, nil
 }
 
+// EVPNVNI is a vni known to zebra. The counters are not parsed, as
+// their type changes between L2 and L3 vnis.
+type EVPNVNI struct {
+	VNI       int    `json:"vni"`
+	Type      string `json:"type"`
+	VxlanIf   string `json:"vxlanIf"`
+	TenantVRF string `json:"tenantVrf"`
+}
+
+// ParseEVPNVNIs takes the result of a show evpn vni json
+// and returns the vnis known to zebra, sorted by vni.
+func ParseEVPNVNIs(vtyshRes string) ([]EVPNVNI, error) {
+	toParse := map[string]EVPNVNI{}
+	err := json.Unmarshal([]byte(vtyshRes), &toParse)
+	if err != nil {
+		return nil, errors.Join(err, errors.New("parseEVPNVNIs: failed to parse vtysh response"))
+	}
+	res := make([]EVPNVNI, 0, len(toParse))
+	for _, v := range toParse {
+		res = append(res, v)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].VNI < res[j].VNI
+	})
+	return res, nil
+}
+
 func ParseVRFs(vtyshRes string) ([]string, error) {
 	vrfs := map[string]interface{}{}
 	err := json.Unmarshal([]byte(vtyshRes), &vrfs)
@@ -793,3 +793,38 @@ func TestVRFs(t *testing.T) {
 		t.Fatalf("unexpected vrf list: %s", cmp.Diff(parsed, expected))
 	}
 }
+
+const evpnVNIs = `{
+  "100":{
+    "vni":100,
+    "type":"L3",
+    "vxlanIf":"vni100",
+    "numMacs":0,
+    "numArpNd":0,
+    "numRemoteVteps":"n\/a",
+    "tenantVrf":"red"
+  },
+  "110":{
+    "vni":110,
+    "type":"L2",
+    "vxlanIf":"vni110",
+    "numMacs":3,
+    "numArpNd":2,
+    "numRemoteVteps":1,
+    "tenantVrf":"red"
+  }
+}`
+
+func TestEVPNVNIs(t *testing.T) {
+	parsed, err := ParseEVPNVNIs(evpnVNIs)
+	if err != nil {
+		t.Fatalf("Failed to parse %s", err)
+	}
+	expected := []EVPNVNI{
+		{VNI: 100, Type: "L3", VxlanIf: "vni100", TenantVRF: "red"},
+		{VNI: 110, Type: "L2", VxlanIf: "vni110", TenantVRF: "red"},
+	}
+	if !cmp.Equal(parsed, expected) {
+		t.Fatalf("unexpected vni list: %s", cmp.Diff(parsed, expected))
+	}
+}
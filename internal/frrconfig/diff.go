@@ -0,0 +1,188 @@
+package frrconfig
+
+import (
+	"strings"
+)
+
+// change is a command to run in the context at the given path.
+type change struct {
+	path    []string
+	command string
+}
+
+// configChanges returns the commands turning the running configuration
+// into the desired one. The removals come first, from the innermost
+// contexts and in the reverse order of the running configuration, with
+// the top level contexts referred to by the others last, so that nothing
+// is removed while something else still refers to it. The additions
+// follow, in the order of the desired configuration.
+func configChanges(running, desired *configContext) []change {
+	res := removedCommands(nil, running, desired)
+	return append(res, addedCommands(nil, running, desired, nil)...)
+}
+
+func removedCommands(path []string, running, desired *configContext) []change {
+	res := []change{}
+	for _, child := range removalOrder(path, running.children) {
+		desiredChild := desired.findChild(child.header)
+		if desiredChild == nil {
+			res = append(res, deletedContext(path, child)...)
+			continue
+		}
+		res = append(res, removedCommands(withContext(path, child.header), child, desiredChild)...)
+	}
+	desiredLines := lineSet(desired.lines)
+	for i := len(running.lines) - 1; i >= 0; i-- {
+		if len(path) == 0 && unmanagedLines[running.lines[i]] {
+			continue
+		}
+		if !desiredLines[running.lines[i]] {
+			res = append(res, change{path: path, command: negate(running.lines[i])})
+		}
+	}
+	return res
+}
+
+// referencedContexts are the prefixes of the top level contexts the others
+// refer to, which FRR shows after them.
+var referencedContexts = []string{
+	"route-map ",
+	"bfd",
+	"key chain ",
+	"vrf ",
+}
+
+// removalOrder returns the given contexts in the order their content is
+// removed: the reverse one, with the referenced top level contexts last.
+func removalOrder(path []string, contexts []*configContext) []*configContext {
+	res := []*configContext{}
+	referenced := []*configContext{}
+	for i := len(contexts) - 1; i >= 0; i-- {
+		if len(path) == 0 && opensContext(contexts[i].header, referencedContexts) {
+			referenced = append(referenced, contexts[i])
+			continue
+		}
+		res = append(res, contexts[i])
+	}
+	return append(res, referenced...)
+}
+
+// deletedContext returns the commands deleting the given context. The
+// address families can't be deleted, so their content is.
+func deletedContext(path []string, ctx *configContext) []change {
+	if !strings.HasPrefix(ctx.header, "address-family ") {
+		return []change{{path: path, command: negate(ctx.header)}}
+	}
+	return removedCommands(withContext(path, ctx.header), ctx, &configContext{header: ctx.header})
+}
+
+// addedCommands returns the commands of the desired context missing from
+// the running one, which is nil if it does not exist. The commands of the
+// given neighbors are added even if already running, see resetNeighbors.
+func addedCommands(path []string, running, desired *configContext, reset map[string]bool) []change {
+	res := []change{}
+	runningLines := map[string]bool{}
+	if running != nil {
+		runningLines = lineSet(running.lines)
+		if strings.HasPrefix(desired.header, "router bgp ") {
+			reset = resetNeighbors(running, desired)
+		}
+	} else if len(desired.lines) == 0 && len(desired.children) == 0 {
+		// entering the context is enough to create it
+		return []change{{path: path[:len(path)-1], command: desired.header}}
+	}
+	for _, l := range desired.lines {
+		if !runningLines[l] || reset[neighborOf(l)] {
+			res = append(res, change{path: path, command: l})
+		}
+	}
+	for _, child := range desired.children {
+		var runningChild *configContext
+		if running != nil {
+			runningChild = running.findChild(child.header)
+		}
+		res = append(res, addedCommands(withContext(path, child.header), runningChild, child, reset)...)
+	}
+	return res
+}
+
+// resetNeighbors returns the neighbors of the given router bgp contexts
+// whose remote-as (or peer-group) changes. Removing it deletes the
+// neighbor together with all its commands, which must be added again.
+func resetNeighbors(running, desired *configContext) map[string]bool {
+	res := map[string]bool{}
+	desiredLines := lineSet(desired.lines)
+	for _, l := range running.lines {
+		fields := strings.Fields(l)
+		if len(fields) < 3 || fields[0] != "neighbor" || (fields[2] != "remote-as" && fields[2] != "peer-group") {
+			continue
+		}
+		if !desiredLines[l] {
+			res[fields[1]] = true
+		}
+	}
+	return res
+}
+
+// neighborOf returns the neighbor the given command refers to, if any.
+func neighborOf(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "neighbor" {
+		return ""
+	}
+	return fields[1]
+}
+
+// negate returns the command undoing the given one.
+func negate(line string) string {
+	if strings.HasPrefix(line, "no ") {
+		return strings.TrimPrefix(line, "no ")
+	}
+	return "no " + line
+}
+
+func withContext(path []string, header string) []string {
+	res := make([]string, 0, len(path)+1)
+	res = append(res, path...)
+	return append(res, header)
+}
+
+func lineSet(lines []string) map[string]bool {
+	res := map[string]bool{}
+	for _, l := range lines {
+		res[l] = true
+	}
+	return res
+}
+
+// changesScript renders the given changes as a vtysh script. The changes
+// are wrapped in the XFRR markers, which batch the commit of the daemons
+// supporting them. This is not a transaction: if a command fails, the
+// ones before it stay applied, and NativeUpdate reloads the whole
+// configuration.
+func changesScript(changes []change) string {
+	var b strings.Builder
+	b.WriteString("XFRR_start_configuration\n")
+	current := []string{}
+	for _, c := range changes {
+		common := 0
+		for common < len(current) && common < len(c.path) && current[common] == c.path[common] {
+			common++
+		}
+		for len(current) > common {
+			current = current[:len(current)-1]
+			b.WriteString(strings.Repeat(" ", len(current)) + "exit\n")
+		}
+		for _, header := range c.path[common:] {
+			b.WriteString(strings.Repeat(" ", len(current)) + header + "\n")
+			current = append(current, header)
+		}
+		b.WriteString(strings.Repeat(" ", len(current)) + c.command + "\n")
+	}
+	for len(current) > 0 {
+		current = current[:len(current)-1]
+		b.WriteString(strings.Repeat(" ", len(current)) + "exit\n")
+	}
+	b.WriteString("XFRR_end_configuration\n")
+	return b.String()
+}
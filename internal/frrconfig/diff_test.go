@@ -0,0 +1,324 @@
+package frrconfig
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// runningConfig is a configuration as shown by vtysh.
+const runningConfig = `frr version 10.2
+frr defaults traditional
+hostname router
+log file /etc/frr/frr.log
+!
+vrf red
+ vni 100
+exit-vrf
+!
+router bgp 64514
+ no bgp ebgp-requires-policy
+ no bgp default ipv4-unicast
+ neighbor 192.168.11.2 remote-as 64512
+ neighbor 192.168.11.2 bfd
+ !
+ address-family ipv4 unicast
+  network 100.65.0.0/32
+  neighbor 192.168.11.2 activate
+ exit-address-family
+ !
+ address-family l2vpn evpn
+  neighbor 192.168.11.2 activate
+  advertise-all-vni
+ exit-address-family
+exit
+!
+router bgp 64514 vrf red
+ neighbor 192.169.10.0 remote-as 64515
+ !
+ address-family ipv4 unicast
+  neighbor 192.169.10.0 activate
+ exit-address-family
+exit
+!
+route-map allowall permit 1
+exit
+!
+end
+`
+
+// desiredConfig is the configuration of runningConfig as rendered by the
+// templates, with a different layout.
+const desiredConfig = `log file /etc/frr/frr.log
+hostname router
+vrf red
+  vni 100
+exit-vrf
+
+route-map allowall permit 1
+router bgp 64514
+  no bgp ebgp-requires-policy
+  no bgp default ipv4-unicast
+  neighbor 192.168.11.2 remote-as 64512
+
+  neighbor 192.168.11.2 bfd
+
+  address-family ipv4 unicast
+    neighbor 192.168.11.2 activate
+  exit-address-family
+  address-family ipv4 unicast
+    network 100.65.0.0/32
+  exit-address-family
+
+  address-family l2vpn evpn
+    neighbor 192.168.11.2 activate
+    advertise-all-vni
+  exit-address-family
+
+router bgp 64514 vrf red
+  neighbor 192.169.10.0 remote-as 64515
+
+  address-family ipv4 unicast
+    neighbor 192.169.10.0 activate
+  exit-address-family
+exit
+`
+
+func TestParseConfig(t *testing.T) {
+	ctx := parseConfig(runningConfig)
+	headers := []string{}
+	for _, c := range ctx.children {
+		headers = append(headers, c.header)
+	}
+	expected := []string{"vrf red", "router bgp 64514", "router bgp 64514 vrf red", "route-map allowall permit 1"}
+	if !cmp.Equal(headers, expected) {
+		t.Fatalf("unexpected contexts: %s", cmp.Diff(expected, headers))
+	}
+	if !cmp.Equal(ctx.lines, []string{"hostname router", "log file /etc/frr/frr.log"}) {
+		t.Fatalf("unexpected top level lines %v", ctx.lines)
+	}
+	if !cmp.Equal(ctx.children[0].lines, []string{"vni 100"}) {
+		t.Fatalf("expected vni to be a command of the vrf, got %v", ctx.children[0].lines)
+	}
+	evpn := ctx.children[1].findChild("address-family l2vpn evpn")
+	if evpn == nil || !cmp.Equal(evpn.lines, []string{"neighbor 192.168.11.2 activate", "advertise-all-vni"}) {
+		t.Fatalf("unexpected evpn address family %+v", evpn)
+	}
+}
+
+func TestConfigChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		running  string
+		desired  string
+		expected []change
+	}{
+		{
+			name:     "same config, different layout",
+			running:  runningConfig,
+			desired:  desiredConfig,
+			expected: []change{},
+		},
+		{
+			name:    "new vrf",
+			running: runningConfig,
+			desired: desiredConfig + `
+vrf blue
+  vni 200
+exit-vrf
+router bgp 64514 vrf blue
+  address-family l2vpn evpn
+    advertise ipv4 unicast
+  exit-address-family
+exit
+`,
+			expected: []change{
+				{path: []string{"vrf blue"}, command: "vni 200"},
+				{path: []string{"router bgp 64514 vrf blue", "address-family l2vpn evpn"}, command: "advertise ipv4 unicast"},
+			},
+		},
+		{
+			name:    "removed vrf",
+			running: runningConfig,
+			desired: `log file /etc/frr/frr.log
+hostname router
+route-map allowall permit 1
+router bgp 64514
+  no bgp ebgp-requires-policy
+  no bgp default ipv4-unicast
+  neighbor 192.168.11.2 remote-as 64512
+  neighbor 192.168.11.2 bfd
+  address-family ipv4 unicast
+    network 100.65.0.0/32
+    neighbor 192.168.11.2 activate
+  exit-address-family
+  address-family l2vpn evpn
+    neighbor 192.168.11.2 activate
+    advertise-all-vni
+  exit-address-family
+exit
+`,
+			expected: []change{
+				{command: "no router bgp 64514 vrf red"},
+				{command: "no vrf red"},
+			},
+		},
+		{
+			name:    "changed lines",
+			running: runningConfig,
+			desired: `log file /etc/frr/frr.log
+hostname router
+vrf red
+  vni 100
+exit-vrf
+route-map allowall permit 1
+router bgp 64514
+  no bgp ebgp-requires-policy
+  neighbor 192.168.11.2 remote-as 64512
+  neighbor 192.168.11.2 bfd
+  address-family ipv4 unicast
+    network 100.65.0.0/32
+    neighbor 192.168.11.2 activate
+  exit-address-family
+exit
+router bgp 64514 vrf red
+  neighbor 192.169.10.0 remote-as 64515
+  address-family ipv4 unicast
+    neighbor 192.169.10.0 activate
+  exit-address-family
+exit
+`,
+			expected: []change{
+				{path: []string{"router bgp 64514", "address-family l2vpn evpn"}, command: "no advertise-all-vni"},
+				{path: []string{"router bgp 64514", "address-family l2vpn evpn"}, command: "no neighbor 192.168.11.2 activate"},
+				{path: []string{"router bgp 64514"}, command: "bgp default ipv4-unicast"},
+			},
+		},
+		{
+			name:    "changed remote as",
+			running: runningConfig,
+			desired: `log file /etc/frr/frr.log
+hostname router
+vrf red
+  vni 100
+exit-vrf
+route-map allowall permit 1
+router bgp 64514
+  no bgp ebgp-requires-policy
+  no bgp default ipv4-unicast
+  neighbor 192.168.11.2 remote-as 64512
+  neighbor 192.168.11.2 bfd
+  address-family ipv4 unicast
+    network 100.65.0.0/32
+    neighbor 192.168.11.2 activate
+  exit-address-family
+  address-family l2vpn evpn
+    neighbor 192.168.11.2 activate
+    advertise-all-vni
+  exit-address-family
+exit
+router bgp 64514 vrf red
+  neighbor 192.169.10.0 remote-as 64516
+  address-family ipv4 unicast
+    neighbor 192.169.10.0 activate
+  exit-address-family
+exit
+`,
+			expected: []change{
+				{path: []string{"router bgp 64514 vrf red"}, command: "no neighbor 192.169.10.0 remote-as 64515"},
+				{path: []string{"router bgp 64514 vrf red"}, command: "neighbor 192.169.10.0 remote-as 64516"},
+				{path: []string{"router bgp 64514 vrf red", "address-family ipv4 unicast"}, command: "neighbor 192.169.10.0 activate"},
+			},
+		},
+		{
+			name: "lines shown differently by frr",
+			running: `Building configuration...
+
+Current configuration:
+!
+no ipv6 forwarding
+service integrated-vtysh-config
+router bgp 64514
+ neighbor 192.168.11.2 remote-as 64512
+ neighbor 192.168.11.2 bfd
+ neighbor 192.168.11.2 bfd profile fast
+ address-family ipv4 unicast
+  network 192.169.10.0/24
+ exit-address-family
+exit
+bfd
+ profile fast
+  echo transmit-interval 40
+  echo receive-interval 40
+ exit
+exit
+end
+`,
+			desired: `router bgp 64514
+  neighbor 192.168.11.2 remote-as 64512
+  neighbor 192.168.11.2 bfd profile fast
+  address-family ipv4 unicast
+    network 192.169.10.2/24
+  exit-address-family
+exit
+bfd
+  profile fast
+    detect-multiplier 3
+    echo-interval 40
+exit
+`,
+			expected: []change{},
+		},
+		{
+			name:    "non default bfd value",
+			running: "bfd\n profile fast\n  detect-multiplier 5\n exit\nexit\n",
+			desired: "bfd\n  profile fast\n    detect-multiplier 3\nexit\n",
+			expected: []change{
+				{path: []string{"bfd", "profile fast"}, command: "no detect-multiplier 5"},
+			},
+		},
+		{
+			name:    "new empty context",
+			running: "hostname router\n",
+			desired: "hostname router\nroute-map allowall permit 1\nbfd\n profile defaults\n exit\nexit\n",
+			expected: []change{
+				{command: "route-map allowall permit 1"},
+				{path: []string{"bfd"}, command: "profile defaults"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := configChanges(parseConfig(tc.running), parseConfig(tc.desired))
+			opts := []cmp.Option{cmp.AllowUnexported(change{}), cmpopts.EquateEmpty()}
+			if !cmp.Equal(changes, tc.expected, opts...) {
+				t.Fatalf("unexpected changes: %s", cmp.Diff(tc.expected, changes, opts...))
+			}
+		})
+	}
+}
+
+func TestChangesScript(t *testing.T) {
+	script := changesScript([]change{
+		{path: []string{"router bgp 64514 vrf red"}, command: "no neighbor 192.169.10.0 remote-as 64515"},
+		{path: []string{"router bgp 64514 vrf red"}, command: "neighbor 192.169.10.0 remote-as 64516"},
+		{path: []string{"router bgp 64514 vrf red", "address-family ipv4 unicast"}, command: "neighbor 192.169.10.0 activate"},
+		{command: "no vrf blue"},
+	})
+	expected := `XFRR_start_configuration
+router bgp 64514 vrf red
+ no neighbor 192.169.10.0 remote-as 64515
+ neighbor 192.169.10.0 remote-as 64516
+ address-family ipv4 unicast
+  neighbor 192.169.10.0 activate
+ exit
+exit
+no vrf blue
+XFRR_end_configuration
+`
+	if script != expected {
+		t.Fatalf("unexpected script: %s", cmp.Diff(expected, script))
+	}
+}
@@ -1,9 +1,11 @@
 package frrconfig
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os/exec"
+	"regexp"
 )
 
 type Action string
@@ -16,30 +18,58 @@ const (
 
 const frrConfPath = "/etc/frr/frr.conf"
 
-// Config reloads the frr configuration at the given path.
-func Update(path string) error {
-	slog.Info("config update", "path", path)
-	err := reloadAction(path, Test)
+// UpdateError is returned when one of the steps of the update fails.
+type UpdateError struct {
+	Action Action
+	// Output is the output of the failed step.
+	Output string
+	Err    error
+}
+
+func (e *UpdateError) Error() string {
+	return fmt.Sprintf("frr update %s failed: %v", e.Action, e.Err)
+}
+
+func (e *UpdateError) Unwrap() error {
+	return e.Err
+}
+
+// passwordRE matches the passwords of the bgp neighbors in the lines of
+// a configuration.
+var passwordRE = regexp.MustCompile(`(\bpassword\s+)\S+`)
+
+// RedactPasswords hides the passwords of the bgp neighbors in the given
+// output of an applier, which may contain the lines of the configuration.
+func RedactPasswords(output string) string {
+	return passwordRE.ReplaceAllString(output, "${1}<redacted>")
+}
+
+// Update reloads the frr configuration at the given path, returning
+// the output of frr-reload.py. If a step fails, the returned error
+// is an UpdateError.
+func Update(ctx context.Context, path string) (string, error) {
+	slog.InfoContext(ctx, "config update", "path", path)
+	testOutput, err := reloadAction(ctx, path, Test)
 	if err != nil {
-		return err
+		return testOutput, err
 	}
-	err = reloadAction(path, Reload)
+	reloadOutput, err := reloadAction(ctx, path, Reload)
 	if err != nil {
-		return err
+		return testOutput + reloadOutput, err
 	}
-	return nil
+	return testOutput + reloadOutput, nil
 }
 
-var execCommand = exec.Command
+var execCommand = exec.CommandContext
 
-func reloadAction(path string, action Action) error {
+func reloadAction(ctx context.Context, path string, action Action) (string, error) {
 	reloadParameter := "--" + string(action)
-	cmd := execCommand("python3", reloaderPath, reloadParameter, path)
+	cmd := execCommand(ctx, "python3", reloaderPath, reloadParameter, path)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		slog.Error("frr update failed", "action", action, "error", err, "output", string(output))
-		return fmt.Errorf("frr update %s failed: %w", action, err)
+		slog.ErrorContext(ctx, "frr update failed", "action", action, "error", err, "output", RedactPasswords(string(output)))
+		return string(output), &UpdateError{Action: action, Output: string(output), Err: err}
 	}
-	slog.Debug("frr update succeeded", "action", action, "output", string(output))
-	return nil
+	slog.DebugContext(ctx, "frr update succeeded", "action", action, "output", RedactPasswords(string(output)))
+	return string(output), nil
 }
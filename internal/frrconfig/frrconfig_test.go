@@ -1,10 +1,11 @@
 package frrconfig
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
-	"reflect"
 	"strings"
 	"testing"
 )
@@ -29,11 +30,11 @@ var tests = map[string]struct {
 
 func TestReload(t *testing.T) {
 	execCommand = fakeExecCommand
-	defer func() { execCommand = exec.Command }()
+	defer func() { execCommand = exec.CommandContext }()
 
 	for tc, params := range tests {
 		t.Run(fmt.Sprintf("reload %s", tc), func(t *testing.T) {
-			err := Update(tc)
+			_, err := Update(context.Background(), tc)
 			if (params.failReload || params.failValidate) && err == nil {
 				t.Fatalf("expecting failure, got no error")
 			}
@@ -43,6 +44,13 @@ func TestReload(t *testing.T) {
 			if params.failValidate && !strings.Contains(err.Error(), "test") {
 				t.Fatalf("expecting test error, got %v", err)
 			}
+			var updateErr *UpdateError
+			if params.failReload && (!errors.As(err, &updateErr) || updateErr.Action != Reload) {
+				t.Fatalf("expecting an update error for the reload step, got %v", err)
+			}
+			if params.failValidate && (!errors.As(err, &updateErr) || updateErr.Action != Test) {
+				t.Fatalf("expecting an update error for the test step, got %v", err)
+			}
 			if !params.failReload && !params.failValidate && err != nil {
 				t.Fatalf("expecting no error, got %v", err)
 			}
@@ -50,16 +58,47 @@ func TestReload(t *testing.T) {
 	}
 }
 
+func TestRedactPasswords(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{
+			name:     "no password",
+			output:   "neighbor 192.168.1.1 remote-as 64512",
+			expected: "neighbor 192.168.1.1 remote-as 64512",
+		},
+		{
+			name:     "password",
+			output:   " neighbor 192.168.1.1 password secret\n",
+			expected: " neighbor 192.168.1.1 password <redacted>\n",
+		},
+		{
+			name:     "passwords in a reload diff",
+			output:   "+ neighbor 192.168.1.1 password secret\n- neighbor 192.168.1.2 password other",
+			expected: "+ neighbor 192.168.1.1 password <redacted>\n- neighbor 192.168.1.2 password <redacted>",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := RedactPasswords(tc.output); res != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, res)
+			}
+		})
+	}
+}
+
 // helper function that redirects the execution to a mock process implemented by
 // TestHelperProcess
-func fakeExecCommand(name string, args ...string) *exec.Cmd {
+func fakeExecCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
 	cs := []string{"-test.run=TestFakeReloadHelper", "--"}
 	cs = append(cs, args...)
 	env := []string{
 		"WANT_FAKE_PYTHON=true",
 	}
 
-	cmd := exec.Command(os.Args[0], cs...)
+	cmd := exec.CommandContext(ctx, os.Args[0], cs...)
 	cmd.Env = append(env, os.Environ()...)
 	return cmd
 }
@@ -80,17 +119,17 @@ func TestFakeReloadHelper(t *testing.T) {
 		}
 		args = args[1:]
 	}
-	if len(args) != 4 {
-		fmt.Printf("expecting 4 args, got %v", args)
+	if len(args) != 3 {
+		fmt.Printf("expecting 3 args, got %v", args)
 		os.Exit(1)
 	}
 
-	if !reflect.DeepEqual(args[:2], []string{"-c", reloaderPath}) {
-		fmt.Println("expected to be called with -c reloader args", args)
+	if args[0] != reloaderPath {
+		fmt.Println("expected to be called with the reloader path", args)
 		os.Exit(1)
 	}
-	action, _ := strings.CutPrefix(args[2], "--")
-	path := args[3]
+	action, _ := strings.CutPrefix(args[1], "--")
+	path := args[2]
 
 	params, ok := tests[path]
 	if !ok {
@@ -0,0 +1,87 @@
+package frrconfig
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var update = flag.Bool("update", false, "update .golden files")
+
+// renderedConfigs are the golden tests of the frr package, whose
+// configurations are rendered from its template. The running
+// configuration FRR shows once each of them is applied is in testdata.
+var renderedConfigs = []string{
+	"TestBasic",
+	"TestBFDProfiles",
+	"TestDualStackVNI",
+	"TestEmpty",
+	"TestIPv6VTEP",
+	"TestMultipleUnderlayPaths",
+	"TestNeighborPassword",
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func renderedConfig(t *testing.T, name string) string {
+	return readTestFile(t, filepath.Join("..", "frr", "testdata", name+".golden"))
+}
+
+func runningConfigFor(t *testing.T, name string) string {
+	return readTestFile(t, filepath.Join("testdata", name+".running"))
+}
+
+func TestRenderedConfigNoChanges(t *testing.T) {
+	for _, name := range renderedConfigs {
+		t.Run(name, func(t *testing.T) {
+			changes := configChanges(parseConfig(runningConfigFor(t, name)), parseConfig(renderedConfig(t, name)))
+			if len(changes) > 0 {
+				t.Fatalf("expecting no changes reloading the running configuration, got:\n%s", changesScript(changes))
+			}
+		})
+	}
+}
+
+func TestRenderedConfigChanges(t *testing.T) {
+	tests := []struct {
+		running string
+		desired string
+	}{
+		{running: "TestBasic", desired: "TestBFDProfiles"},
+		{running: "TestBFDProfiles", desired: "TestBasic"},
+		{running: "TestBasic", desired: "TestDualStackVNI"},
+		{running: "TestBasic", desired: "TestNeighborPassword"},
+		{running: "TestBasic", desired: "TestMultipleUnderlayPaths"},
+		{running: "TestBasic", desired: "TestEmpty"},
+		{running: "TestEmpty", desired: "TestBasic"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.running+"To"+tc.desired, func(t *testing.T) {
+			changes := configChanges(parseConfig(runningConfigFor(t, tc.running)), parseConfig(renderedConfig(t, tc.desired)))
+			script := changesScript(changes)
+			goldenFile := filepath.Join("testdata", filepath.FromSlash(t.Name())+".golden")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(goldenFile), 0755); err != nil {
+					t.Fatalf("failed to create the golden dir: %v", err)
+				}
+				if err := os.WriteFile(goldenFile, []byte(script), 0600); err != nil {
+					t.Fatalf("failed to update %s: %v", goldenFile, err)
+				}
+			}
+			expected := readTestFile(t, goldenFile)
+			if script != expected {
+				t.Fatalf("unexpected changes: %s", cmp.Diff(expected, script))
+			}
+		})
+	}
+}
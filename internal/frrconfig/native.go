@@ -0,0 +1,152 @@
+package frrconfig
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+const (
+	// FRRReloadApplier applies the configuration with frr-reload.py.
+	FRRReloadApplier = "frr-reload"
+	// NativeApplier applies the configuration with NativeUpdate.
+	NativeApplier = "native"
+)
+
+// UpdateFor returns the function applying the configuration with the
+// given applier.
+func UpdateFor(applier string) (UpdateFunc, error) {
+	switch applier {
+	case FRRReloadApplier:
+		return Update, nil
+	case NativeApplier:
+		return NativeUpdate, nil
+	}
+	return nil, fmt.Errorf("unknown applier %q, must be %s or %s", applier, FRRReloadApplier, NativeApplier)
+}
+
+// NativeUpdate applies the frr configuration at the given path as Update
+// does, without frr-reload.py. The test step checks the configuration
+// and computes the commands to run, returning them. The reload step
+// computes them again against the running configuration and applies them
+// through vtysh. vtysh can't apply them as a transaction: if one of them
+// fails, the ones before it stay applied, and the configuration is
+// applied in full with frr-reload.py, which reconciles the running
+// configuration whatever the commands applied. The passwords are hidden
+// in the returned output. If a step fails, the returned error is an
+// UpdateError.
+func NativeUpdate(ctx context.Context, path string) (string, error) {
+	slog.InfoContext(ctx, "config update", "path", path, "applier", NativeApplier)
+	testOutput, err := nativeAction(ctx, path, Test)
+	if err != nil {
+		return testOutput, err
+	}
+	reloadOutput, err := nativeAction(ctx, path, Reload)
+	if err != nil {
+		return testOutput + reloadOutput, err
+	}
+	return testOutput + reloadOutput, nil
+}
+
+func nativeAction(ctx context.Context, path string, action Action) (string, error) {
+	var output string
+	var err error
+	switch action {
+	case Test:
+		output, err = nativeTest(ctx, path)
+	case Reload:
+		output, err = nativeReload(ctx, path)
+	}
+	// the output contains the commands applied, passwords included
+	output = RedactPasswords(output)
+	if err != nil {
+		slog.ErrorContext(ctx, "frr update failed", "action", action, "error", RedactPasswords(err.Error()), "output", output)
+		return output, &UpdateError{Action: action, Output: output, Err: err}
+	}
+	slog.DebugContext(ctx, "frr update succeeded", "action", action, "output", output)
+	return output, nil
+}
+
+func nativeTest(ctx context.Context, path string) (string, error) {
+	output, err := vtysh(ctx, "--dryrun", "-f", path)
+	if err != nil {
+		return output, fmt.Errorf("invalid configuration: %w", err)
+	}
+	changes, err := changesFor(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	return changesOutput(changes), nil
+}
+
+func nativeReload(ctx context.Context, path string) (string, error) {
+	changes, err := changesFor(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if len(changes) == 0 {
+		return changesOutput(changes), nil
+	}
+	script, err := os.CreateTemp("", "frr-changes")
+	if err != nil {
+		return "", fmt.Errorf("failed to create the changes file: %w", err)
+	}
+	defer os.Remove(script.Name())
+	if _, err := script.WriteString(changesScript(changes)); err != nil {
+		script.Close()
+		return "", fmt.Errorf("failed to write the changes to %s: %w", script.Name(), err)
+	}
+	if err := script.Close(); err != nil {
+		return "", fmt.Errorf("failed to close %s: %w", script.Name(), err)
+	}
+	output, err := vtysh(ctx, "-f", script.Name())
+	output = changesOutput(changes) + output
+	if err != nil {
+		slog.WarnContext(ctx, "frr changes failed, reloading the whole configuration", "error", err, "output", RedactPasswords(output))
+		reloadOutput, err := reloadAction(ctx, path, Reload)
+		output = output + "full reload:\n" + reloadOutput
+		if err != nil {
+			return output, fmt.Errorf("failed to apply the changes and to reload the whole configuration: %w", err)
+		}
+		return output, nil
+	}
+
+	// the commands FRR shows differently from how they are configured
+	// can't be compared, and are applied at each reload
+	left, err := changesFor(ctx, path)
+	if err != nil {
+		return output, err
+	}
+	if len(left) > 0 {
+		slog.WarnContext(ctx, "frr running configuration differs from the applied one", "changes", RedactPasswords(changesScript(left)))
+	}
+	return output, nil
+}
+
+// changesFor returns the changes turning the running configuration into
+// the one at the given path.
+func changesFor(ctx context.Context, path string) ([]change, error) {
+	desired, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	running, err := vtysh(ctx, "-c", "show running-config")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the running configuration: %w - output: %s", err, running)
+	}
+	return configChanges(parseConfig(running), parseConfig(string(desired))), nil
+}
+
+func changesOutput(changes []change) string {
+	if len(changes) == 0 {
+		return "no changes\n"
+	}
+	return "changes:\n" + changesScript(changes)
+}
+
+func vtysh(ctx context.Context, args ...string) (string, error) {
+	cmd := execCommand(ctx, "vtysh", args...)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
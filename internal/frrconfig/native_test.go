@@ -0,0 +1,212 @@
+package frrconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeVtysh redirects the vtysh and frr-reload.py executions to
+// TestFakeVtyshHelper, which serves the running configuration from the
+// files in the given directory. The commands whose first argument is in
+// fail, or the frr-reload.py ones with "python3 <action>", fail.
+func fakeVtysh(dir string, fail ...string) func(context.Context, string, ...string) *exec.Cmd {
+	return func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		cs := []string{"-test.run=TestFakeVtyshHelper", "--", name}
+		cs = append(cs, args...)
+		cmd := exec.CommandContext(ctx, os.Args[0], cs...)
+		cmd.Env = append([]string{"WANT_FAKE_VTYSH=true", "FAKE_VTYSH_DIR=" + dir, "FAKE_VTYSH_FAIL=" + strings.Join(fail, ",")}, os.Environ()...)
+		return cmd
+	}
+}
+
+func TestNativeUpdate(t *testing.T) {
+	defer func() { execCommand = exec.CommandContext }()
+
+	tests := []struct {
+		name             string
+		fail             []string
+		expectedErr      Action
+		expectedApplied  string
+		expectedReloaded bool
+	}{
+		{
+			name:            "succeeds",
+			expectedApplied: "no router bgp 64514 vrf red\nno route-map allowall permit 1\nno vrf red\n",
+		},
+		{
+			name:        "invalid config",
+			fail:        []string{"--dryrun"},
+			expectedErr: Test,
+		},
+		{
+			name:             "apply fails, the whole config is reloaded",
+			fail:             []string{"-f"},
+			expectedApplied:  "",
+			expectedReloaded: true,
+		},
+		{
+			name:             "apply and reload fail",
+			fail:             []string{"-f", "python3 --reload"},
+			expectedErr:      Reload,
+			expectedApplied:  "",
+			expectedReloaded: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			execCommand = fakeVtysh(dir, tc.fail...)
+			if err := os.WriteFile(filepath.Join(dir, "running"), []byte(runningConfig), 0600); err != nil {
+				t.Fatalf("failed to write the running config: %v", err)
+			}
+			desired := strings.Replace(runningConfig, "vrf red\n vni 100\nexit-vrf\n", "", 1)
+			desired = desired[:strings.Index(desired, "router bgp 64514 vrf red")]
+			desired = strings.Replace(desired, " neighbor 192.168.11.2 bfd\n", " neighbor 192.168.11.2 bfd\n neighbor 192.168.11.2 password secret\n", 1)
+			path := filepath.Join(dir, "frr.conf")
+			if err := os.WriteFile(path, []byte(desired), 0600); err != nil {
+				t.Fatalf("failed to write the config: %v", err)
+			}
+
+			output, err := NativeUpdate(context.Background(), path)
+			if strings.Contains(output, "password secret") {
+				t.Fatalf("expecting the passwords to be hidden, got %s", output)
+			}
+			var updateErr *UpdateError
+			if tc.expectedErr != "" && (!errors.As(err, &updateErr) || updateErr.Action != tc.expectedErr) {
+				t.Fatalf("expecting an update error for the %s step, got %v", tc.expectedErr, err)
+			}
+			if tc.expectedErr == "" && err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+			applied, _ := os.ReadFile(filepath.Join(dir, "applied"))
+			if tc.expectedApplied == "" && len(applied) > 0 {
+				t.Fatalf("expecting nothing applied, got %s", applied)
+			}
+			if !strings.Contains(string(applied), tc.expectedApplied) {
+				t.Fatalf("expecting %q to be applied, got %s", tc.expectedApplied, applied)
+			}
+			_, err = os.Stat(filepath.Join(dir, "reloaded"))
+			if reloaded := err == nil; reloaded != tc.expectedReloaded {
+				t.Fatalf("expecting the whole config reloaded to be %v, got %v", tc.expectedReloaded, reloaded)
+			}
+			if tc.expectedErr != "" {
+				return
+			}
+
+			// the running config now matches
+			output, err = NativeUpdate(context.Background(), path)
+			if err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+			if !strings.Contains(output, "no changes") {
+				t.Fatalf("expecting no changes, got %s", output)
+			}
+			appliedAgain, _ := os.ReadFile(filepath.Join(dir, "applied"))
+			if string(appliedAgain) != string(applied) {
+				t.Fatalf("expecting nothing else to be applied, got %s", appliedAgain)
+			}
+		})
+	}
+}
+
+func TestUpdateFor(t *testing.T) {
+	if _, err := UpdateFor(NativeApplier); err != nil {
+		t.Fatalf("expecting the native applier, got %v", err)
+	}
+	if _, err := UpdateFor(FRRReloadApplier); err != nil {
+		t.Fatalf("expecting the frr-reload applier, got %v", err)
+	}
+	if _, err := UpdateFor("other"); err == nil {
+		t.Fatalf("expecting error for an unknown applier")
+	}
+}
+
+// This is not a real test. It's used in case fakeVtysh is used in place of exec.Command.
+// The running config is read from the running file, applying a script
+// appends it to the applied file and replaces the running config with
+// the desired one. Running frr-reload.py creates the reloaded file, and
+// reloading replaces the running config too.
+func TestFakeVtyshHelper(t *testing.T) {
+	if os.Getenv("WANT_FAKE_VTYSH") != "true" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		args = args[1:]
+	}
+	if len(args) < 3 || (args[0] != "vtysh" && args[0] != "python3") {
+		fmt.Println("unexpected command", args)
+		os.Exit(1)
+	}
+	step := args[1]
+	if args[0] == "python3" {
+		step = "python3 " + args[2]
+		if err := os.WriteFile(filepath.Join(os.Getenv("FAKE_VTYSH_DIR"), "reloaded"), nil, 0600); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if slices.Contains(strings.Split(os.Getenv("FAKE_VTYSH_FAIL"), ","), step) {
+		fmt.Println(args[0], "failed")
+		os.Exit(1)
+	}
+	dir := os.Getenv("FAKE_VTYSH_DIR")
+	switch step {
+	case "python3 --reload":
+		desired, err := os.ReadFile(args[len(args)-1])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "running"), desired, 0600); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	case "-c":
+		running, err := os.ReadFile(filepath.Join(dir, "running"))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Print(string(running))
+	case "--dryrun":
+	case "-f":
+		script, err := os.ReadFile(args[len(args)-1])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		applied, err := os.OpenFile(filepath.Join(dir, "applied"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		_, err = applied.Write(script)
+		applied.Close()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		desired, err := os.ReadFile(filepath.Join(dir, "frr.conf"))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "running"), desired, 0600); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	os.Exit(0)
+}
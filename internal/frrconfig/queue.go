@@ -0,0 +1,192 @@
+package frrconfig
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxHistory is the number of reloads kept in the history.
+const maxHistory = 20
+
+// Status is the status of the reloads served by a Queue.
+type Status struct {
+	// AppliedVersion is the version of the configuration FRR is running,
+	// empty if unknown, as when a reload fails without a rollback.
+	AppliedVersion string `json:"appliedVersion,omitempty"`
+	// LastResult is the result of the last reload.
+	LastResult *ReloadResult `json:"lastResult,omitempty"`
+	// LastReloadTime is when the last reload completed.
+	LastReloadTime *time.Time `json:"lastReloadTime,omitempty"`
+	// Pending tells if a reload is waiting for the running one to complete.
+	Pending bool `json:"pending"`
+	// History are the last reloads, the most recent first.
+	History []HistoryEntry `json:"history"`
+}
+
+// HistoryEntry is a reload in the history of a Queue.
+type HistoryEntry struct {
+	Time       time.Time `json:"time"`
+	Duration   string    `json:"duration"`
+	Version    string    `json:"version"`
+	Success    bool      `json:"success"`
+	FailedStep Action    `json:"failedStep,omitempty"`
+	// RolledBackTo is the version applied again after the failure, if the
+	// rollback succeeded.
+	RolledBackTo string `json:"rolledBackTo,omitempty"`
+	// Requests is the number of requests served by the reload, as the
+	// ones arriving while a reload is running are merged.
+	Requests int `json:"requests"`
+}
+
+// pendingReload is the reload to run next, together with the requests
+// waiting for its result.
+type pendingReload struct {
+	request ReloadRequest
+	waiters []waiter
+}
+
+type waiter struct {
+	ctx    context.Context
+	result chan ReloadResult
+}
+
+// Queue serializes the reloads through a single worker. The requests
+// arriving while a reload runs are merged: only the most recent one is
+// applied, and all of them get its result.
+type Queue struct {
+	reloader *Reloader
+	kick     chan struct{}
+
+	mu      sync.Mutex
+	pending *pendingReload
+	status  Status
+}
+
+// NewQueue returns a queue applying the configurations with the given reloader.
+func NewQueue(reloader *Reloader) *Queue {
+	return &Queue{
+		reloader: reloader,
+		kick:     make(chan struct{}, 1),
+		status:   Status{History: []HistoryEntry{}},
+	}
+}
+
+// Submit queues the given request and waits for the result of the reload
+// serving it, or for the context to be done.
+func (q *Queue) Submit(ctx context.Context, request ReloadRequest) ReloadResult {
+	w := waiter{ctx: ctx, result: make(chan ReloadResult, 1)}
+	q.mu.Lock()
+	if q.pending == nil {
+		q.pending = &pendingReload{}
+	} else {
+		slog.InfoContext(ctx, "reload merged with a pending one", "version", request.Version, "replaced", q.pending.request.Version)
+	}
+	q.pending.request = request
+	q.pending.waiters = append(q.pending.waiters, w)
+	q.mu.Unlock()
+
+	select {
+	case q.kick <- struct{}{}:
+	default:
+	}
+
+	select {
+	case res := <-w.result:
+		return res
+	case <-ctx.Done():
+		return ReloadResult{Version: request.Version, Error: ctx.Err().Error()}
+	}
+}
+
+// Run serves the queued reloads until the context is done.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.kick:
+		}
+		q.mu.Lock()
+		next := q.pending
+		q.pending = nil
+		q.mu.Unlock()
+		if next == nil {
+			continue
+		}
+		q.serve(ctx, next)
+	}
+}
+
+func (q *Queue) serve(ctx context.Context, reload *pendingReload) {
+	waitersCtx, cancel := whileAnyWaiting(ctx, reload.waiters)
+	defer cancel()
+	start := time.Now()
+	res := q.reloader.Apply(waitersCtx, reload.request)
+	end := time.Now()
+
+	q.mu.Lock()
+	q.status.LastResult = &res
+	q.status.LastReloadTime = &end
+	switch {
+	case res.Success:
+		q.status.AppliedVersion = res.Version
+	case res.Rollback != nil && res.Rollback.Success:
+		q.status.AppliedVersion = res.Rollback.Version
+	case res.FailedStep == Reload || res.Rollback != nil:
+		q.status.AppliedVersion = ""
+	}
+	entry := HistoryEntry{
+		Time:       end,
+		Duration:   end.Sub(start).String(),
+		Version:    res.Version,
+		Success:    res.Success,
+		FailedStep: res.FailedStep,
+		Requests:   len(reload.waiters),
+	}
+	if res.Rollback != nil && res.Rollback.Success {
+		entry.RolledBackTo = res.Rollback.Version
+	}
+	q.status.History = append([]HistoryEntry{entry}, q.status.History...)
+	if len(q.status.History) > maxHistory {
+		q.status.History = q.status.History[:maxHistory]
+	}
+	q.mu.Unlock()
+
+	for _, w := range reload.waiters {
+		w.result <- res
+	}
+}
+
+// Status returns the status of the reloads.
+func (q *Queue) Status() Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	res := q.status
+	res.Pending = q.pending != nil
+	res.History = append([]HistoryEntry{}, q.status.History...)
+	return res
+}
+
+// whileAnyWaiting returns a context which is canceled when all the given
+// waiters gave up, so that a reload nobody is waiting for is canceled.
+func whileAnyWaiting(parent context.Context, waiters []waiter) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	remaining := int32(len(waiters))
+	stops := make([]func() bool, 0, len(waiters))
+	for _, w := range waiters {
+		stops = append(stops, context.AfterFunc(w.ctx, func() {
+			if atomic.AddInt32(&remaining, -1) == 0 {
+				cancel()
+			}
+		}))
+	}
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,145 @@
+package frrconfig
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingFRR records the configurations applied, blocking each reload
+// until it is released.
+type blockingFRR struct {
+	mu      sync.Mutex
+	applied []string
+	started chan string
+	release chan struct{}
+}
+
+func (f *blockingFRR) update(ctx context.Context, path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	f.started <- string(content)
+	select {
+	case <-f.release:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.applied = append(f.applied, string(content))
+	return "", nil
+}
+
+func TestQueueCoalescesReloads(t *testing.T) {
+	frr := &blockingFRR{started: make(chan string), release: make(chan struct{})}
+	reloader, err := NewReloader(filepath.Join(t.TempDir(), "frr.conf"), frr.update)
+	if err != nil {
+		t.Fatalf("failed to create the reloader: %v", err)
+	}
+	q := NewQueue(reloader)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	results := make(chan ReloadResult, 3)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results <- q.Submit(ctx, requestFor("first"))
+	}()
+	if started := <-frr.started; started != "first" {
+		t.Fatalf("expected the first config to be reloaded, got %s", started)
+	}
+
+	// these arrive while the first reload is running
+	for _, config := range []string{"second", "third"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- q.Submit(ctx, requestFor(config))
+		}()
+		// the requests are submitted in order
+		waitFor(t, func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return q.pending != nil && q.pending.request.Config == config
+		})
+	}
+	if !q.Status().Pending {
+		t.Fatalf("expected a pending reload")
+	}
+
+	frr.release <- struct{}{}
+	if started := <-frr.started; started != "third" {
+		t.Fatalf("expected the pending requests to be merged into the third, got %s", started)
+	}
+	frr.release <- struct{}{}
+	wg.Wait()
+
+	close(results)
+	versions := map[string]int{}
+	for r := range results {
+		if !r.Success {
+			t.Fatalf("expected the reloads to succeed, got %+v", r)
+		}
+		versions[r.Version]++
+	}
+	if versions[ConfigVersion("first")] != 1 || versions[ConfigVersion("third")] != 2 {
+		t.Fatalf("unexpected results %v", versions)
+	}
+
+	status := q.Status()
+	if status.AppliedVersion != ConfigVersion("third") {
+		t.Fatalf("expected the third config to be applied, got %s", status.AppliedVersion)
+	}
+	if len(status.History) != 2 || status.History[0].Requests != 2 || status.History[1].Requests != 1 {
+		t.Fatalf("unexpected history %+v", status.History)
+	}
+	if status.Pending {
+		t.Fatalf("expected no pending reloads")
+	}
+}
+
+func TestQueueCancelsAbandonedReloads(t *testing.T) {
+	frr := &blockingFRR{started: make(chan string), release: make(chan struct{})}
+	reloader, err := NewReloader(filepath.Join(t.TempDir(), "frr.conf"), frr.update)
+	if err != nil {
+		t.Fatalf("failed to create the reloader: %v", err)
+	}
+	q := NewQueue(reloader)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	requestCtx, cancelRequest := context.WithCancel(ctx)
+	result := make(chan ReloadResult)
+	go func() { result <- q.Submit(requestCtx, requestFor("first")) }()
+	<-frr.started
+	cancelRequest()
+	if res := <-result; res.Success {
+		t.Fatalf("expected the canceled request to fail")
+	}
+	waitFor(t, func() bool {
+		return q.Status().LastResult != nil
+	})
+	if q.Status().LastResult.Success {
+		t.Fatalf("expected the abandoned reload to be canceled")
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,135 @@
+package frrconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// rollbackTimeout is the maximum time the reload of the last known good
+// configuration is waited for. The rollback goes on even if the request
+// that caused it is canceled, as FRR may be partially configured.
+const rollbackTimeout = 2 * time.Minute
+
+// UpdateFunc applies the configuration at the given path, see Update.
+type UpdateFunc func(ctx context.Context, path string) (string, error)
+
+// Reloader applies the configurations it receives, and rolls back to the
+// last one applied successfully when reloading a new one fails.
+type Reloader struct {
+	configPath string
+	update     UpdateFunc
+
+	mu       sync.Mutex
+	lastGood *ReloadRequest
+}
+
+// lastGoodPath is where the last configuration applied successfully is
+// saved, so that it survives the restarts of the reloader.
+func lastGoodPath(configPath string) string {
+	return configPath + ".lastgood"
+}
+
+// NewReloader returns a reloader writing the configurations to the given
+// path and applying them with the given function.
+func NewReloader(configPath string, update UpdateFunc) (*Reloader, error) {
+	res := &Reloader{configPath: configPath, update: update}
+	data, err := os.ReadFile(lastGoodPath(configPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the last known good config: %w", err)
+	}
+	var lastGood ReloadRequest
+	if err := json.Unmarshal(data, &lastGood); err != nil {
+		slog.Warn("ignoring invalid last known good config", "path", lastGoodPath(configPath), "error", err)
+		return res, nil
+	}
+	res.lastGood = &lastGood
+	return res, nil
+}
+
+// Apply writes and applies the given configuration. If the reload fails,
+// the last configuration applied successfully is applied again, and the
+// outcome is reported in the rollback field of the result.
+func (r *Reloader) Apply(ctx context.Context, request ReloadRequest) ReloadResult {
+	result := r.apply(ctx, request)
+	if result.Success {
+		r.setLastGood(request)
+		return result
+	}
+	if result.FailedStep != Reload {
+		// the configuration was not applied, FRR is untouched
+		return result
+	}
+
+	lastGood := r.lastKnownGood()
+	if lastGood == nil {
+		slog.WarnContext(ctx, "reload failed with no last known good config to roll back to", "version", request.Version)
+		return result
+	}
+	slog.InfoContext(ctx, "reload failed, rolling back", "version", request.Version, "rollback", lastGood.Version)
+	rollbackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+	rollback := r.apply(rollbackCtx, *lastGood)
+	result.Rollback = &RollbackResult{
+		Version: rollback.Version,
+		Success: rollback.Success,
+		Output:  rollback.Output,
+		Error:   rollback.Error,
+	}
+	if !rollback.Success {
+		slog.ErrorContext(ctx, "rollback failed", "version", lastGood.Version, "error", rollback.Error)
+	}
+	return result
+}
+
+// apply writes and applies the given configuration. The passwords are
+// hidden in the output and in the error of the result, as they are
+// returned to the controller and reported in the status of the reloader.
+func (r *Reloader) apply(ctx context.Context, request ReloadRequest) ReloadResult {
+	result := ReloadResult{Version: request.Version}
+	if err := WriteConfig(r.configPath, request.Config); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	output, err := r.update(ctx, r.configPath)
+	result.Output = RedactPasswords(output)
+	if err != nil {
+		result.Error = RedactPasswords(err.Error())
+		var updateErr *UpdateError
+		if errors.As(err, &updateErr) {
+			result.FailedStep = updateErr.Action
+		}
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+func (r *Reloader) lastKnownGood() *ReloadRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastGood
+}
+
+func (r *Reloader) setLastGood(request ReloadRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastGood = &request
+	data, err := json.Marshal(request)
+	if err != nil {
+		slog.Error("failed to marshal the last known good config", "error", err)
+		return
+	}
+	// not being able to save it only affects the rollbacks after a restart
+	if err := WriteConfig(lastGoodPath(r.configPath), string(data)); err != nil {
+		slog.Error("failed to save the last known good config", "error", err)
+	}
+}
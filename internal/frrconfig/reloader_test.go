@@ -0,0 +1,124 @@
+package frrconfig
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeFRR records the configurations applied, failing the reload of the
+// ones listed in failReload.
+type fakeFRR struct {
+	applied    []string
+	failReload map[string]bool
+}
+
+func (f *fakeFRR) update(_ context.Context, path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if f.failReload[string(content)] {
+		return "reload output", &UpdateError{Action: Reload, Output: "reload output", Err: errors.New("exit status 1")}
+	}
+	f.applied = append(f.applied, string(content))
+	return "", nil
+}
+
+func requestFor(config string) ReloadRequest {
+	return ReloadRequest{Version: ConfigVersion(config), Config: config}
+}
+
+func TestReloaderRollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "frr.conf")
+	frr := &fakeFRR{failReload: map[string]bool{"bad": true, "worse": true}}
+	reloader, err := NewReloader(path, frr.update)
+	if err != nil {
+		t.Fatalf("failed to create the reloader: %v", err)
+	}
+	ctx := context.Background()
+
+	result := reloader.Apply(ctx, requestFor("bad"))
+	if result.Success || result.FailedStep != Reload {
+		t.Fatalf("expected the reload to fail, got %+v", result)
+	}
+	if result.Rollback != nil {
+		t.Fatalf("expected no rollback with no config applied before, got %+v", result.Rollback)
+	}
+
+	result = reloader.Apply(ctx, requestFor("good"))
+	if !result.Success {
+		t.Fatalf("expected the reload to succeed, got %+v", result)
+	}
+
+	result = reloader.Apply(ctx, requestFor("bad"))
+	if result.Success {
+		t.Fatalf("expected the reload to fail, got %+v", result)
+	}
+	if result.Rollback == nil || !result.Rollback.Success || result.Rollback.Version != ConfigVersion("good") {
+		t.Fatalf("expected a successful rollback to the good config, got %+v", result.Rollback)
+	}
+	if frr.applied[len(frr.applied)-1] != "good" {
+		t.Fatalf("expected the good config to be applied again, got %v", frr.applied)
+	}
+
+	// the last known good config survives the restarts
+	restarted, err := NewReloader(path, frr.update)
+	if err != nil {
+		t.Fatalf("failed to create the reloader: %v", err)
+	}
+	result = restarted.Apply(ctx, requestFor("worse"))
+	if result.Rollback == nil || !result.Rollback.Success || result.Rollback.Version != ConfigVersion("good") {
+		t.Fatalf("expected a successful rollback to the good config after a restart, got %+v", result.Rollback)
+	}
+}
+
+func TestReloaderNoRollbackWhenTestFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "frr.conf")
+	calls := 0
+	reloader, err := NewReloader(path, func(_ context.Context, _ string) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", nil
+		}
+		return "invalid", &UpdateError{Action: Test, Output: "invalid", Err: errors.New("exit status 1")}
+	})
+	if err != nil {
+		t.Fatalf("failed to create the reloader: %v", err)
+	}
+	ctx := context.Background()
+	if result := reloader.Apply(ctx, requestFor("good")); !result.Success {
+		t.Fatalf("expected the reload to succeed, got %+v", result)
+	}
+	result := reloader.Apply(ctx, requestFor("invalid"))
+	if result.Success || result.FailedStep != Test {
+		t.Fatalf("expected the test step to fail, got %+v", result)
+	}
+	if result.Rollback != nil {
+		t.Fatalf("expected no rollback when the test fails, got %+v", result.Rollback)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 updates, got %d", calls)
+	}
+}
+
+func TestReloaderRedactsPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "frr.conf")
+	output := "neighbor 192.168.1.1 password secret"
+	reloader, err := NewReloader(path, func(_ context.Context, _ string) (string, error) {
+		return output, &UpdateError{Action: Test, Output: output, Err: errors.New("invalid: " + output)}
+	})
+	if err != nil {
+		t.Fatalf("failed to create the reloader: %v", err)
+	}
+	result := reloader.Apply(context.Background(), requestFor("invalid"))
+	if strings.Contains(result.Output, "secret") || strings.Contains(result.Error, "secret") {
+		t.Fatalf("expected the passwords to be hidden, got %+v", result)
+	}
+	if !strings.Contains(result.Output, "password <redacted>") {
+		t.Fatalf("expected the redacted output, got %q", result.Output)
+	}
+}
@@ -0,0 +1,236 @@
+package frrconfig
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// configContext is a context of an FRR configuration, such as a router
+// bgp instance or one of its address families, with the commands it
+// contains and its sub contexts, in the order they appear.
+type configContext struct {
+	// header is the command entering the context, empty for the root.
+	header   string
+	lines    []string
+	children []*configContext
+}
+
+// topLevelContexts are the commands entering a context from the root of
+// the configuration: the ones ending with a space are prefixes followed
+// by the arguments, the others must match the whole command.
+var topLevelContexts = []string{
+	"router ",
+	"vrf ",
+	"interface ",
+	"route-map ",
+	"bfd",
+	"key chain ",
+	"line vty",
+	"segment-routing",
+	"mpls ldp",
+	"l2vpn ",
+	"nexthop-group ",
+	"pbr-map ",
+	"rpki",
+}
+
+// subContexts are the prefixes of the commands entering a context from the
+// contexts with the given header prefix. The same command may be a
+// plain one elsewhere, as "vni" is inside a vrf.
+var subContexts = map[string][]string{
+	"router bgp ":               {"address-family "},
+	"address-family l2vpn evpn": {"vni "},
+	"bfd":                       {"profile ", "peer "},
+	"key chain ":                {"key "},
+}
+
+// contextClosers are the commands leaving the current context.
+var contextClosers = map[string]bool{
+	"exit":                true,
+	"exit-vrf":            true,
+	"exit-address-family": true,
+	"exit-vni":            true,
+	"quit":                true,
+}
+
+// ignoredLines are the prefixes of the lines that are not part of the
+// configuration to apply, as FRR adds them to its running configuration.
+var ignoredLines = []string{
+	"Building configuration...",
+	"Current configuration:",
+	"frr version ",
+	"frr defaults ",
+	"!",
+	"#",
+}
+
+// unmanagedLines are the top level lines FRR shows on its own, reflecting
+// the state of the system or of vtysh rather than the configuration, as
+// "no ipv6 forwarding". They are never removed, as negating them would
+// change something nobody configured.
+var unmanagedLines = map[string]bool{
+	"service integrated-vtysh-config": true,
+	"ip forwarding":                   true,
+	"no ip forwarding":                true,
+	"ipv6 forwarding":                 true,
+	"no ipv6 forwarding":              true,
+}
+
+// bfdDefaults are the commands of the bfd profiles and peers setting the
+// default values, which FRR does not show.
+var bfdDefaults = map[string]bool{
+	"detect-multiplier 3":       true,
+	"receive-interval 300":      true,
+	"transmit-interval 300":     true,
+	"echo receive-interval 50":  true,
+	"echo transmit-interval 50": true,
+	"minimum-ttl 254":           true,
+	"no echo-mode":              true,
+	"no passive-mode":           true,
+	"no shutdown":               true,
+}
+
+// parseConfig parses the given FRR configuration into its context
+// hierarchy. Contexts appearing more than once are merged, as FRR does.
+// The hierarchy is driven by the commands only, as the indentation of the
+// configurations is not reliable.
+func parseConfig(config string) *configContext {
+	root := &configContext{}
+	stack := []*configContext{root}
+	for _, l := range strings.Split(config, "\n") {
+		line := strings.Join(strings.Fields(l), " ")
+		if line == "" || isIgnored(line) {
+			continue
+		}
+		if line == "end" {
+			stack = stack[:1]
+			continue
+		}
+		if contextClosers[line] {
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		depth, opens := openingDepth(stack, line)
+		if !opens {
+			current := stack[len(stack)-1]
+			current.addLines(normalizedLines(current.header, line)...)
+			continue
+		}
+		stack = stack[:depth+1]
+		stack = append(stack, stack[depth].child(line))
+	}
+	return root
+}
+
+// openingDepth returns the depth of the context in the given stack the
+// given line enters a sub context of, starting from the innermost one,
+// and whether the line enters a context at all.
+func openingDepth(stack []*configContext, line string) (int, bool) {
+	for i := len(stack) - 1; i > 0; i-- {
+		for prefix, subs := range subContexts {
+			if strings.HasPrefix(stack[i].header, prefix) && opensContext(line, subs) {
+				return i, true
+			}
+		}
+	}
+	if opensContext(line, topLevelContexts) {
+		return 0, true
+	}
+	return 0, false
+}
+
+// addLines adds the given lines to the context, skipping the ones it
+// already contains.
+func (c *configContext) addLines(lines ...string) {
+	for _, l := range lines {
+		if !containsLine(c.lines, l) {
+			c.lines = append(c.lines, l)
+		}
+	}
+}
+
+func containsLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizedLines returns the given command of the context with the given
+// header as FRR shows it in its running configuration, so that the
+// rendered and the running configurations can be compared. A command may
+// be shown as more than one, or not at all when it sets a default value.
+func normalizedLines(header, line string) []string {
+	fields := strings.Fields(line)
+	switch {
+	case strings.HasPrefix(header, "profile ") || strings.HasPrefix(header, "peer "):
+		if len(fields) == 2 && fields[0] == "echo-interval" {
+			// the deprecated command sets both the echo intervals
+			return withoutBFDDefaults("echo transmit-interval "+fields[1], "echo receive-interval "+fields[1])
+		}
+		return withoutBFDDefaults(line)
+	case len(fields) >= 2 && fields[0] == "network":
+		// the prefixes are stored with the host bits cleared
+		if prefix, err := netip.ParsePrefix(fields[1]); err == nil {
+			fields[1] = prefix.Masked().String()
+			return []string{strings.Join(fields, " ")}
+		}
+	case len(fields) == 5 && fields[0] == "neighbor" && fields[2] == "bfd" && fields[3] == "profile":
+		// setting the profile enables bfd on the neighbor too
+		return []string{strings.Join(fields[:3], " "), line}
+	}
+	return []string{line}
+}
+
+func withoutBFDDefaults(lines ...string) []string {
+	res := []string{}
+	for _, l := range lines {
+		if !bfdDefaults[l] {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+// child returns the sub context with the given header, adding it if missing.
+func (c *configContext) child(header string) *configContext {
+	if res := c.findChild(header); res != nil {
+		return res
+	}
+	res := &configContext{header: header}
+	c.children = append(c.children, res)
+	return res
+}
+
+func (c *configContext) findChild(header string) *configContext {
+	for _, child := range c.children {
+		if child.header == header {
+			return child
+		}
+	}
+	return nil
+}
+
+// opensContext tells if the given line is one of the given commands
+// entering a context, see topLevelContexts.
+func opensContext(line string, commands []string) bool {
+	for _, c := range commands {
+		if line == c || (strings.HasSuffix(c, " ") && strings.HasPrefix(line, c)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIgnored(line string) bool {
+	for _, p := range ignoredLines {
+		if strings.HasPrefix(line, p) {
+			return true
+		}
+	}
+	return false
+}
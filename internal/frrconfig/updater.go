@@ -1,30 +1,140 @@
 package frrconfig
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/openperouter/openperouter/internal/reloaderconn"
 )
 
-func UpdaterForAddress(address string, configFile string) func(context.Context, string) error {
+// reloadTimeout is the maximum time a reload is waited for, on top of
+// the deadline of the caller's context.
+const reloadTimeout = 2 * time.Minute
+
+// ReloadRequest asks the reloader to apply the given configuration.
+type ReloadRequest struct {
+	// Version identifies the configuration, see ConfigVersion.
+	Version string `json:"version"`
+	Config  string `json:"config"`
+}
+
+// ReloadResult is the outcome of a ReloadRequest.
+type ReloadResult struct {
+	// Version is the version of the configuration the result refers to.
+	Version string `json:"version"`
+	Success bool   `json:"success"`
+	// FailedStep is the step of the update that failed, if any.
+	FailedStep Action `json:"failedStep,omitempty"`
+	// Output is the output of the applier (frr-reload.py by default).
+	Output string `json:"output,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Rollback is the outcome of applying again the last configuration
+	// applied successfully, attempted when the reload fails.
+	Rollback *RollbackResult `json:"rollback,omitempty"`
+}
+
+// RollbackResult is the outcome of a rollback.
+type RollbackResult struct {
+	// Version is the version of the configuration rolled back to.
+	Version string `json:"version"`
+	Success bool   `json:"success"`
+	Output  string `json:"output,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ConfigVersion returns the version of the given configuration, which is
+// the hash of its content.
+func ConfigVersion(config string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(config)))
+}
+
+// WriteConfig writes the given configuration to the given path. The file
+// is replaced atomically, so that a reload never reads a partial file.
+func WriteConfig(path, config string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create the temporary config file for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(config); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write the config to %s: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move the config to %s: %w", path, err)
+	}
+	return nil
+}
+
+// UpdaterForAddress returns an updater sending the configuration to the
+// reloader listening on the given address through the given client, and
+// waiting for it to be applied.
+func UpdaterForAddress(address string, client *reloaderconn.Client) func(context.Context, string) error {
 	return func(ctx context.Context, config string) error {
-		slog.InfoContext(ctx, "updater writing frr file", "file", configFile)
-		err := os.WriteFile(configFile, []byte(config), 0600)
+		request := ReloadRequest{Version: ConfigVersion(config), Config: config}
+		body, err := json.Marshal(request)
 		if err != nil {
-			return fmt.Errorf("failed to write the config to %s", configFile)
+			return fmt.Errorf("failed to marshal the reload request: %w", err)
 		}
-		requestURL := fmt.Sprintf("http://%s", address)
-		slog.InfoContext(ctx, "updater requesting update", "url", requestURL)
+
+		ctx, cancel := context.WithTimeout(ctx, reloadTimeout)
+		defer cancel()
+		requestURL := client.URL(address, "/")
+		slog.InfoContext(ctx, "updater requesting update", "url", requestURL, "version", request.Version)
 		defer slog.InfoContext(ctx, "updater update requested")
-		res, err := http.Post(requestURL, "", nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to create the reload request for %s: %w", address, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		res, err := client.Do(req)
 		if err != nil {
 			return fmt.Errorf("failed to reload against %s: %w", address, err)
 		}
-		if res.StatusCode != http.StatusOK {
-			return fmt.Errorf("failed to reload against %s, status %d", address, res.StatusCode)
+		defer res.Body.Close()
+
+		var result ReloadResult
+		if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+			return fmt.Errorf("failed to reload against %s, status %d: invalid response: %w", address, res.StatusCode, err)
+		}
+		if !result.Success {
+			// the error is reported in the events and in the status of the
+			// resources: the passwords are hidden even if the reloader
+			// didn't hide them
+			err := fmt.Errorf("failed to reload version %s against %s: %s", request.Version, address, RedactPasswords(result.Error))
+			if result.FailedStep != "" {
+				err = fmt.Errorf("failed to reload version %s against %s, step %s failed: %s - output: %s",
+					request.Version, address, result.FailedStep, RedactPasswords(result.Error), RedactPasswords(result.Output))
+			}
+			return withRollback(err, result.Rollback)
+		}
+		if result.Version != request.Version {
+			return fmt.Errorf("failed to reload against %s: version %s superseded by version %s", address, request.Version, result.Version)
 		}
 		return nil
 	}
 }
+
+// withRollback adds the outcome of the given rollback, if any, to the
+// given reload error.
+func withRollback(err error, rollback *RollbackResult) error {
+	if rollback == nil {
+		return err
+	}
+	if rollback.Success {
+		return fmt.Errorf("%w, rolled back to version %s", err, rollback.Version)
+	}
+	return fmt.Errorf("%w, rollback to version %s failed: %s - output: %s", err, rollback.Version,
+		RedactPasswords(rollback.Error), RedactPasswords(rollback.Output))
+}
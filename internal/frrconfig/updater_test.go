@@ -2,48 +2,133 @@ package frrconfig
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/openperouter/openperouter/internal/reloaderconn"
 )
 
 func TestUpdaterForAddress(t *testing.T) {
-	tmpfile, err := os.CreateTemp("", "config")
-	if err != nil {
-		t.Fatalf("failed to create temp file: %v", err)
+	var received ReloadRequest
+	result := func(r ReloadRequest) ReloadResult {
+		return ReloadResult{Version: r.Version, Success: true}
 	}
-	defer os.Remove(tmpfile.Name())
-
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			t.Errorf("expected POST request, got %s", r.Method)
 		}
-		w.WriteHeader(http.StatusOK)
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		res := result(received)
+		if !res.Success {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_ = json.NewEncoder(w).Encode(res)
 	})
 	server := httptest.NewServer(handler)
 	defer server.Close()
 
-	updater := UpdaterForAddress(server.URL[7:], tmpfile.Name()) // Remove "http://"
+	client, err := reloaderconn.NewClient(reloaderconn.ClientOptions{})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+	updater := UpdaterForAddress(server.URL[7:], client) // Remove "http://"
 
 	err = updater(context.Background(), "test config")
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
+	if received.Config != "test config" {
+		t.Errorf("expected config %q, got %q", "test config", received.Config)
+	}
+	if received.Version != ConfigVersion("test config") {
+		t.Errorf("expected version %s, got %s", ConfigVersion("test config"), received.Version)
+	}
 
-	content, err := os.ReadFile(tmpfile.Name())
-	if err != nil {
-		t.Fatalf("failed to read temp file: %v", err)
+	// Test reload failure
+	result = func(r ReloadRequest) ReloadResult {
+		return ReloadResult{Version: r.Version, FailedStep: Reload, Output: "frr-reload output", Error: "exit status 1"}
+	}
+	err = updater(context.Background(), "test config")
+	if err == nil || !strings.Contains(err.Error(), "frr-reload output") {
+		t.Errorf("expected error with the reload output, got %v", err)
+	}
+
+	// Test reload failure with rollback
+	result = func(r ReloadRequest) ReloadResult {
+		return ReloadResult{Version: r.Version, FailedStep: Reload, Error: "exit status 1",
+			Rollback: &RollbackResult{Version: "previous", Success: true}}
+	}
+	err = updater(context.Background(), "test config")
+	if err == nil || !strings.Contains(err.Error(), "rolled back to version previous") {
+		t.Errorf("expected error with the rollback outcome, got %v", err)
+	}
+
+	// Test the passwords in the outputs are hidden
+	result = func(r ReloadRequest) ReloadResult {
+		return ReloadResult{Version: r.Version, FailedStep: Reload,
+			Output: "neighbor 192.168.1.1 password secret", Error: "exit status 1",
+			Rollback: &RollbackResult{Version: "previous", Output: "neighbor 192.168.1.2 password other", Error: "exit status 1"}}
+	}
+	err = updater(context.Background(), "test config")
+	if err == nil || strings.Contains(err.Error(), "secret") || strings.Contains(err.Error(), "other") {
+		t.Errorf("expected error without the passwords, got %v", err)
 	}
-	expectedContent := "test config"
-	if string(content) != expectedContent {
-		t.Errorf("expected content %q, got %q", expectedContent, string(content))
+	if !strings.Contains(err.Error(), "password <redacted>") {
+		t.Errorf("expected error with the redacted passwords, got %v", err)
+	}
+
+	// Test version mismatch
+	result = func(r ReloadRequest) ReloadResult {
+		return ReloadResult{Version: "other", Success: true}
+	}
+	err = updater(context.Background(), "test config")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// Test context cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = updater(ctx, "test config")
+	if err == nil {
+		t.Errorf("expected error, got nil")
 	}
 
 	// Test HTTP failure
 	server.Close()
-	err = updater("test config")
+	err = updater(context.Background(), "test config")
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
 }
+
+func TestWriteConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "frr.conf")
+	for _, config := range []string{"first config", "second"} {
+		if err := WriteConfig(path, config); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read config: %v", err)
+		}
+		if string(content) != config {
+			t.Fatalf("expected content %q, got %q", config, string(content))
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the config file to be left, got %v", entries)
+	}
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier:Apache-2.0
+
+// Package frrmetrics exposes the state of the BGP sessions, of the BFD
+// peers and of the EVPN vnis of the router as prometheus metrics, by
+// periodically querying FRR through vtysh.
+package frrmetrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/openperouter/openperouter/internal/frrstate"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "openperouter"
+
+var (
+	bgpSessionUp = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bgp", "session_up"),
+		"BGP session state (1 is up, 0 is down).",
+		[]string{"peer", "vrf"}, nil)
+
+	bgpPrefixesSent = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bgp", "prefixes_sent"),
+		"Number of prefixes advertised to the peer, across all the address families.",
+		[]string{"peer", "vrf"}, nil)
+
+	bgpPrefixesReceived = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bgp", "prefixes_received"),
+		"Number of prefixes accepted from the peer, across all the address families.",
+		[]string{"peer", "vrf"}, nil)
+
+	bgpMessagesSent = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bgp", "messages_sent_total"),
+		"Number of BGP messages sent to the peer, by type.",
+		[]string{"peer", "vrf", "type"}, nil)
+
+	bgpMessagesReceived = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bgp", "messages_received_total"),
+		"Number of BGP messages received from the peer, by type.",
+		[]string{"peer", "vrf", "type"}, nil)
+
+	bfdSessionUp = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bfd", "session_up"),
+		"BFD session state (1 is up, 0 is down).",
+		[]string{"peer", "local", "vrf"}, nil)
+
+	bfdReceiveInterval = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bfd", "receive_interval_milliseconds"),
+		"The minimum interval between the BFD control packets received from the peer.",
+		[]string{"peer", "local", "vrf"}, nil)
+
+	bfdTransmitInterval = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bfd", "transmit_interval_milliseconds"),
+		"The minimum interval between the BFD control packets sent to the peer.",
+		[]string{"peer", "local", "vrf"}, nil)
+
+	bfdDetectMultiplier = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "bfd", "detect_multiplier"),
+		"The number of BFD control packets that can be lost before declaring the session down.",
+		[]string{"peer", "local", "vrf"}, nil)
+
+	evpnVNIs = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "evpn", "vnis"),
+		"Number of EVPN vnis known to the router, by type (L2 or L3).",
+		[]string{"type"}, nil)
+
+	frrUp = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "frr", "up"),
+		"Whether the last query of FRR for the metrics succeeded (1) or failed (0).",
+		nil, nil)
+
+	pollFailures = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "frr", "poll_failures_total"),
+		"Number of times querying FRR for the metrics failed.",
+		nil, nil)
+)
+
+// Exporter is a prometheus collector serving the state of the router as
+// of the last poll. When the last poll failed, only its outcome is served.
+type Exporter struct {
+	cli frrstate.Cli
+
+	mu       sync.Mutex
+	last     frrstate.State
+	up       bool
+	failures int
+}
+
+// NewExporter returns an exporter querying FRR with the given cli.
+func NewExporter(cli frrstate.Cli) *Exporter {
+	return &Exporter{cli: cli}
+}
+
+// Run polls FRR every interval until the context is done.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.Poll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll queries FRR and replaces the state served by the exporter. If any
+// of the queries fails, the previous state is dropped, as it may not
+// reflect the router anymore.
+func (e *Exporter) Poll() {
+	s, err := frrstate.Fetch(e.cli)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		slog.Error("frr metrics poll failed", "error", err)
+		e.failures++
+		e.last = frrstate.State{}
+		e.up = false
+		return
+	}
+	e.last = s
+	e.up = true
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bgpSessionUp
+	ch <- bgpPrefixesSent
+	ch <- bgpPrefixesReceived
+	ch <- bgpMessagesSent
+	ch <- bgpMessagesReceived
+	ch <- bfdSessionUp
+	ch <- bfdReceiveInterval
+	ch <- bfdTransmitInterval
+	ch <- bfdDetectMultiplier
+	ch <- evpnVNIs
+	ch <- frrUp
+	ch <- pollFailures
+}
+
+// Collect implements prometheus.Collector.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	s := e.last
+	up := e.up
+	failures := e.failures
+	e.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(frrUp, prometheus.GaugeValue, boolToFloat(up))
+	ch <- prometheus.MustNewConstMetric(pollFailures, prometheus.CounterValue, float64(failures))
+	if !up {
+		return
+	}
+
+	for _, n := range s.Neighbors {
+		peer := n.IP.String()
+		ch <- prometheus.MustNewConstMetric(bgpSessionUp, prometheus.GaugeValue, boolToFloat(n.Connected), peer, n.VRF)
+		ch <- prometheus.MustNewConstMetric(bgpPrefixesSent, prometheus.GaugeValue, float64(n.PrefixSent), peer, n.VRF)
+		ch <- prometheus.MustNewConstMetric(bgpPrefixesReceived, prometheus.GaugeValue, float64(n.PrefixReceived), peer, n.VRF)
+
+		sent := map[string]int{
+			"open":         n.MsgStats.OpensSent,
+			"notification": n.MsgStats.NotificationsSent,
+			"update":       n.MsgStats.UpdatesSent,
+			"keepalive":    n.MsgStats.KeepalivesSent,
+			"routeRefresh": n.MsgStats.RouteRefreshSent,
+		}
+		for msgType, v := range sent {
+			ch <- prometheus.MustNewConstMetric(bgpMessagesSent, prometheus.CounterValue, float64(v), peer, n.VRF, msgType)
+		}
+		received := map[string]int{
+			"open":         n.MsgStats.OpensReceived,
+			"notification": n.MsgStats.NotificationsReceived,
+			"update":       n.MsgStats.UpdatesReceived,
+			"keepalive":    n.MsgStats.KeepalivesReceived,
+			"routeRefresh": n.MsgStats.RouteRefreshReceived,
+		}
+		for msgType, v := range received {
+			ch <- prometheus.MustNewConstMetric(bgpMessagesReceived, prometheus.CounterValue, float64(v), peer, n.VRF, msgType)
+		}
+	}
+
+	for _, p := range s.BFDPeers {
+		vrf := p.Vrf
+		if vrf == "" {
+			vrf = "default"
+		}
+		ch <- prometheus.MustNewConstMetric(bfdSessionUp, prometheus.GaugeValue, boolToFloat(p.Status == "up"), p.Peer, p.Local, vrf)
+		ch <- prometheus.MustNewConstMetric(bfdReceiveInterval, prometheus.GaugeValue, float64(p.ReceiveInterval), p.Peer, p.Local, vrf)
+		ch <- prometheus.MustNewConstMetric(bfdTransmitInterval, prometheus.GaugeValue, float64(p.TransmitInterval), p.Peer, p.Local, vrf)
+		ch <- prometheus.MustNewConstMetric(bfdDetectMultiplier, prometheus.GaugeValue, float64(p.DetectMultiplier), p.Peer, p.Local, vrf)
+	}
+
+	vnisByType := map[string]int{"L2": 0, "L3": 0}
+	for _, v := range s.VNIs {
+		vnisByType[v.Type]++
+	}
+	for vniType, count := range vnisByType {
+		ch <- prometheus.MustNewConstMetric(evpnVNIs, prometheus.GaugeValue, float64(count), vniType)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
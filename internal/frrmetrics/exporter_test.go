@@ -0,0 +1,165 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frrmetrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openperouter/openperouter/internal/frrstate"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var vtyshOutputs = map[string]string{
+	"show bgp vrf all json": `{"default": {"vrfName": "default"}, "red": {"vrfName": "red"}}`,
+	"show bgp vrf default neighbors json": `{
+  "192.168.11.2": {
+    "remoteAs": 64612,
+    "localAs": 64514,
+    "bgpState": "Established",
+    "messageStats": {"opensSent": 1, "opensRecv": 1, "updatesSent": 5, "updatesRecv": 7, "keepalivesSent": 10, "keepalivesRecv": 11, "notificationsRecv": 2, "routeRefreshRecv": 3, "totalSent": 16, "totalRecv": 24},
+    "addressFamilyInfo": {
+      "l2VpnEvpn": {"sentPrefixCounter": 4, "acceptedPrefixCounter": 6}
+    }
+  }
+}`,
+	"show bgp vrf red neighbors json": `{
+  "192.169.10.0": {
+    "remoteAs": 64515,
+    "localAs": 64514,
+    "bgpState": "Active",
+    "addressFamilyInfo": {}
+  }
+}`,
+	"show bfd peers json": `[{"peer": "192.168.11.2", "local": "192.168.11.3", "vrf": "default", "status": "up", "receive-interval": 300, "transmit-interval": 200, "detect-multiplier": 3}]`,
+	"show evpn vni json": `{
+  "100": {"vni": 100, "type": "L3", "vxlanIf": "br-pe-100", "numRemoteVteps": "n\/a", "tenantVrf": "red"},
+  "200": {"vni": 200, "type": "L2", "vxlanIf": "br-pe-200", "numRemoteVteps": 1, "tenantVrf": "red"}
+}`,
+}
+
+func fakeCli(outputs map[string]string) frrstate.Cli {
+	return func(args string) (string, error) {
+		out, ok := outputs[args]
+		if !ok {
+			return "", errors.New("unexpected command " + args)
+		}
+		return out, nil
+	}
+}
+
+func TestExporter(t *testing.T) {
+	e := NewExporter(fakeCli(vtyshOutputs))
+	e.Poll()
+
+	expected := `
+# HELP openperouter_bfd_detect_multiplier The number of BFD control packets that can be lost before declaring the session down.
+# TYPE openperouter_bfd_detect_multiplier gauge
+openperouter_bfd_detect_multiplier{local="192.168.11.3",peer="192.168.11.2",vrf="default"} 3
+# HELP openperouter_bfd_receive_interval_milliseconds The minimum interval between the BFD control packets received from the peer.
+# TYPE openperouter_bfd_receive_interval_milliseconds gauge
+openperouter_bfd_receive_interval_milliseconds{local="192.168.11.3",peer="192.168.11.2",vrf="default"} 300
+# HELP openperouter_bfd_session_up BFD session state (1 is up, 0 is down).
+# TYPE openperouter_bfd_session_up gauge
+openperouter_bfd_session_up{local="192.168.11.3",peer="192.168.11.2",vrf="default"} 1
+# HELP openperouter_bfd_transmit_interval_milliseconds The minimum interval between the BFD control packets sent to the peer.
+# TYPE openperouter_bfd_transmit_interval_milliseconds gauge
+openperouter_bfd_transmit_interval_milliseconds{local="192.168.11.3",peer="192.168.11.2",vrf="default"} 200
+# HELP openperouter_bgp_prefixes_received Number of prefixes accepted from the peer, across all the address families.
+# TYPE openperouter_bgp_prefixes_received gauge
+openperouter_bgp_prefixes_received{peer="192.168.11.2",vrf="default"} 6
+openperouter_bgp_prefixes_received{peer="192.169.10.0",vrf="red"} 0
+# HELP openperouter_bgp_prefixes_sent Number of prefixes advertised to the peer, across all the address families.
+# TYPE openperouter_bgp_prefixes_sent gauge
+openperouter_bgp_prefixes_sent{peer="192.168.11.2",vrf="default"} 4
+openperouter_bgp_prefixes_sent{peer="192.169.10.0",vrf="red"} 0
+# HELP openperouter_bgp_session_up BGP session state (1 is up, 0 is down).
+# TYPE openperouter_bgp_session_up gauge
+openperouter_bgp_session_up{peer="192.168.11.2",vrf="default"} 1
+openperouter_bgp_session_up{peer="192.169.10.0",vrf="red"} 0
+# HELP openperouter_evpn_vnis Number of EVPN vnis known to the router, by type (L2 or L3).
+# TYPE openperouter_evpn_vnis gauge
+openperouter_evpn_vnis{type="L2"} 1
+openperouter_evpn_vnis{type="L3"} 1
+# HELP openperouter_frr_poll_failures_total Number of times querying FRR for the metrics failed.
+# TYPE openperouter_frr_poll_failures_total counter
+openperouter_frr_poll_failures_total 0
+# HELP openperouter_frr_up Whether the last query of FRR for the metrics succeeded (1) or failed (0).
+# TYPE openperouter_frr_up gauge
+openperouter_frr_up 1
+`
+	err := testutil.CollectAndCompare(e, strings.NewReader(expected),
+		"openperouter_bgp_session_up",
+		"openperouter_bgp_prefixes_sent",
+		"openperouter_bgp_prefixes_received",
+		"openperouter_bfd_session_up",
+		"openperouter_bfd_receive_interval_milliseconds",
+		"openperouter_bfd_transmit_interval_milliseconds",
+		"openperouter_bfd_detect_multiplier",
+		"openperouter_evpn_vnis",
+		"openperouter_frr_poll_failures_total",
+		"openperouter_frr_up",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `
+# HELP openperouter_bgp_messages_received_total Number of BGP messages received from the peer, by type.
+# TYPE openperouter_bgp_messages_received_total counter
+openperouter_bgp_messages_received_total{peer="192.168.11.2",type="keepalive",vrf="default"} 11
+openperouter_bgp_messages_received_total{peer="192.168.11.2",type="notification",vrf="default"} 2
+openperouter_bgp_messages_received_total{peer="192.168.11.2",type="open",vrf="default"} 1
+openperouter_bgp_messages_received_total{peer="192.168.11.2",type="routeRefresh",vrf="default"} 3
+openperouter_bgp_messages_received_total{peer="192.168.11.2",type="update",vrf="default"} 7
+openperouter_bgp_messages_received_total{peer="192.169.10.0",type="keepalive",vrf="red"} 0
+openperouter_bgp_messages_received_total{peer="192.169.10.0",type="notification",vrf="red"} 0
+openperouter_bgp_messages_received_total{peer="192.169.10.0",type="open",vrf="red"} 0
+openperouter_bgp_messages_received_total{peer="192.169.10.0",type="routeRefresh",vrf="red"} 0
+openperouter_bgp_messages_received_total{peer="192.169.10.0",type="update",vrf="red"} 0
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(expected), "openperouter_bgp_messages_received_total"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExporterDropsStateOnFailure(t *testing.T) {
+	outputs := map[string]string{}
+	for k, v := range vtyshOutputs {
+		outputs[k] = v
+	}
+	e := NewExporter(fakeCli(outputs))
+	e.Poll()
+
+	delete(outputs, "show evpn vni json")
+	e.Poll()
+
+	expected := `
+# HELP openperouter_frr_poll_failures_total Number of times querying FRR for the metrics failed.
+# TYPE openperouter_frr_poll_failures_total counter
+openperouter_frr_poll_failures_total 1
+# HELP openperouter_frr_up Whether the last query of FRR for the metrics succeeded (1) or failed (0).
+# TYPE openperouter_frr_up gauge
+openperouter_frr_up 0
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the state is served again once FRR answers
+	outputs["show evpn vni json"] = vtyshOutputs["show evpn vni json"]
+	e.Poll()
+	expected = `
+# HELP openperouter_evpn_vnis Number of EVPN vnis known to the router, by type (L2 or L3).
+# TYPE openperouter_evpn_vnis gauge
+openperouter_evpn_vnis{type="L2"} 1
+openperouter_evpn_vnis{type="L3"} 1
+# HELP openperouter_frr_up Whether the last query of FRR for the metrics succeeded (1) or failed (0).
+# TYPE openperouter_frr_up gauge
+openperouter_frr_up 1
+`
+	if err := testutil.CollectAndCompare(e, strings.NewReader(expected), "openperouter_evpn_vnis", "openperouter_frr_up"); err != nil {
+		t.Fatal(err)
+	}
+}
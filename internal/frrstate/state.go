@@ -0,0 +1,140 @@
+// SPDX-License-Identifier:Apache-2.0
+
+// Package frrstate retrieves the routing state of the router from FRR,
+// and serves it to the controller through the reloader.
+package frrstate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/openperouter/openperouter/internal/frr"
+	"github.com/openperouter/openperouter/internal/reloaderconn"
+)
+
+// Path is the path the reloader serves the state of the router on.
+const Path = "/frrstate"
+
+// Cli runs the given vtysh command and returns its output.
+type Cli func(args string) (string, error)
+
+// Vtysh runs the given command against the FRR instance sharing
+// the sockets with the current process.
+func Vtysh(args string) (string, error) {
+	out, err := exec.Command("vtysh", "-c", args).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("vtysh %q failed: %w - %s", args, err, out)
+	}
+	return string(out), nil
+}
+
+// State is the routing state of the router.
+type State struct {
+	VRFs      []string        `json:"vrfs"`
+	Neighbors []*frr.Neighbor `json:"neighbors"`
+	BFDPeers  []frr.BFDPeer   `json:"bfdPeers"`
+	VNIs      []frr.EVPNVNI   `json:"vnis"`
+}
+
+// Fetch queries FRR through the given cli and returns the state of the router.
+// The neighbors are sorted by vrf and ip.
+func Fetch(cli Cli) (State, error) {
+	res := State{}
+	out, err := cli("show bgp vrf all json")
+	if err != nil {
+		return State{}, err
+	}
+	res.VRFs, err = frr.ParseVRFs(out)
+	if err != nil {
+		return State{}, err
+	}
+	for _, vrf := range res.VRFs {
+		out, err := cli(fmt.Sprintf("show bgp vrf %s neighbors json", vrf))
+		if err != nil {
+			return State{}, err
+		}
+		neighbors, err := frr.ParseNeighbours(out)
+		if err != nil {
+			return State{}, fmt.Errorf("failed to parse the neighbors of vrf %s: %w", vrf, err)
+		}
+		for _, n := range neighbors {
+			n.VRF = vrf
+		}
+		res.Neighbors = append(res.Neighbors, neighbors...)
+	}
+	sort.Slice(res.Neighbors, func(i, j int) bool {
+		if res.Neighbors[i].VRF != res.Neighbors[j].VRF {
+			return res.Neighbors[i].VRF < res.Neighbors[j].VRF
+		}
+		return res.Neighbors[i].IP.String() < res.Neighbors[j].IP.String()
+	})
+
+	out, err = cli("show bfd peers json")
+	if err != nil {
+		return State{}, err
+	}
+	res.BFDPeers, err = frr.ParseBFDPeers(out)
+	if err != nil {
+		return State{}, err
+	}
+
+	out, err = cli("show evpn vni json")
+	if err != nil {
+		return State{}, err
+	}
+	res.VNIs, err = frr.ParseEVPNVNIs(out)
+	if err != nil {
+		return State{}, err
+	}
+	return res, nil
+}
+
+// Handler serves the state of the router, retrieved through the given cli.
+func Handler(cli Cli) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "invalid method", http.StatusBadRequest)
+			return
+		}
+		state, err := Fetch(cli)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(state); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ForAddress returns the state of the router from the reloader listening
+// on the given address, reached through the given client.
+func ForAddress(ctx context.Context, address string, client *reloaderconn.Client) (State, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	requestURL := client.URL(address, Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return State{}, fmt.Errorf("failed to create the request for %s: %w", requestURL, err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return State{}, fmt.Errorf("failed to get the router state from %s: %w", address, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return State{}, fmt.Errorf("failed to get the router state from %s, status %d", address, res.StatusCode)
+	}
+	var state State
+	if err := json.NewDecoder(res.Body).Decode(&state); err != nil {
+		return State{}, fmt.Errorf("failed to decode the router state from %s: %w", address, err)
+	}
+	return state, nil
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package frrstate
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openperouter/openperouter/internal/frr"
+	"github.com/openperouter/openperouter/internal/reloaderconn"
+)
+
+var vtyshOutputs = map[string]string{
+	"show bgp vrf all json": `{"red": {"vrfName": "red"}, "default": {"vrfName": "default"}}`,
+	"show bgp vrf default neighbors json": `{
+  "192.168.11.2": {"remoteAs": 64612, "localAs": 64514, "bgpState": "Established"},
+  "192.168.11.1": {"remoteAs": 64612, "localAs": 64514, "bgpState": "Active"}
+}`,
+	"show bgp vrf red neighbors json": `{
+  "192.169.10.0": {"remoteAs": 64515, "localAs": 64514, "bgpState": "Established"}
+}`,
+	"show bfd peers json": `[{"peer": "192.168.11.2", "local": "192.168.11.3", "vrf": "default", "status": "up"}]`,
+	"show evpn vni json":  `{"100": {"vni": 100, "type": "L3", "vxlanIf": "br-pe-100", "tenantVrf": "red"}}`,
+}
+
+func fakeCli(args string) (string, error) {
+	out, ok := vtyshOutputs[args]
+	if !ok {
+		return "", errors.New("unexpected command " + args)
+	}
+	return out, nil
+}
+
+func TestFetch(t *testing.T) {
+	state, err := Fetch(fakeCli)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	expected := State{
+		VRFs: []string{"default", "red"},
+		Neighbors: []*frr.Neighbor{
+			{IP: net.ParseIP("192.168.11.1"), VRF: "default", LocalAS: "64514", RemoteAS: "64612"},
+			{IP: net.ParseIP("192.168.11.2"), VRF: "default", Connected: true, LocalAS: "64514", RemoteAS: "64612"},
+			{IP: net.ParseIP("192.169.10.0"), VRF: "red", Connected: true, LocalAS: "64514", RemoteAS: "64515"},
+		},
+		BFDPeers: []frr.BFDPeer{{Peer: "192.168.11.2", Local: "192.168.11.3", Vrf: "default", Status: "up"}},
+		VNIs:     []frr.EVPNVNI{{VNI: 100, Type: "L3", VxlanIf: "br-pe-100", TenantVRF: "red"}},
+	}
+	if !cmp.Equal(state, expected) {
+		t.Fatalf("unexpected state: %s", cmp.Diff(expected, state))
+	}
+}
+
+func TestForAddress(t *testing.T) {
+	server := httptest.NewServer(Handler(fakeCli))
+	defer server.Close()
+	client, err := reloaderconn.NewClient(reloaderconn.ClientOptions{})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+
+	state, err := ForAddress(context.Background(), strings.TrimPrefix(server.URL, "http://"), client)
+	if err != nil {
+		t.Fatalf("failed to get the state: %v", err)
+	}
+	expected, err := Fetch(fakeCli)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if !cmp.Equal(state, expected) {
+		t.Fatalf("unexpected state: %s", cmp.Diff(expected, state))
+	}
+
+	failing := httptest.NewServer(Handler(func(string) (string, error) {
+		return "", errors.New("vtysh failed")
+	}))
+	defer failing.Close()
+	if _, err := ForAddress(context.Background(), strings.TrimPrefix(failing.URL, "http://"), client); err == nil {
+		t.Fatalf("expected error when vtysh fails")
+	}
+}
@@ -5,16 +5,24 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/openperouter/openperouter/internal/metrics"
 	"github.com/vishvananda/netlink"
 )
 
-func setupBridge(params VNIParams, vrf *netlink.Vrf) (*netlink.Bridge, error) {
-
-	name := bridgeName(params.VNI)
+// setupBridge creates the bridge for the given vni, enslaving it to the
+// given vrf. If the vrf is nil, the bridge is not attached to any vrf.
+func setupBridge(vni int, vrf *netlink.Vrf) (_ *netlink.Bridge, err error) {
+	defer func(start time.Time) { metrics.ObserveHostNetworkStep(metrics.StepBridge, start, err) }(time.Now())
+	name := bridgeName(vni)
+	masterIndex := 0
+	if vrf != nil {
+		masterIndex = vrf.Index
+	}
 	link, err := netlink.LinkByName(name)
 	if err != nil && errors.As(err, &netlink.LinkNotFoundError{}) {
-		link, err = createBridge(name, vrf.Index)
+		link, err = createBridge(name, masterIndex)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create bridge %s: %w", name, err)
 		}
@@ -26,12 +34,18 @@ func setupBridge(params VNIParams, vrf *netlink.Vrf) (*netlink.Bridge, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to delete link %v: %w", link, err)
 		}
-		bridge, err = createBridge(name, vrf.Index)
+		bridge, err = createBridge(name, masterIndex)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create bridge %s: %w", name, err)
 		}
 	}
 
+	if bridge.MasterIndex != masterIndex {
+		if err := setMaster(bridge, masterIndex); err != nil {
+			return nil, fmt.Errorf("failed to set master for bridge %s: %w", name, err)
+		}
+	}
+
 	err = addrGenModeNone(bridge)
 	if err != nil {
 		return nil, fmt.Errorf("failed to set addr_gen_mode to 1 for %s: %w", bridge.Name, err)
@@ -44,10 +58,10 @@ func setupBridge(params VNIParams, vrf *netlink.Vrf) (*netlink.Bridge, error) {
 	return bridge, nil
 }
 
-func createBridge(name string, vrfIndex int) (*netlink.Bridge, error) {
+func createBridge(name string, masterIndex int) (*netlink.Bridge, error) {
 	bridge := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{
 		Name:        name,
-		MasterIndex: vrfIndex,
+		MasterIndex: masterIndex,
 	}}
 	err := netlink.LinkAdd(bridge)
 	if err != nil {
@@ -57,6 +71,15 @@ func createBridge(name string, vrfIndex int) (*netlink.Bridge, error) {
 	return bridge, nil
 }
 
+// setMaster sets the master of the given link, removing it if
+// the index is 0.
+func setMaster(link netlink.Link, masterIndex int) error {
+	if masterIndex == 0 {
+		return netlink.LinkSetNoMaster(link)
+	}
+	return netlink.LinkSetMasterByIndex(link, masterIndex)
+}
+
 const bridgePrefix = "br"
 
 func BridgeName(vni int) string {
@@ -0,0 +1,74 @@
+package hostnetwork
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// The namespaces a managed device may live in.
+const (
+	HostNamespace   = "host"
+	RouterNamespace = "router"
+)
+
+// Device is a network device managed by the controller.
+type Device struct {
+	Name      string
+	Type      string
+	Namespace string
+	OperState string
+}
+
+// ManagedDevices returns the devices managed by the controller, both in
+// the host namespace and in the given one, sorted by namespace and name.
+func ManagedDevices(targetNS string) ([]Device, error) {
+	res, err := managedDevices(HostNamespace, isManagedHostLink)
+	if err != nil {
+		return nil, err
+	}
+	ns, err := netns.GetFromName(targetNS)
+	if err != nil {
+		return nil, fmt.Errorf("ManagedDevices: Failed to get network namespace %s: %w", targetNS, err)
+	}
+	defer ns.Close()
+	if err := inNamespace(ns, func() error {
+		routerDevices, err := managedDevices(RouterNamespace, isManagedRouterLink)
+		if err != nil {
+			return err
+		}
+		res = append(res, routerDevices...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Namespace != res[j].Namespace {
+			return res[i].Namespace < res[j].Namespace
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+func managedDevices(namespace string, isManaged func(netlink.Link) bool) ([]Device, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links in the %s namespace: %w", namespace, err)
+	}
+	res := []Device{}
+	for _, l := range links {
+		if !isManaged(l) {
+			continue
+		}
+		res = append(res, Device{
+			Name:      l.Attrs().Name,
+			Type:      l.Type(),
+			Namespace: namespace,
+			OperState: l.Attrs().OperState.String(),
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,133 @@
+package hostnetwork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+type L2VNIParams struct {
+	TargetNS   string
+	VTEPIP     string
+	VNI        int
+	VXLanPort  int
+	VRF        string // the vrf the bridge is attached to, if any
+	HostMaster *HostMaster
+}
+
+type HostMaster struct {
+	Name       string
+	AutoCreate bool
+}
+
+// SetupL2VNI sets up a layer 2 vni: a bridge and a vxlan inside the
+// target namespace, and a veth connecting the bridge to the host.
+// The bridge is attached to the vrf of the params, which must already exist,
+// only if set.
+func SetupL2VNI(ctx context.Context, params L2VNIParams) error {
+	slog.DebugContext(ctx, "setting up L2VNI", "params", params)
+	defer slog.DebugContext(ctx, "end setting up L2VNI", "params", params)
+	ns, err := netns.GetFromName(params.TargetNS)
+	if err != nil {
+		return fmt.Errorf("SetupL2VNI: Failed to get network namespace %s", params.TargetNS)
+	}
+	defer ns.Close()
+
+	hostSide, peSide := vethLegsForL2VNI(params.VNI)
+	hostVeth, peVeth, err := setupVeth(ctx, hostSide, peSide, ns)
+	if err != nil {
+		return err
+	}
+
+	if params.HostMaster != nil {
+		master, err := setupHostMaster(ctx, params.HostMaster)
+		if err != nil {
+			return err
+		}
+		if hostVeth.Attrs().MasterIndex != master.Attrs().Index {
+			if err := netlink.LinkSetMaster(hostVeth, master); err != nil {
+				return fmt.Errorf("failed to set %s as master of host veth %s: %w", master.Attrs().Name, hostVeth.Attrs().Name, err)
+			}
+		}
+	}
+
+	err = netlink.LinkSetUp(hostVeth)
+	if err != nil {
+		return fmt.Errorf("could not set link up for host leg %s: %v", hostVeth.Attrs().Name, err)
+	}
+
+	if err := inNamespace(ns, func() error {
+		var vrf *netlink.Vrf
+		if params.VRF != "" {
+			link, err := netlink.LinkByName(params.VRF)
+			if err != nil {
+				return fmt.Errorf("failed to find vrf %s for l2vni %d: %w", params.VRF, params.VNI, err)
+			}
+			var ok bool
+			vrf, ok = link.(*netlink.Vrf)
+			if !ok {
+				return fmt.Errorf("link %s for l2vni %d is not a vrf", params.VRF, params.VNI)
+			}
+		}
+
+		slog.DebugContext(ctx, "setting up bridge")
+		bridge, err := setupBridge(params.VNI, vrf)
+		if err != nil {
+			return err
+		}
+
+		slog.DebugContext(ctx, "setting up vxlan")
+		err = setupVXLan(VNIParams{
+			TargetNS:  params.TargetNS,
+			VTEPIP:    params.VTEPIP,
+			VNI:       params.VNI,
+			VXLanPort: params.VXLanPort,
+		}, bridge)
+		if err != nil {
+			return err
+		}
+
+		if peVeth.Attrs().MasterIndex != bridge.Index {
+			if err := netlink.LinkSetMaster(peVeth, bridge); err != nil {
+				return fmt.Errorf("failed to set bridge %s as master of pe veth %s: %w", bridge.Name, peVeth.Attrs().Name, err)
+			}
+		}
+		err = netlink.LinkSetUp(peVeth)
+		if err != nil {
+			return fmt.Errorf("could not set link up for pe leg %s: %v", peVeth.Attrs().Name, err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// setupHostMaster returns the bridge on the host the veth of a l2vni must
+// be attached to, creating it if requested.
+func setupHostMaster(ctx context.Context, hostMaster *HostMaster) (netlink.Link, error) {
+	link, err := netlink.LinkByName(hostMaster.Name)
+	if errors.As(err, &netlink.LinkNotFoundError{}) && hostMaster.AutoCreate {
+		slog.DebugContext(ctx, "host master not found, creating", "name", hostMaster.Name)
+		link = &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: hostMaster.Name}}
+		if err := netlink.LinkAdd(link); err != nil {
+			return nil, fmt.Errorf("failed to create host master %s: %w", hostMaster.Name, err)
+		}
+		if err := netlink.LinkSetUp(link); err != nil {
+			return nil, fmt.Errorf("could not set link up for host master %s: %v", hostMaster.Name, err)
+		}
+		return link, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find host master %s: %w", hostMaster.Name, err)
+	}
+	if link.Type() != "bridge" {
+		return nil, fmt.Errorf("host master %s is not a bridge, it is %s", hostMaster.Name, link.Type())
+	}
+	return link, nil
+}
@@ -0,0 +1,195 @@
+package hostnetwork
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+func TestL2VNI(t *testing.T) {
+	cleanTest(t, testNSName)
+	setup := func() netns.NsHandle {
+		_, testNS := createTestNS(t, testNSName)
+		setupLoopback(t, testNS)
+		return testNS
+	}
+
+	t.Run("l2 vni without vrf", func(t *testing.T) {
+		testNS := setup()
+		t.Cleanup(func() {
+			cleanTest(t, testNSName)
+		})
+
+		params := L2VNIParams{
+			TargetNS:  testNSName,
+			VTEPIP:    "192.170.0.9/32",
+			VNI:       110,
+			VXLanPort: 4789,
+			HostMaster: &HostMaster{
+				Name:       "testbr110",
+				AutoCreate: true,
+			},
+		}
+
+		err := SetupL2VNI(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup l2 vni: %v", err)
+		}
+		validateL2HostLeg(t, params)
+		_ = inNamespace(testNS, func() error {
+			validateL2NS(t, params)
+			return nil
+		})
+
+		// idempotent
+		err = SetupL2VNI(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup l2 vni: %v", err)
+		}
+		validateL2HostLeg(t, params)
+		_ = inNamespace(testNS, func() error {
+			validateL2NS(t, params)
+			return nil
+		})
+	})
+
+	t.Run("l2 vni attached to l3 vni vrf", func(t *testing.T) {
+		testNS := setup()
+		t.Cleanup(func() {
+			cleanTest(t, testNSName)
+		})
+
+		l3Params := VNIParams{
+			VRF:          "testred",
+			TargetNS:     testNSName,
+			VTEPIP:       "192.170.0.9/32",
+			VethHostIPv4: "192.168.9.1/32",
+			VethNSIPv4:   "192.168.9.0/32",
+			VNI:          100,
+			VXLanPort:    4789,
+		}
+		err := SetupVNI(context.Background(), l3Params)
+		if err != nil {
+			t.Fatalf("failed to setup vni: %v", err)
+		}
+
+		params := L2VNIParams{
+			TargetNS:  testNSName,
+			VTEPIP:    "192.170.0.9/32",
+			VNI:       110,
+			VXLanPort: 4789,
+			VRF:       "testred",
+		}
+		err = SetupL2VNI(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup l2 vni: %v", err)
+		}
+		validateL2HostLeg(t, params)
+		_ = inNamespace(testNS, func() error {
+			validateL2NS(t, params)
+			return nil
+		})
+
+		// a veth with the prefix of the l2 host legs not created by us
+		foreign := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "l2hostforeign"}, PeerName: "foreignpeer"}
+		if err := netlink.LinkAdd(foreign); err != nil {
+			t.Fatalf("failed to add the foreign veth: %v", err)
+		}
+		t.Cleanup(func() {
+			_ = netlink.LinkDel(foreign)
+		})
+
+		err = RemoveNonConfiguredVNIs(testNSName, []VNIParams{l3Params}, nil)
+		if err != nil {
+			t.Fatalf("failed to remove non configured vnis: %v", err)
+		}
+		hostSide, _ := vethLegsForL2VNI(params.VNI)
+		checkLinkdeleted(t, hostSide)
+		if _, err := netlink.LinkByName(foreign.Name); err != nil {
+			t.Fatalf("expecting the foreign veth to be kept: %v", err)
+		}
+		_ = inNamespace(testNS, func() error {
+			checkLinkdeleted(t, vxLanName(params.VNI))
+			checkLinkdeleted(t, bridgeName(params.VNI))
+			validateNS(t, l3Params)
+			return nil
+		})
+	})
+}
+
+func validateL2HostLeg(t *testing.T, params L2VNIParams) {
+	t.Helper()
+	hostSide, _ := vethLegsForL2VNI(params.VNI)
+	hostLegLink, err := netlink.LinkByName(hostSide)
+	if err != nil {
+		t.Fatalf("failed to get link by name: %v", err)
+	}
+	if hostLegLink.Attrs().OperState != netlink.OperUp {
+		t.Fatalf("host leg %s is not up: %s", hostSide, hostLegLink.Attrs().OperState)
+	}
+	if params.HostMaster == nil {
+		return
+	}
+	master, err := netlink.LinkByName(params.HostMaster.Name)
+	if err != nil {
+		t.Fatalf("failed to get host master by name: %v", err)
+	}
+	if master.Type() != "bridge" {
+		t.Fatalf("host master is not a bridge: %s", master.Type())
+	}
+	if hostLegLink.Attrs().MasterIndex != master.Attrs().Index {
+		t.Fatalf("host leg master is not %s", params.HostMaster.Name)
+	}
+}
+
+func validateL2NS(t *testing.T, params L2VNIParams) {
+	t.Helper()
+	loopback, err := netlink.LinkByName(UnderlayLoopback)
+	if err != nil {
+		t.Fatalf("failed to get loopback by name: %v", err)
+	}
+
+	bridgeLink, err := netlink.LinkByName(bridgeName(params.VNI))
+	if err != nil {
+		t.Fatalf("failed to get bridge by name: %v", err)
+	}
+	bridge := bridgeLink.(*netlink.Bridge)
+	if bridge.OperState != netlink.OperUp {
+		t.Fatalf("bridge is not up: %s", bridge.OperState)
+	}
+	expectedMaster := 0
+	if params.VRF != "" {
+		vrf, err := netlink.LinkByName(params.VRF)
+		if err != nil {
+			t.Fatalf("failed to get vrf by name: %v", err)
+		}
+		expectedMaster = vrf.Attrs().Index
+	}
+	if bridge.MasterIndex != expectedMaster {
+		t.Fatalf("bridge master is %d, expected %d", bridge.MasterIndex, expectedMaster)
+	}
+
+	vxlanLink, err := netlink.LinkByName(vxLanName(params.VNI))
+	if err != nil {
+		t.Fatalf("failed to get vxlan by name: %v", err)
+	}
+	err = checkVXLanConfigured(vxlanLink.(*netlink.Vxlan), bridge.Index, loopback.Attrs().Index, VNIParams{
+		VTEPIP:    params.VTEPIP,
+		VNI:       params.VNI,
+		VXLanPort: params.VXLanPort,
+	})
+	if err != nil {
+		t.Fatalf("invalid vxlan %v", err)
+	}
+
+	_, peSide := vethLegsForL2VNI(params.VNI)
+	peLegLink, err := netlink.LinkByName(peSide)
+	if err != nil {
+		t.Fatalf("failed to get pe leg by name: %v", err)
+	}
+	if peLegLink.Attrs().MasterIndex != bridge.Index {
+		t.Fatalf("pe leg master is not the bridge")
+	}
+}
@@ -32,6 +32,20 @@ func assignIPToInterface(link netlink.Link, address string) error {
 	return nil
 }
 
+// assignIPsToInterface assigns the given addresses to the link,
+// skipping the empty ones.
+func assignIPsToInterface(link netlink.Link, addresses ...string) error {
+	for _, a := range addresses {
+		if a == "" {
+			continue
+		}
+		if err := assignIPToInterface(link, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func interfaceHasIP(link netlink.Link, address string) (bool, error) {
 	_, err := netlink.ParseAddr(address)
 	if err != nil {
@@ -49,6 +63,24 @@ func interfaceHasIP(link netlink.Link, address string) (bool, error) {
 	return false, nil
 }
 
+// removeOtherIPs removes all the global addresses from the given link,
+// except the one passed as parameter.
+func removeOtherIPs(link netlink.Link, address string) error {
+	addresses, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("removeOtherIPs: failed to list addresses for interface %s", link.Attrs().Name)
+	}
+	for _, a := range addresses {
+		if a.IPNet.String() == address || a.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if err := netlink.AddrDel(link, &a); err != nil {
+			return fmt.Errorf("removeOtherIPs: failed to remove address %s from interface %s, err %v", a.IPNet, link.Attrs().Name, err)
+		}
+	}
+	return nil
+}
+
 func addrGenModeNone(l netlink.Link) error {
 	fileName := fmt.Sprintf("/proc/sys/net/ipv6/conf/%s/addr_gen_mode", l.Attrs().Name)
 	file, err := os.OpenFile(fileName, os.O_WRONLY, 0)
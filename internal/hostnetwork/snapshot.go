@@ -0,0 +1,283 @@
+package hostnetwork
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// nicSysctls are the per interface sysctls saved in a snapshot, relative
+// to /proc/sys/net. They are reset when a nic changes namespace, as they
+// belong to the namespace and not to the device.
+var nicSysctls = []string{
+	"ipv4/conf/%s/forwarding",
+	"ipv4/conf/%s/rp_filter",
+	"ipv4/conf/%s/accept_redirects",
+	"ipv4/conf/%s/arp_ignore",
+	"ipv4/conf/%s/arp_announce",
+	"ipv6/conf/%s/disable_ipv6",
+	"ipv6/conf/%s/forwarding",
+	"ipv6/conf/%s/accept_ra",
+}
+
+// procSysNet is where the net sysctls are read from and written to.
+var procSysNet = "/proc/sys/net"
+
+// nicSnapshot is the layer 3 configuration of a nic. It is taken before moving
+// the nic into the router's namespace, replayed there, and restored when
+// the nic is given back to the host.
+// Offloads and the other settings of the device are not part of it, as
+// they are not affected by the move.
+type nicSnapshot struct {
+	Name string `json:"name"`
+	// HardwareAddr is used to find the nic when the kernel gives it back
+	// to the host with a different name.
+	HardwareAddr string            `json:"hardwareAddr,omitempty"`
+	MTU          int               `json:"mtu,omitempty"`
+	Addresses    []string          `json:"addresses,omitempty"`
+	Routes       []routeSnapshot   `json:"routes,omitempty"`
+	Sysctls      map[string]string `json:"sysctls,omitempty"`
+}
+
+type routeSnapshot struct {
+	Dst      string `json:"dst,omitempty"`
+	Gw       string `json:"gw,omitempty"`
+	Src      string `json:"src,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	Scope    int    `json:"scope,omitempty"`
+	Protocol int    `json:"protocol,omitempty"`
+}
+
+// takeNICSnapshot returns the configuration of the given link. Only the
+// routes of the main table are saved, and the addresses and the routes
+// created by the kernel are skipped, as they come back by themselves.
+func takeNICSnapshot(link netlink.Link) (nicSnapshot, error) {
+	res := nicSnapshot{
+		Name:         link.Attrs().Name,
+		HardwareAddr: link.Attrs().HardwareAddr.String(),
+		MTU:          link.Attrs().MTU,
+	}
+	addresses, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nicSnapshot{}, fmt.Errorf("failed to list addresses for %s: %w", link.Attrs().Name, err)
+	}
+	for _, a := range addresses {
+		if a.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		res.Addresses = append(res.Addresses, a.IPNet.String())
+	}
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{LinkIndex: link.Attrs().Index}, netlink.RT_FILTER_OIF)
+	if err != nil {
+		return nicSnapshot{}, fmt.Errorf("failed to list routes for %s: %w", link.Attrs().Name, err)
+	}
+	for _, r := range routes {
+		if r.Protocol == unix.RTPROT_KERNEL {
+			continue
+		}
+		s := routeSnapshot{
+			Priority: r.Priority,
+			Scope:    int(r.Scope),
+			Protocol: r.Protocol,
+		}
+		if r.Dst != nil {
+			s.Dst = r.Dst.String()
+		}
+		if r.Gw != nil {
+			s.Gw = r.Gw.String()
+		}
+		if r.Src != nil {
+			s.Src = r.Src.String()
+		}
+		res.Routes = append(res.Routes, s)
+	}
+
+	res.Sysctls, err = readNICSysctls(link.Attrs().Name)
+	if err != nil {
+		return nicSnapshot{}, err
+	}
+	return res, nil
+}
+
+// readNICSysctls returns the values of the sysctls of the given nic
+// in the current namespace. The ones not available, for example
+// because ipv6 is disabled, are skipped.
+func readNICSysctls(nic string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, s := range nicSysctls {
+		key := fmt.Sprintf(s, nic)
+		value, err := os.ReadFile(filepath.Join(procSysNet, key))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sysctl %s: %w", key, err)
+		}
+		res[key] = strings.TrimSpace(string(value))
+	}
+	return res, nil
+}
+
+// apply replays the snapshot on the given link, which is expected to have
+// the same name of the snapshotted one. The settings that cannot be applied,
+// such as the routes conflicting with the ones already in the namespace,
+// do not stop the others from being applied and are returned as not preserved.
+func (s nicSnapshot) apply(link netlink.Link) ([]string, error) {
+	notPreserved := []string{}
+	if s.MTU != 0 && link.Attrs().MTU != s.MTU {
+		if err := netlink.LinkSetMTU(link, s.MTU); err != nil {
+			notPreserved = append(notPreserved, fmt.Sprintf("mtu %d: %v", s.MTU, err))
+		}
+	}
+
+	// the sysctls go first, as they may affect how addresses and routes are handled
+	keys := make([]string, 0, len(s.Sysctls))
+	for k := range s.Sysctls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := writeSysctl(k, s.Sysctls[k]); err != nil {
+			notPreserved = append(notPreserved, fmt.Sprintf("sysctl %s=%s: %v", k, s.Sysctls[k], err))
+		}
+	}
+
+	if err := assignIPsToInterface(link, s.Addresses...); err != nil {
+		return nil, err
+	}
+
+	for _, r := range s.Routes {
+		route, err := r.toRoute(link)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route for %s: %w", s.Name, err)
+		}
+		err = netlink.RouteAdd(route)
+		if errors.Is(err, unix.EEXIST) {
+			if routeExists(route) {
+				continue
+			}
+			notPreserved = append(notPreserved, fmt.Sprintf("route %s: conflicts with an existing route", r))
+			continue
+		}
+		if err != nil {
+			notPreserved = append(notPreserved, fmt.Sprintf("route %s: %v", r, err))
+		}
+	}
+	return notPreserved, nil
+}
+
+func (r routeSnapshot) toRoute(link netlink.Link) (*netlink.Route, error) {
+	route := &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Priority:  r.Priority,
+		Scope:     netlink.Scope(r.Scope),
+		Protocol:  r.Protocol,
+	}
+	if r.Dst != "" {
+		_, dst, err := net.ParseCIDR(r.Dst)
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination %s: %w", r.Dst, err)
+		}
+		route.Dst = dst
+	}
+	route.Gw = net.ParseIP(r.Gw)
+	route.Src = net.ParseIP(r.Src)
+	return route, nil
+}
+
+func (r routeSnapshot) String() string {
+	dst := r.Dst
+	if dst == "" {
+		dst = "default"
+	}
+	res := dst
+	if r.Gw != "" {
+		res += " via " + r.Gw
+	}
+	if r.Priority != 0 {
+		res += " metric " + strconv.Itoa(r.Priority)
+	}
+	return res
+}
+
+// routeExists tells if the given route is already configured through
+// the same link, which happens when the snapshot is applied twice.
+func routeExists(route *netlink.Route) bool {
+	family := netlink.FAMILY_V4
+	if route.Gw.To4() == nil && (route.Dst == nil || route.Dst.IP.To4() == nil) {
+		family = netlink.FAMILY_V6
+	}
+	filter := netlink.RT_FILTER_OIF | netlink.RT_FILTER_DST
+	if route.Gw != nil {
+		filter |= netlink.RT_FILTER_GW
+	}
+	routes, err := netlink.RouteListFiltered(family, route, filter)
+	if err != nil {
+		return false
+	}
+	return len(routes) > 0
+}
+
+func writeSysctl(key, value string) error {
+	return os.WriteFile(filepath.Join(procSysNet, key), []byte(value), 0o644)
+}
+
+func snapshotPath(stateDir, nic string) string {
+	return filepath.Join(stateDir, nic+".json")
+}
+
+func saveNICSnapshot(stateDir string, s nicSnapshot) error {
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state dir %s: %w", stateDir, err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot for %s: %w", s.Name, err)
+	}
+	// write and rename so that a crash never leaves a partial snapshot
+	tmp := snapshotPath(stateDir, s.Name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write snapshot for %s: %w", s.Name, err)
+	}
+	if err := os.Rename(tmp, snapshotPath(stateDir, s.Name)); err != nil {
+		return fmt.Errorf("failed to save snapshot for %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+// loadNICSnapshot returns the snapshot of the given nic, and false if
+// no snapshot was saved for it.
+func loadNICSnapshot(stateDir, nic string) (nicSnapshot, bool, error) {
+	data, err := os.ReadFile(snapshotPath(stateDir, nic))
+	if errors.Is(err, os.ErrNotExist) {
+		return nicSnapshot{}, false, nil
+	}
+	if err != nil {
+		return nicSnapshot{}, false, fmt.Errorf("failed to read snapshot for %s: %w", nic, err)
+	}
+	var res nicSnapshot
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nicSnapshot{}, false, fmt.Errorf("failed to unmarshal snapshot for %s: %w", nic, err)
+	}
+	return res, true, nil
+}
+
+func deleteNICSnapshot(stateDir, nic string) error {
+	err := os.Remove(snapshotPath(stateDir, nic))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete snapshot for %s: %w", nic, err)
+	}
+	slog.Debug("nic snapshot deleted", "nic", nic)
+	return nil
+}
@@ -0,0 +1,112 @@
+package hostnetwork
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNICSnapshotStore(t *testing.T) {
+	stateDir := t.TempDir()
+	snapshot := nicSnapshot{
+		Name:      "eth1",
+		MTU:       9000,
+		Addresses: []string{"192.168.11.3/24", "2001:db8::3/64"},
+		Routes: []routeSnapshot{
+			{Dst: "10.100.0.0/24", Gw: "192.168.11.1", Priority: 100, Protocol: 4},
+		},
+		Sysctls: map[string]string{"ipv4/conf/eth1/rp_filter": "2"},
+	}
+
+	_, found, err := loadNICSnapshot(stateDir, "eth1")
+	if err != nil {
+		t.Fatalf("failed to load missing snapshot: %v", err)
+	}
+	if found {
+		t.Fatalf("expecting snapshot not to be found")
+	}
+
+	if err := saveNICSnapshot(stateDir, snapshot); err != nil {
+		t.Fatalf("failed to save snapshot: %v", err)
+	}
+	loaded, found, err := loadNICSnapshot(stateDir, "eth1")
+	if err != nil {
+		t.Fatalf("failed to load snapshot: %v", err)
+	}
+	if !found {
+		t.Fatalf("expecting snapshot to be found")
+	}
+	if !reflect.DeepEqual(loaded, snapshot) {
+		t.Fatalf("expecting snapshot %v, got %v", snapshot, loaded)
+	}
+
+	if err := deleteNICSnapshot(stateDir, "eth1"); err != nil {
+		t.Fatalf("failed to delete snapshot: %v", err)
+	}
+	_, found, err = loadNICSnapshot(stateDir, "eth1")
+	if err != nil {
+		t.Fatalf("failed to load deleted snapshot: %v", err)
+	}
+	if found {
+		t.Fatalf("expecting snapshot to be deleted")
+	}
+	// deleting twice is not an error
+	if err := deleteNICSnapshot(stateDir, "eth1"); err != nil {
+		t.Fatalf("failed to delete snapshot twice: %v", err)
+	}
+}
+
+func TestNICSysctls(t *testing.T) {
+	procSysNet = t.TempDir()
+	t.Cleanup(func() { procSysNet = "/proc/sys/net" })
+
+	// only ipv4 is available
+	ipv4Dir := filepath.Join(procSysNet, "ipv4/conf/eth1")
+	if err := os.MkdirAll(ipv4Dir, 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	for _, s := range []string{"forwarding", "rp_filter", "accept_redirects", "arp_ignore", "arp_announce"} {
+		if err := os.WriteFile(filepath.Join(ipv4Dir, s), []byte("1\n"), 0o644); err != nil {
+			t.Fatalf("failed to write sysctl %s: %v", s, err)
+		}
+	}
+
+	res, err := readNICSysctls("eth1")
+	if err != nil {
+		t.Fatalf("failed to read sysctls: %v", err)
+	}
+	if len(res) != 5 {
+		t.Fatalf("expecting 5 sysctls, got %v", res)
+	}
+	if res["ipv4/conf/eth1/rp_filter"] != "1" {
+		t.Fatalf("expecting rp_filter 1, got %v", res)
+	}
+
+	if err := writeSysctl("ipv4/conf/eth1/rp_filter", "2"); err != nil {
+		t.Fatalf("failed to write sysctl: %v", err)
+	}
+	res, err = readNICSysctls("eth1")
+	if err != nil {
+		t.Fatalf("failed to read sysctls: %v", err)
+	}
+	if res["ipv4/conf/eth1/rp_filter"] != "2" {
+		t.Fatalf("expecting rp_filter 2, got %v", res)
+	}
+}
+
+func TestRouteSnapshotString(t *testing.T) {
+	tests := []struct {
+		route    routeSnapshot
+		expected string
+	}{
+		{routeSnapshot{Gw: "192.168.11.1"}, "default via 192.168.11.1"},
+		{routeSnapshot{Dst: "10.100.0.0/24", Gw: "192.168.11.1", Priority: 100}, "10.100.0.0/24 via 192.168.11.1 metric 100"},
+		{routeSnapshot{Dst: "10.100.0.0/24"}, "10.100.0.0/24"},
+	}
+	for _, tc := range tests {
+		if tc.route.String() != tc.expected {
+			t.Fatalf("expecting %q, got %q", tc.expected, tc.route.String())
+		}
+	}
+}
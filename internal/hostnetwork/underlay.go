@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/openperouter/openperouter/internal/metrics"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
 )
@@ -15,22 +17,33 @@ const (
 	UnderlayNicAlias = "underlayNic"
 )
 
-// used to identify the interface moved into the network ns to serve
-// the underlay
-const underlayNicSpecialAddr = "172.16.1.1/32"
-
 type UnderlayParams struct {
-	MainNic  string
+	Nics     []string
 	VtepIP   string
 	TargetNS string
+	// StateDir is the directory where the configuration the nics had
+	// on the host is saved before moving them, in order to restore it
+	// when they are given back. If empty, the configuration is not saved.
+	StateDir string
+}
+
+// NICSettings are the settings of a nic that could not be preserved
+// when moving it between the host and the router namespace.
+type NICSettings struct {
+	Nic      string
+	Settings []string
 }
 
-func SetupUnderlay(ctx context.Context, params UnderlayParams) error {
+// SetupUnderlay configures the underlay in the target namespace, moving the
+// nics into it. The settings of the nics that could not be preserved are
+// returned.
+func SetupUnderlay(ctx context.Context, params UnderlayParams) (notPreserved []NICSettings, err error) {
+	defer func(start time.Time) { metrics.ObserveHostNetworkStep(metrics.StepUnderlay, start, err) }(time.Now())
 	slog.DebugContext(ctx, "setup underlay", "params", params)
 	defer slog.DebugContext(ctx, "setup underlay done")
 	ns, err := netns.GetFromName(params.TargetNS)
 	if err != nil {
-		return fmt.Errorf("setupUnderlay: Failed to find network namespace %s: %w", params.TargetNS, err)
+		return nil, fmt.Errorf("setupUnderlay: Failed to find network namespace %s: %w", params.TargetNS, err)
 	}
 	defer ns.Close()
 
@@ -51,116 +64,297 @@ func SetupUnderlay(ctx context.Context, params UnderlayParams) error {
 			return err
 		}
 
+		// the vtep ip may have changed, possibly to a different ip family
+		err = removeOtherIPs(loopback, params.VtepIP)
+		if err != nil {
+			return err
+		}
+
 		return nil
 	}); err != nil {
-		return err
+		return nil, err
 	}
 
-	err = moveUnderlayNic(ctx, params.MainNic, ns)
-	if err != nil {
-		return err
-	}
-	return nil
+	return moveUnderlayNics(ctx, params.Nics, ns, params.StateDir)
 }
 
-func moveUnderlayNic(ctx context.Context, underlayNic string, ns netns.NsHandle) error {
-	oldUnderlayNic, err := oldUnderlayInterface(ns)
+// moveUnderlayNics moves the given nics into the namespace, moving back
+// to the host the ones that were serving the underlay and are not in
+// the list anymore.
+// The nics moved into the namespace are marked by setting their alias to
+// UnderlayNicAlias.
+// The settings of the nics that could not be preserved are returned, also
+// when failing.
+func moveUnderlayNics(ctx context.Context, underlayNics []string, ns netns.NsHandle, stateDir string) ([]NICSettings, error) {
+	oldUnderlayNics, err := oldUnderlayInterfaces(ns)
 	if err != nil {
-		return fmt.Errorf("failed to get old underlay interface %w", err)
+		return nil, fmt.Errorf("failed to get old underlay interfaces %w", err)
 	}
 
-	if oldUnderlayNic != "" && oldUnderlayNic == underlayNic { // nothing to do
-		slog.DebugContext(ctx, "move underlay", "event", "underlay nic already set")
-		return nil
+	res := []NICSettings{}
+
+	toKeep := map[string]bool{}
+	for _, n := range underlayNics {
+		toKeep[n] = true
+	}
+	for _, old := range oldUnderlayNics {
+		if toKeep[old] {
+			continue
+		}
+		slog.DebugContext(ctx, "move underlay", "event", "underlay nic not configured anymore, removing", "old", old)
+		notRestored, err := removeUnderlayInterface(ctx, old, ns, stateDir)
+		res = appendNICSettings(res, old, notRestored)
+		if err != nil {
+			return res, err
+		}
 	}
 
-	if oldUnderlayNic != "" && oldUnderlayNic != underlayNic { // need to move the old one back
-		slog.DebugContext(ctx, "move underlay", "event", "different underlay nic found, removing", "old", oldUnderlayNic, "new", underlayNic)
-		if err := removeUnderlayInterface(ctx, oldUnderlayNic, ns); err != nil {
-			return err
+	for _, underlayNic := range underlayNics {
+		snapshot, onHost, err := snapshotHostNIC(ctx, underlayNic, stateDir)
+		if err != nil {
+			return res, err
 		}
+		err = moveNicToNamespace(ctx, underlayNic, ns)
+		if err != nil {
+			return res, err
+		}
+
+		if err := inNamespace(ns, func() error {
+			underlay, err := netlink.LinkByName(underlayNic)
+			if err != nil {
+				return fmt.Errorf("failed to get underlay nic by name %s: %w", underlayNic, err)
+			}
+
+			if underlay.Attrs().Alias != UnderlayNicAlias {
+				if err := netlink.LinkSetAlias(underlay, UnderlayNicAlias); err != nil {
+					return fmt.Errorf("failed to set alias for underlay nic %s: %w", underlayNic, err)
+				}
+			}
+			if err := netlink.LinkSetUp(underlay); err != nil {
+				return fmt.Errorf("could not set link up for underlay nic %s: %v", underlay.Attrs().Name, err)
+			}
+			if !onHost {
+				return nil
+			}
+			// the nic was just moved, the configuration that was lost is replayed
+			notPreserved, err := snapshot.apply(underlay)
+			if err != nil {
+				return fmt.Errorf("failed to replay the configuration of underlay nic %s: %w", underlayNic, err)
+			}
+			if len(notPreserved) > 0 {
+				slog.WarnContext(ctx, "underlay nic settings not preserved in the router namespace", "nic", underlayNic, "settings", notPreserved)
+				res = appendNICSettings(res, underlayNic, notPreserved)
+			}
+			return nil
+		}); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func appendNICSettings(settings []NICSettings, nic string, notPreserved []string) []NICSettings {
+	if len(notPreserved) == 0 {
+		return settings
 	}
+	return append(settings, NICSettings{Nic: nic, Settings: notPreserved})
+}
 
-	err = moveNicToNamespace(ctx, underlayNic, ns)
+// RemoveUnderlay gives back to the host all the nics serving the underlay,
+// restoring the configuration they had before being moved, and deletes the
+// underlay loopback from the target namespace. The settings of the nics
+// that could not be restored are returned.
+func RemoveUnderlay(ctx context.Context, targetNS, stateDir string) ([]NICSettings, error) {
+	slog.DebugContext(ctx, "remove underlay", "namespace", targetNS)
+	defer slog.DebugContext(ctx, "remove underlay done")
+	ns, err := netns.GetFromName(targetNS)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("RemoveUnderlay: Failed to find network namespace %s: %w", targetNS, err)
 	}
+	defer ns.Close()
 
-	if err := inNamespace(ns, func() error {
-		underlay, err := netlink.LinkByName(underlayNic)
+	underlayNics, err := oldUnderlayInterfaces(ns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get underlay interfaces %w", err)
+	}
+	notRestored := []NICSettings{}
+	for _, nic := range underlayNics {
+		settings, err := removeUnderlayInterface(ctx, nic, ns, stateDir)
+		notRestored = appendNICSettings(notRestored, nic, settings)
 		if err != nil {
-			return fmt.Errorf("failed to get underlay nic by name %s: %w", underlayNic, err)
+			return notRestored, err
 		}
+	}
 
-		if err := assignIPToInterface(underlay, underlayNicSpecialAddr); err != nil {
-			return err
+	return notRestored, inNamespace(ns, func() error {
+		loopback, err := netlink.LinkByName(UnderlayLoopback)
+		if errors.As(err, &netlink.LinkNotFoundError{}) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get loopback %s: %w", UnderlayLoopback, err)
 		}
-		if err := netlink.LinkSetUp(underlay); err != nil {
-			return fmt.Errorf("could not set link up for VRF %s: %v", underlay.Attrs().Name, err)
+		if err := netlink.LinkDel(loopback); err != nil {
+			return fmt.Errorf("failed to delete loopback %s: %w", UnderlayLoopback, err)
 		}
 		return nil
-	}); err != nil {
-		return err
+	})
+}
+
+// snapshotHostNIC returns the configuration of the given nic, and false if the
+// nic is not in the host namespace anymore. The configuration is also saved
+// in the state dir, if set.
+// If the nic was given back to the host because the namespace of the router
+// was deleted, its configuration is already lost and the saved one is returned.
+func snapshotHostNIC(ctx context.Context, nic, stateDir string) (nicSnapshot, bool, error) {
+	link, err := findHostNIC(ctx, nic, stateDir)
+	if err != nil {
+		return nicSnapshot{}, false, err
+	}
+	if link == nil { // already moved
+		return nicSnapshot{}, false, nil
 	}
-	return nil
+	if link.Attrs().Alias == UnderlayNicAlias && stateDir != "" {
+		saved, found, err := loadNICSnapshot(stateDir, nic)
+		if err != nil {
+			return nicSnapshot{}, false, err
+		}
+		if found {
+			slog.InfoContext(ctx, "underlay nic given back to the host, using the saved configuration", "nic", nic)
+			return saved, true, nil
+		}
+	}
+	if link.Attrs().Alias == UnderlayNicAlias {
+		slog.WarnContext(ctx, "underlay nic given back to the host with no saved configuration", "nic", nic)
+	}
+	snapshot, err := takeNICSnapshot(link)
+	if err != nil {
+		return nicSnapshot{}, false, err
+	}
+	if stateDir == "" {
+		return snapshot, true, nil
+	}
+	slog.DebugContext(ctx, "saving nic snapshot", "nic", nic, "snapshot", snapshot)
+	if err := saveNICSnapshot(stateDir, snapshot); err != nil {
+		return nicSnapshot{}, false, err
+	}
+	return snapshot, true, nil
 }
 
-func removeUnderlayInterface(ctx context.Context, oldUnderlay string, ns netns.NsHandle) error {
+// findHostNIC returns the given nic if it is in the host namespace, or nil
+// if it is not.
+// When the namespace of the router is deleted, the kernel gives the nic back
+// to the host, renaming it if its name is already taken. In that case
+// the nic is found through the hardware address saved in the state dir,
+// and renamed back.
+func findHostNIC(ctx context.Context, nic, stateDir string) (netlink.Link, error) {
+	link, err := netlink.LinkByName(nic)
+	if err == nil {
+		return link, nil
+	}
+	if !errors.As(err, &netlink.LinkNotFoundError{}) {
+		return nil, fmt.Errorf("failed to find link %s: %w", nic, err)
+	}
+	if stateDir == "" {
+		return nil, nil
+	}
+	saved, found, err := loadNICSnapshot(stateDir, nic)
+	if err != nil {
+		return nil, err
+	}
+	if !found || saved.HardwareAddr == "" {
+		return nil, nil
+	}
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+	for _, l := range links {
+		if l.Attrs().Alias != UnderlayNicAlias || l.Attrs().HardwareAddr.String() != saved.HardwareAddr {
+			continue
+		}
+		slog.InfoContext(ctx, "underlay nic given back to the host with a different name, renaming", "nic", nic, "name", l.Attrs().Name)
+		if err := netlink.LinkSetDown(l); err != nil {
+			return nil, fmt.Errorf("failed to set %s down: %w", l.Attrs().Name, err)
+		}
+		if err := netlink.LinkSetName(l, nic); err != nil {
+			return nil, fmt.Errorf("failed to rename %s to %s: %w", l.Attrs().Name, nic, err)
+		}
+		return netlink.LinkByName(nic)
+	}
+	return nil, nil
+}
+
+// removeUnderlayInterface gives the given nic back to the host, restoring
+// the configuration it had. The settings that could not be restored are
+// returned.
+func removeUnderlayInterface(ctx context.Context, oldUnderlay string, ns netns.NsHandle, stateDir string) ([]string, error) {
 	currentNS, err := netns.Get()
 	if err != nil {
-		return fmt.Errorf("failed to get current ns: %w", err)
+		return nil, fmt.Errorf("failed to get current ns: %w", err)
 	}
+	defer currentNS.Close()
 	if err := inNamespace(ns, func() error {
 		oldLink, err := netlink.LinkByName(oldUnderlay)
 		if err != nil {
 			return fmt.Errorf("failed to get old underlay by name %s under ns %s: %w", oldUnderlay, ns.String(), err)
 		}
-		addr, err := netlink.ParseAddr(underlayNicSpecialAddr)
+		err = netlink.LinkSetAlias(oldLink, "")
 		if err != nil {
-			return fmt.Errorf("failed to parse special addr %s: %w", addr, err)
-		}
-		err = netlink.AddrDel(oldLink, addr)
-		if err != nil {
-			return fmt.Errorf("failed to remove special addr from %s %s: %w", oldLink.Attrs().Name, addr, err)
+			return fmt.Errorf("failed to remove alias from %s: %w", oldLink.Attrs().Name, err)
 		}
 		if err := moveNicToNamespace(ctx, oldLink.Attrs().Name, currentNS); err != nil {
 			return err
 		}
 		return nil
 	}); err != nil {
-		return err
+		return nil, err
+	}
+
+	if stateDir == "" {
+		return nil, nil
+	}
+	snapshot, found, err := loadNICSnapshot(stateDir, oldUnderlay)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		slog.DebugContext(ctx, "no snapshot found for nic", "nic", oldUnderlay)
+		return nil, nil
+	}
+	link, err := netlink.LinkByName(oldUnderlay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nic %s moved back to the host: %w", oldUnderlay, err)
+	}
+	notPreserved, err := snapshot.apply(link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore the configuration of nic %s: %w", oldUnderlay, err)
 	}
-	return nil
+	if len(notPreserved) > 0 {
+		slog.WarnContext(ctx, "nic settings not restored on the host", "nic", oldUnderlay, "settings", notPreserved)
+	}
+	return notPreserved, deleteNICSnapshot(stateDir, oldUnderlay)
 }
 
-func oldUnderlayInterface(ns netns.NsHandle) (string, error) {
-	res := ""
+// oldUnderlayInterfaces returns the names of the interfaces inside the
+// namespace that were moved there to serve the underlay.
+func oldUnderlayInterfaces(ns netns.NsHandle) ([]string, error) {
+	res := []string{}
 	err := inNamespace(ns, func() error {
 		links, err := netlink.LinkList()
 		if err != nil {
 			return fmt.Errorf("failed to list links")
 		}
 		for _, l := range links {
-			addr, _ := netlink.AddrList(l, netlink.FAMILY_ALL)
-			slog.Debug("old underlay", "checking link", l.Attrs().Name, "addresses", addr)
-			hasIP, err := interfaceHasIP(l, underlayNicSpecialAddr)
-			if err != nil {
-				return err
-			}
-			if hasIP {
-				res = l.Attrs().Name
-				return nil
+			if l.Attrs().Alias == UnderlayNicAlias {
+				res = append(res, l.Attrs().Name)
 			}
 		}
 		return nil
 	})
 	if err != nil {
-		return "", err
-	}
-	if res != "" {
-		slog.Debug("returning found has ip", "res", res)
-		return res, nil
+		return nil, err
 	}
-	slog.Debug("returning not found")
-	return "", nil
+	slog.Debug("old underlay interfaces", "found", res)
+	return res, nil
 }
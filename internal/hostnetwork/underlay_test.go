@@ -3,6 +3,7 @@ package hostnetwork
 import (
 	"context"
 	"errors"
+	"net"
 	"os"
 	"runtime"
 	"strings"
@@ -61,11 +62,11 @@ func TestUnderlay(t *testing.T) {
 		cleanTest(t, underlayTestNS)
 		testNs := setup()
 		params := UnderlayParams{
-			MainNic:  underlayTestInterface,
+			Nics:     []string{underlayTestInterface},
 			VtepIP:   "192.168.1.1/32",
 			TargetNS: underlayTestNS,
 		}
-		err := SetupUnderlay(context.Background(), params)
+		_, err := SetupUnderlay(context.Background(), params)
 		if err != nil {
 			t.Fatalf("failed to setup underlay %s", err)
 		}
@@ -77,15 +78,15 @@ func TestUnderlay(t *testing.T) {
 		cleanTest(t, underlayTestNS)
 		testNs := setup()
 		params := UnderlayParams{
-			MainNic:  underlayTestInterface,
+			Nics:     []string{underlayTestInterface},
 			VtepIP:   "192.168.1.1/32",
 			TargetNS: underlayTestNS,
 		}
-		err := SetupUnderlay(context.Background(), params)
+		_, err := SetupUnderlay(context.Background(), params)
 		if err != nil {
 			t.Fatalf("failed to setup underlay %s", err)
 		}
-		err = SetupUnderlay(context.Background(), params)
+		_, err = SetupUnderlay(context.Background(), params)
 		if err != nil {
 			t.Fatalf("failed to setup underlay %s", err)
 		}
@@ -98,58 +99,289 @@ func TestUnderlay(t *testing.T) {
 		testNs := setup()
 
 		params := UnderlayParams{
-			MainNic:  underlayTestInterface,
+			Nics:     []string{underlayTestInterface},
 			VtepIP:   "192.168.1.1/32",
 			TargetNS: underlayTestNS,
 		}
-		err := SetupUnderlay(context.Background(), params)
+		_, err := SetupUnderlay(context.Background(), params)
 		if err != nil {
 			t.Fatalf("failed to setup underlay %s", err)
 		}
 
-		params.MainNic = underlayTestInterfaceEdit
+		params.Nics = []string{underlayTestInterfaceEdit}
 		params.VtepIP = "192.168.1.2/32"
 
-		err = SetupUnderlay(context.Background(), params)
+		_, err = SetupUnderlay(context.Background(), params)
 		if err != nil {
 			t.Fatalf("failed to setup underlay %s", err)
 		}
 
 		validateUnderlay(t, testNs, externalInterfaceEditIP, params)
+		validateNicInHost(t, underlayTestInterface, externalInterfaceIP)
+	})
+
+	t.Run("test underlay with multiple nics", func(t *testing.T) {
+		cleanTest(t, underlayTestNS)
+		testNs := setup()
+
+		params := UnderlayParams{
+			Nics:     []string{underlayTestInterface, underlayTestInterfaceEdit},
+			VtepIP:   "192.168.1.1/32",
+			TargetNS: underlayTestNS,
+		}
+		_, err := SetupUnderlay(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup underlay %s", err)
+		}
+		validateUnderlay(t, testNs, externalInterfaceIP, params, externalInterfaceEditIP)
+
+		params.Nics = []string{underlayTestInterfaceEdit}
+		_, err = SetupUnderlay(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup underlay %s", err)
+		}
+		validateUnderlay(t, testNs, externalInterfaceEditIP, params)
+		validateNicInHost(t, underlayTestInterface, externalInterfaceIP)
+	})
+
+	t.Run("test underlay with ipv6 vtep", func(t *testing.T) {
+		cleanTest(t, underlayTestNS)
+		testNs := setup()
+
+		params := UnderlayParams{
+			Nics:     []string{underlayTestInterface},
+			VtepIP:   "192.168.1.1/32",
+			TargetNS: underlayTestNS,
+		}
+		_, err := SetupUnderlay(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup underlay %s", err)
+		}
+
+		params.VtepIP = "2001:db8::1/128"
+		_, err = SetupUnderlay(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup underlay %s", err)
+		}
+
+		validateUnderlay(t, testNs, externalInterfaceIP, params)
+		_ = inNamespace(testNs, func() error {
+			loopback, err := netlink.LinkByName(UnderlayLoopback)
+			if err != nil {
+				t.Fatalf("failed to get loopback %v", err)
+			}
+			hasIP, err := interfaceHasIP(loopback, "192.168.1.1/32")
+			if err != nil {
+				t.Fatalf("failed to check loopback ip %v", err)
+			}
+			if hasIP {
+				t.Fatalf("old vtep ip still assigned to the loopback")
+			}
+			return nil
+		})
+	})
+
+	t.Run("test underlay preserves the nic configuration", func(t *testing.T) {
+		cleanTest(t, underlayTestNS)
+		testNs := setup()
+
+		link, err := netlink.LinkByName(underlayTestInterface)
+		if err != nil {
+			t.Fatalf("failed to get link %s: %v", underlayTestInterface, err)
+		}
+		err = netlink.LinkSetMTU(link, 1400)
+		if err != nil {
+			t.Fatalf("failed to set mtu for %s: %v", underlayTestInterface, err)
+		}
+		err = netlink.LinkSetUp(link)
+		if err != nil {
+			t.Fatalf("failed to set link %s up: %v", underlayTestInterface, err)
+		}
+		err = netlink.RouteAdd(underlayTestRoute(t, link))
+		if err != nil {
+			t.Fatalf("failed to add route to %s: %v", underlayTestInterface, err)
+		}
+		rpFilter := "ipv4/conf/" + underlayTestInterface + "/rp_filter"
+		err = writeSysctl(rpFilter, "2")
+		if err != nil {
+			t.Fatalf("failed to set rp_filter for %s: %v", underlayTestInterface, err)
+		}
+
+		params := UnderlayParams{
+			Nics:     []string{underlayTestInterface},
+			VtepIP:   "192.168.1.1/32",
+			TargetNS: underlayTestNS,
+		}
+		_, err = SetupUnderlay(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup underlay %s", err)
+		}
+		validateUnderlay(t, testNs, externalInterfaceIP, params)
+
+		_ = inNamespace(testNs, func() error {
+			link, err := netlink.LinkByName(underlayTestInterface)
+			if err != nil {
+				t.Fatalf("failed to get link %s: %v", underlayTestInterface, err)
+			}
+			if link.Attrs().MTU != 1400 {
+				t.Fatalf("expecting mtu 1400 for %s, got %d", underlayTestInterface, link.Attrs().MTU)
+			}
+			routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, underlayTestRoute(t, link),
+				netlink.RT_FILTER_OIF|netlink.RT_FILTER_DST|netlink.RT_FILTER_GW)
+			if err != nil {
+				t.Fatalf("failed to list routes: %v", err)
+			}
+			if len(routes) != 1 {
+				t.Fatalf("expecting route to be replayed in the namespace, got %v", routes)
+			}
+			sysctls, err := readNICSysctls(underlayTestInterface)
+			if err != nil {
+				t.Fatalf("failed to read sysctls: %v", err)
+			}
+			if sysctls[rpFilter] != "2" {
+				t.Fatalf("expecting rp_filter 2 in the namespace, got %v", sysctls)
+			}
+			return nil
+		})
+	})
+
+	t.Run("test underlay removal gives the nic back", func(t *testing.T) {
+		cleanTest(t, underlayTestNS)
+		testNs := setup()
+
+		link, err := netlink.LinkByName(underlayTestInterface)
+		if err != nil {
+			t.Fatalf("failed to get link %s: %v", underlayTestInterface, err)
+		}
+		err = netlink.LinkSetUp(link)
+		if err != nil {
+			t.Fatalf("failed to set link %s up: %v", underlayTestInterface, err)
+		}
+		err = netlink.RouteAdd(underlayTestRoute(t, link))
+		if err != nil {
+			t.Fatalf("failed to add route to %s: %v", underlayTestInterface, err)
+		}
+
+		params := UnderlayParams{
+			Nics:     []string{underlayTestInterface},
+			VtepIP:   "192.168.1.1/32",
+			TargetNS: underlayTestNS,
+			StateDir: t.TempDir(),
+		}
+		notPreserved, err := SetupUnderlay(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup underlay %s", err)
+		}
+		if len(notPreserved) > 0 {
+			t.Fatalf("expecting all the settings to be preserved, got %v", notPreserved)
+		}
+		validateUnderlay(t, testNs, externalInterfaceIP, params)
+
+		notRestored, err := RemoveUnderlay(context.Background(), underlayTestNS, params.StateDir)
+		if err != nil {
+			t.Fatalf("failed to remove underlay %s", err)
+		}
+		if len(notRestored) > 0 {
+			t.Fatalf("expecting all the settings to be restored, got %v", notRestored)
+		}
+		validateNicInHost(t, underlayTestInterface, externalInterfaceIP)
+		link, err = netlink.LinkByName(underlayTestInterface)
+		if err != nil {
+			t.Fatalf("failed to get link %s: %v", underlayTestInterface, err)
+		}
+		routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, underlayTestRoute(t, link),
+			netlink.RT_FILTER_OIF|netlink.RT_FILTER_DST|netlink.RT_FILTER_GW)
+		if err != nil {
+			t.Fatalf("failed to list routes: %v", err)
+		}
+		if len(routes) != 1 {
+			t.Fatalf("expecting route to be restored, got %v", routes)
+		}
+		_ = inNamespace(testNs, func() error {
+			checkLinkdeleted(t, UnderlayLoopback)
+			return nil
+		})
+
+		// removing twice is a no-op
+		_, err = RemoveUnderlay(context.Background(), underlayTestNS, params.StateDir)
+		if err != nil {
+			t.Fatalf("failed to remove underlay twice %s", err)
+		}
 	})
 	cleanTest(t, underlayTestNS)
 }
 
-func validateUnderlay(t *testing.T, ns netns.NsHandle, ipToValidate string, params UnderlayParams) {
+func underlayTestRoute(t *testing.T, link netlink.Link) *netlink.Route {
+	t.Helper()
+	_, dst, err := net.ParseCIDR("10.100.0.0/24")
+	if err != nil {
+		t.Fatalf("failed to parse cidr: %v", err)
+	}
+	return &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       dst,
+		Gw:        net.ParseIP("192.170.0.1"),
+	}
+}
+
+// validateUnderlay checks that the loopback and the nics of the given params are in
+// the namespace, and that each nic has the ip with the same index in ipsToValidate.
+func validateUnderlay(t *testing.T, ns netns.NsHandle, ipToValidate string, params UnderlayParams, otherIPsToValidate ...string) {
+	t.Helper()
+	ipsToValidate := append([]string{ipToValidate}, otherIPsToValidate...)
 	_ = inNamespace(ns, func() error {
 		links, err := netlink.LinkList()
 		if err != nil {
 			t.Fatalf("failed to list links %v", err)
 		}
 		loopbackFound := false
-		mainNicFound := false
+		nicsFound := 0
+		underlayNics := 0
 		for _, l := range links {
 			if l.Attrs().Name == UnderlayLoopback {
 				loopbackFound = true
 				validateIP(t, l, params.VtepIP)
 			}
-			if l.Attrs().Name == params.MainNic {
-				mainNicFound = true
-				validateIP(t, l, ipToValidate)
-				validateIP(t, l, underlayNicSpecialAddr)
+			if l.Attrs().Alias == UnderlayNicAlias {
+				underlayNics++
+			}
+			for i, nic := range params.Nics {
+				if l.Attrs().Name != nic {
+					continue
+				}
+				nicsFound++
+				validateIP(t, l, ipsToValidate[i])
+				if l.Attrs().Alias != UnderlayNicAlias {
+					t.Fatalf("nic %s is not marked as underlay, alias %q", nic, l.Attrs().Alias)
+				}
 			}
-
 		}
 		if !loopbackFound {
 			t.Fatalf("failed to find loopback in ns, links %v", links)
 		}
-		if !mainNicFound {
-			t.Fatalf("failed to find loopback in ns, links %v", links)
+		if nicsFound != len(params.Nics) {
+			t.Fatalf("failed to find nics %v in ns, links %v", params.Nics, links)
+		}
+		if underlayNics != len(params.Nics) {
+			t.Fatalf("expecting %d underlay nics in ns, found %d", len(params.Nics), underlayNics)
 		}
 
 		return nil
 	})
+}
 
+// validateNicInHost checks that the given nic was moved back to the host
+// with its address.
+func validateNicInHost(t *testing.T, nic, ipToValidate string) {
+	t.Helper()
+	link, err := netlink.LinkByName(nic)
+	if err != nil {
+		t.Fatalf("failed to find nic %s in host: %v", nic, err)
+	}
+	if link.Attrs().Alias == UnderlayNicAlias {
+		t.Fatalf("nic %s moved back to host is still marked as underlay", nic)
+	}
+	validateIP(t, link, ipToValidate)
 }
 
 func validateIP(t *testing.T, l netlink.Link, address string) {
@@ -183,7 +415,8 @@ func cleanTest(t *testing.T, namespace string) {
 	for _, l := range links {
 		if strings.HasPrefix(l.Attrs().Name, "test") ||
 			strings.HasPrefix(l.Attrs().Name, PEVethPrefix) ||
-			strings.HasPrefix(l.Attrs().Name, HostVethPrefix) {
+			strings.HasPrefix(l.Attrs().Name, HostVethPrefix) ||
+			strings.HasPrefix(l.Attrs().Name, L2HostVethPrefix) {
 			err := netlink.LinkDel(l)
 			if err != nil {
 				t.Fatalf("failed remove link %s: %v", l.Attrs().Name, err)
@@ -5,21 +5,26 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/openperouter/openperouter/internal/metrics"
 	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
 )
 
-func setupVeth(ctx context.Context, name string, targetNS netns.NsHandle) (netlink.Link, netlink.Link, error) {
-	logger := slog.Default().With("veth", name)
+// setupVeth creates the veth pair with the given legs names, moving the pe side
+// to the target namespace.
+func setupVeth(ctx context.Context, hostSide, peSide string, targetNS netns.NsHandle) (_ netlink.Link, _ netlink.Link, err error) {
+	defer func(start time.Time) { metrics.ObserveHostNetworkStep(metrics.StepVeth, start, err) }(time.Now())
+	logger := slog.Default().With("veth", hostSide)
 	logger.DebugContext(ctx, "setting up veth")
-	hostSide, _ := vethLegsForVRF(name)
 
 	link, err := netlink.LinkByName(hostSide)
 	if err != nil && errors.As(err, &netlink.LinkNotFoundError{}) {
 		logger.DebugContext(ctx, "veth does not exist, creating")
-		link, err = createVeth(name)
+		link, err = createVeth(hostSide, peSide)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -32,15 +37,15 @@ func setupVeth(ctx context.Context, name string, targetNS netns.NsHandle) (netli
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to delete link %v: %w", link, err)
 		}
-		vethHost, err = createVeth(name)
+		vethHost, err = createVeth(hostSide, peSide)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed create veth %s: %w", name, err)
+			return nil, nil, fmt.Errorf("failed create veth %s: %w", hostSide, err)
 		}
 	}
-	slog.DebugContext(ctx, "veth created veth", "veth", name)
+	slog.DebugContext(ctx, "veth created veth", "veth", hostSide)
 	peerIndex, err := netlink.VethPeerIndex(vethHost)
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not find peer veth for %s: %w", name, err)
+		return nil, nil, fmt.Errorf("could not find peer veth for %s: %w", hostSide, err)
 	}
 	vethPE, err := netlink.LinkByIndex(peerIndex)
 	alreadyInNamespace := false
@@ -52,7 +57,7 @@ func setupVeth(ctx context.Context, name string, targetNS netns.NsHandle) (netli
 			}
 			return nil
 		}); err != nil {
-			return nil, nil, fmt.Errorf("could not find peer veth by index for %s: %w", name, err)
+			return nil, nil, fmt.Errorf("could not find peer veth by index for %s: %w", hostSide, err)
 		}
 		slog.DebugContext(ctx, "pe leg already in ns", "pe veth", vethPE.Attrs().Name)
 		alreadyInNamespace = true
@@ -65,16 +70,99 @@ func setupVeth(ctx context.Context, name string, targetNS netns.NsHandle) (netli
 		slog.DebugContext(ctx, "pe leg moved to ns", "pe veth", vethPE.Attrs().Name)
 	}
 
-	slog.DebugContext(ctx, "veth is up", "vrf", name)
+	slog.DebugContext(ctx, "veth is up", "veth", hostSide)
 	return vethHost, vethPE, nil
 }
 
-func createVeth(vrfName string) (*netlink.Veth, error) {
-	hostSide, peSide := vethLegsForVRF(vrfName)
+// RemoveOrphanedVeths deletes the host legs of the veths whose pe leg is
+// neither in the host nor in the target namespace, as it was left in the
+// namespace of a router that does not exist anymore.
+func RemoveOrphanedVeths(ctx context.Context, targetNS string) error {
+	ns, err := netns.GetFromName(targetNS)
+	if err != nil {
+		return fmt.Errorf("RemoveOrphanedVeths: Failed to get network namespace %s: %w", targetNS, err)
+	}
+	defer ns.Close()
+
+	links, err := netlink.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed to list links: %w", err)
+	}
+	for _, l := range links {
+		hostLeg, ok := l.(*netlink.Veth)
+		if !ok {
+			continue
+		}
+		peSide, ok := peLegForHostLeg(hostLeg.Name)
+		if !ok {
+			continue
+		}
+		orphaned, err := isOrphanedVeth(hostLeg, peSide, ns)
+		if err != nil {
+			return err
+		}
+		if !orphaned {
+			continue
+		}
+		slog.InfoContext(ctx, "removing orphaned veth", "veth", hostLeg.Name)
+		if err := netlink.LinkDel(hostLeg); err != nil {
+			return fmt.Errorf("failed to delete orphaned veth %s: %w", hostLeg.Name, err)
+		}
+	}
+	return nil
+}
+
+// isOrphanedVeth tells if the given host leg is not connected to the
+// pe leg with the given name, in the host or in the given namespace.
+func isOrphanedVeth(hostLeg *netlink.Veth, peSide string, ns netns.NsHandle) (bool, error) {
+	peerIndex, err := netlink.VethPeerIndex(hostLeg)
+	if err != nil {
+		return true, nil
+	}
+	peer, err := netlink.LinkByIndex(peerIndex)
+	if err == nil && peer.Attrs().Name == peSide {
+		return false, nil
+	}
+	found := false
+	if err := inNamespace(ns, func() error {
+		peer, err := netlink.LinkByIndex(peerIndex)
+		if errors.As(err, &netlink.LinkNotFoundError{}) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find peer of %s: %w", hostLeg.Name, err)
+		}
+		found = peer.Attrs().Name == peSide
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	return !found, nil
+}
+
+// peLegForHostLeg returns the name of the pe leg of the veth with the
+// given host leg, and false if the veth is not one of ours.
+func peLegForHostLeg(hostSide string) (string, bool) {
+	if strings.HasPrefix(hostSide, L2HostVethPrefix) {
+		vni, err := vniForL2HostLeg(hostSide)
+		if err != nil {
+			return "", false
+		}
+		_, peSide := vethLegsForL2VNI(vni)
+		return peSide, true
+	}
+	if strings.HasPrefix(hostSide, HostVethPrefix) {
+		_, peSide := vethLegsForVRF(vrfForHostLeg(hostSide))
+		return peSide, true
+	}
+	return "", false
+}
+
+func createVeth(hostSide, peSide string) (*netlink.Veth, error) {
 	vethHost := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: hostSide}, PeerName: peSide}
 	err := netlink.LinkAdd(vethHost)
 	if err != nil {
-		return nil, fmt.Errorf("could not add veth %s: %w", vrfName, err)
+		return nil, fmt.Errorf("could not add veth %s: %w", hostSide, err)
 	}
 	return vethHost, nil
 }
@@ -85,9 +173,28 @@ func vethLegsForVRF(name string) (string, string) {
 	return hostSide, peSide
 }
 
+// vethLegsForL2VNI returns the names of the legs of the veth
+// connecting the host to the bridge of the given layer 2 vni.
+func vethLegsForL2VNI(vni int) (string, string) {
+	hostSide := fmt.Sprintf("%s%d", L2HostVethPrefix, vni)
+	peSide := fmt.Sprintf("%s%d", L2PEVethPrefix, vni)
+	return hostSide, peSide
+}
+
 const HostVethPrefix = "host"
 const PEVethPrefix = "pe"
+const L2HostVethPrefix = "l2host"
+const L2PEVethPrefix = "l2pe"
 
 func vrfForHostLeg(name string) string {
 	return strings.TrimPrefix(name, HostVethPrefix)
 }
+
+func vniForL2HostLeg(name string) (int, error) {
+	vni := strings.TrimPrefix(name, L2HostVethPrefix)
+	res, err := strconv.Atoi(vni)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get vni for l2 host leg %s", name)
+	}
+	return res, nil
+}
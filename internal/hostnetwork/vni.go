@@ -12,13 +12,15 @@ import (
 )
 
 type VNIParams struct {
-	VRF        string
-	TargetNS   string
-	VTEPIP     string
-	VethHostIP string
-	VethNSIP   string
-	VNI        int
-	VXLanPort  int
+	VRF          string
+	TargetNS     string
+	VTEPIP       string
+	VethHostIPv4 string
+	VethHostIPv6 string
+	VethNSIPv4   string
+	VethNSIPv6   string
+	VNI          int
+	VXLanPort    int
 }
 
 func SetupVNI(ctx context.Context, params VNIParams) error {
@@ -29,11 +31,12 @@ func SetupVNI(ctx context.Context, params VNIParams) error {
 		return fmt.Errorf("SetupVNI: Failed to get network namespace %s", params.TargetNS)
 	}
 
-	hostVeth, peVeth, err := setupVeth(ctx, params.VRF, ns)
+	hostSide, peSide := vethLegsForVRF(params.VRF)
+	hostVeth, peVeth, err := setupVeth(ctx, hostSide, peSide, ns)
 	if err != nil {
 		return err
 	}
-	err = assignIPToInterface(hostVeth, params.VethHostIP)
+	err = assignIPsToInterface(hostVeth, params.VethHostIPv4, params.VethHostIPv6)
 	if err != nil {
 		return err
 	}
@@ -44,28 +47,30 @@ func SetupVNI(ctx context.Context, params VNIParams) error {
 	}
 
 	if err := inNamespace(ns, func() error {
-		err = assignIPToInterface(peVeth, params.VethNSIP)
+		slog.DebugContext(ctx, "setting up vrf", "vrf", params.VRF)
+		vrf, err := setupVRF(params.VRF)
 		if err != nil {
 			return err
 		}
-		err = netlink.LinkSetUp(peVeth)
+
+		// enslaving the veth to the vrf flushes its ipv6 addresses, so
+		// the addresses must be assigned after.
+		err = netlink.LinkSetMaster(peVeth, vrf)
 		if err != nil {
-			return fmt.Errorf("could not set link up for host leg %s: %v", hostVeth, err)
+			return fmt.Errorf("failed to set vrf %s as marter of pe veth %s", vrf.Name, peVeth.Attrs().Name)
 		}
 
-		slog.DebugContext(ctx, "setting up vrf", "vrf", params.VRF)
-		vrf, err := setupVRF(params.VRF)
+		err = assignIPsToInterface(peVeth, params.VethNSIPv4, params.VethNSIPv6)
 		if err != nil {
 			return err
 		}
-
-		err = netlink.LinkSetMaster(peVeth, vrf)
+		err = netlink.LinkSetUp(peVeth)
 		if err != nil {
-			return fmt.Errorf("failed to set vrf %s as marter of pe veth %s", vrf.Name, peVeth.Attrs().Name)
+			return fmt.Errorf("could not set link up for host leg %s: %v", hostVeth, err)
 		}
 
 		slog.DebugContext(ctx, "setting up bridge")
-		bridge, err := setupBridge(params, vrf)
+		bridge, err := setupBridge(params.VNI, vrf)
 		if err != nil {
 			return err
 		}
@@ -78,7 +83,7 @@ func SetupVNI(ctx context.Context, params VNIParams) error {
 
 		/*
 			slog.DebugContext(ctx, "setting up route to host")
-			if err := addRouteToHost(vrf, params.VethHostIP, peVeth); err != nil {
+			if err := addRouteToHost(vrf, params.VethHostIPv4, peVeth); err != nil {
 				return err
 			}
 		*/
@@ -90,19 +95,37 @@ func SetupVNI(ctx context.Context, params VNIParams) error {
 	return nil
 }
 
-func RemoveNonConfiguredVNIs(ns netns.NsHandle, params []VNIParams) error {
+// RemoveNonConfiguredVNIs removes the interfaces related to the vnis and the
+// layer 2 vnis not contained in the given params, both from the host and from
+// the target namespace. Passing no params removes all of them.
+// The bridges on the host the layer 2 vnis are attached to are not removed.
+func RemoveNonConfiguredVNIs(targetNS string, params []VNIParams, l2Params []L2VNIParams) error {
+	ns, err := netns.GetFromName(targetNS)
+	if err != nil {
+		return fmt.Errorf("RemoveNonConfiguredVNIs: Failed to get network namespace %s: %w", targetNS, err)
+	}
+	defer ns.Close()
+
 	vrfs := map[string]bool{}
 	vnis := map[int]bool{}
+	l2VNIs := map[int]bool{}
 	for _, p := range params {
 		vrfs[p.VRF] = true
 		vnis[p.VNI] = true
 	}
+	for _, p := range l2Params {
+		vnis[p.VNI] = true
+		l2VNIs[p.VNI] = true
+	}
 	hostLinks, err := netlink.LinkList()
 	if err != nil {
 		return fmt.Errorf("remove non configured vnis: failed to list links: %w", err)
 	}
 	for _, hl := range hostLinks {
-		if hl.Type() == "veth" && strings.HasPrefix(hl.Attrs().Name, HostVethPrefix) {
+		if hl.Type() != "veth" {
+			continue
+		}
+		if strings.HasPrefix(hl.Attrs().Name, HostVethPrefix) {
 			vrf := vrfForHostLeg(hl.Attrs().Name)
 			if vrfs[vrf] {
 				continue
@@ -111,6 +134,20 @@ func RemoveNonConfiguredVNIs(ns netns.NsHandle, params []VNIParams) error {
 				return fmt.Errorf("remove host leg: %s %w", hl.Attrs().Name, err)
 			}
 		}
+		if strings.HasPrefix(hl.Attrs().Name, L2HostVethPrefix) {
+			vni, err := vniForL2HostLeg(hl.Attrs().Name)
+			if err != nil {
+				// not created by us, it must not block the cleanup of the others
+				slog.Warn("remove non configured vnis: skipping l2 host leg", "name", hl.Attrs().Name, "error", err)
+				continue
+			}
+			if l2VNIs[vni] {
+				continue
+			}
+			if err := netlink.LinkDel(hl); err != nil {
+				return fmt.Errorf("remove l2 host leg: %s %w", hl.Attrs().Name, err)
+			}
+		}
 	}
 
 	err = inNamespace(ns, func() error {
@@ -33,13 +33,13 @@ func TestVNI(t *testing.T) {
 		})
 
 		params := VNIParams{
-			VRF:        "testred",
-			TargetNS:   testNSName,
-			VTEPIP:     "192.170.0.9/32",
-			VethHostIP: "192.168.9.1/32",
-			VethNSIP:   "192.168.9.0/32",
-			VNI:        100,
-			VXLanPort:  4789,
+			VRF:          "testred",
+			TargetNS:     testNSName,
+			VTEPIP:       "192.170.0.9/32",
+			VethHostIPv4: "192.168.9.1/32",
+			VethNSIPv4:   "192.168.9.0/32",
+			VNI:          100,
+			VXLanPort:    4789,
 		}
 
 		err := SetupVNI(context.Background(), params)
@@ -57,6 +57,93 @@ func TestVNI(t *testing.T) {
 
 	})
 
+	t.Run("dual stack vni", func(t *testing.T) {
+		testNS := setup()
+		t.Cleanup(func() {
+			cleanTest(t, testNSName)
+		})
+
+		params := VNIParams{
+			VRF:          "testred",
+			TargetNS:     testNSName,
+			VTEPIP:       "192.170.0.9/32",
+			VethHostIPv4: "192.168.9.1/32",
+			VethNSIPv4:   "192.168.9.0/32",
+			VethHostIPv6: "2001:db8:9::1/128",
+			VethNSIPv6:   "2001:db8:9::/128",
+			VNI:          100,
+			VXLanPort:    4789,
+		}
+
+		err := SetupVNI(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup vni: %v", err)
+		}
+
+		time.Sleep(4 * time.Second)
+		validateHostLeg(t, params)
+
+		_ = inNamespace(testNS, func() error {
+			validateNS(t, params)
+			return nil
+		})
+	})
+
+	t.Run("orphaned veth is recreated", func(t *testing.T) {
+		testNS := setup()
+		t.Cleanup(func() {
+			cleanTest(t, testNSName)
+		})
+
+		params := VNIParams{
+			VRF:          "testred",
+			TargetNS:     testNSName,
+			VTEPIP:       "192.170.0.9/32",
+			VethHostIPv4: "192.168.9.1/32",
+			VethNSIPv4:   "192.168.9.0/32",
+			VNI:          100,
+			VXLanPort:    4789,
+		}
+
+		err := SetupVNI(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup vni: %v", err)
+		}
+
+		// the pe leg does not belong to the router anymore
+		_, peSide := vethLegsForVRF(params.VRF)
+		_ = inNamespace(testNS, func() error {
+			peLeg, err := netlink.LinkByName(peSide)
+			if err != nil {
+				t.Fatalf("failed to get pe leg %s: %v", peSide, err)
+			}
+			if err := netlink.LinkSetName(peLeg, "testother"); err != nil {
+				t.Fatalf("failed to rename pe leg %s: %v", peSide, err)
+			}
+			return nil
+		})
+
+		err = RemoveOrphanedVeths(context.Background(), testNSName)
+		if err != nil {
+			t.Fatalf("failed to remove orphaned veths: %v", err)
+		}
+		hostSide, _ := vethLegsForVRF(params.VRF)
+		checkLinkdeleted(t, hostSide)
+
+		err = SetupVNI(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup vni: %v", err)
+		}
+
+		time.Sleep(4 * time.Second)
+		validateHostLeg(t, params)
+
+		_ = inNamespace(testNS, func() error {
+			validateNS(t, params)
+			return nil
+		})
+	})
+
 	t.Run("multiple vnis + cleanup", func(t *testing.T) {
 		testNS := setup()
 		t.Cleanup(func() {
@@ -66,22 +153,22 @@ func TestVNI(t *testing.T) {
 		params := []VNIParams{
 			{
 
-				VRF:        "testred",
-				TargetNS:   testNSName,
-				VTEPIP:     "192.170.0.9/32",
-				VethHostIP: "192.168.9.1/32",
-				VethNSIP:   "192.168.9.0/32",
-				VNI:        100,
-				VXLanPort:  4789,
+				VRF:          "testred",
+				TargetNS:     testNSName,
+				VTEPIP:       "192.170.0.9/32",
+				VethHostIPv4: "192.168.9.1/32",
+				VethNSIPv4:   "192.168.9.0/32",
+				VNI:          100,
+				VXLanPort:    4789,
 			},
 			{
-				VRF:        "testblue",
-				TargetNS:   testNSName,
-				VTEPIP:     "192.170.0.10/32",
-				VethHostIP: "192.168.9.2/32",
-				VethNSIP:   "192.168.9.3/32",
-				VNI:        101,
-				VXLanPort:  4789,
+				VRF:          "testblue",
+				TargetNS:     testNSName,
+				VTEPIP:       "192.170.0.10/32",
+				VethHostIPv4: "192.168.9.2/32",
+				VethNSIPv4:   "192.168.9.3/32",
+				VNI:          101,
+				VXLanPort:    4789,
 			},
 		}
 		for _, p := range params {
@@ -99,7 +186,7 @@ func TestVNI(t *testing.T) {
 
 		remaining := params[0]
 		toDelete := params[1]
-		err := RemoveNonConfiguredVNIs(testNS, []VNIParams{remaining})
+		err := RemoveNonConfiguredVNIs(testNSName, []VNIParams{remaining}, nil)
 		if err != nil {
 			t.Fatalf("failed to remove non configured vnis: %v", err)
 		}
@@ -115,6 +202,35 @@ func TestVNI(t *testing.T) {
 		validateVNIIsNotConfigured(t, toDelete)
 	})
 
+	t.Run("remove all the vnis", func(t *testing.T) {
+		_ = setup()
+		t.Cleanup(func() {
+			cleanTest(t, testNSName)
+		})
+
+		params := VNIParams{
+			VRF:          "testred",
+			TargetNS:     testNSName,
+			VTEPIP:       "192.170.0.9/32",
+			VethHostIPv4: "192.168.9.1/32",
+			VethNSIPv4:   "192.168.9.0/32",
+			VNI:          100,
+			VXLanPort:    4789,
+		}
+		err := SetupVNI(context.Background(), params)
+		if err != nil {
+			t.Fatalf("failed to setup vni: %v", err)
+		}
+
+		err = RemoveNonConfiguredVNIs(testNSName, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to remove non configured vnis: %v", err)
+		}
+		hostSide, _ := vethLegsForVRF(params.VRF)
+		checkLinkdeleted(t, hostSide)
+		validateVNIIsNotConfigured(t, params)
+	})
+
 	t.Run("creation is idempotent", func(t *testing.T) {
 		testNS := setup()
 		t.Cleanup(func() {
@@ -122,13 +238,13 @@ func TestVNI(t *testing.T) {
 		})
 
 		params := VNIParams{
-			VRF:        "testred",
-			TargetNS:   testNSName,
-			VTEPIP:     "192.170.0.9/32",
-			VethHostIP: "192.168.9.1/32",
-			VethNSIP:   "192.168.9.0/32",
-			VNI:        100,
-			VXLanPort:  4789,
+			VRF:          "testred",
+			TargetNS:     testNSName,
+			VTEPIP:       "192.170.0.9/32",
+			VethHostIPv4: "192.168.9.1/32",
+			VethNSIPv4:   "192.168.9.0/32",
+			VNI:          100,
+			VXLanPort:    4789,
 		}
 
 		err := SetupVNI(context.Background(), params)
@@ -162,13 +278,18 @@ func validateHostLeg(t *testing.T, params VNIParams) {
 	if hostLegLink.Attrs().OperState != netlink.OperUp {
 		t.Fatalf("host leg %s is not up: %s", hostSide, hostLegLink.Attrs().OperState)
 	}
-	hasIP, err := interfaceHasIP(hostLegLink, params.VethHostIP)
-	if err != nil {
-		t.Fatalf("failed to undersand if host leg has ip: %v", err)
-	}
-	if !hasIP {
-		addresses, _ := netlink.AddrList(hostLegLink, netlink.FAMILY_ALL)
-		t.Fatalf("host leg doesn't have ip %s %v", params.VethHostIP, addresses)
+	for _, ip := range []string{params.VethHostIPv4, params.VethHostIPv6} {
+		if ip == "" {
+			continue
+		}
+		hasIP, err := interfaceHasIP(hostLegLink, ip)
+		if err != nil {
+			t.Fatalf("failed to undersand if host leg has ip: %v", err)
+		}
+		if !hasIP {
+			addresses, _ := netlink.AddrList(hostLegLink, netlink.FAMILY_ALL)
+			t.Fatalf("host leg doesn't have ip %s %v", ip, addresses)
+		}
 	}
 }
 
@@ -241,15 +362,20 @@ func validateNS(t *testing.T, params VNIParams) {
 		t.Fatalf("peLegLink master is not vrf")
 	}
 
-	hasIP, err := interfaceHasIP(peLegLink, params.VethNSIP)
-	if err != nil {
-		t.Fatalf("failed to undersand if pe leg has ip: %v", err)
-	}
-	if !hasIP {
-		t.Fatalf("pe leg doesn't have ip %s", params.VethNSIP)
+	for _, ip := range []string{params.VethNSIPv4, params.VethNSIPv6} {
+		if ip == "" {
+			continue
+		}
+		hasIP, err := interfaceHasIP(peLegLink, ip)
+		if err != nil {
+			t.Fatalf("failed to undersand if pe leg has ip: %v", err)
+		}
+		if !hasIP {
+			t.Fatalf("pe leg doesn't have ip %s", ip)
+		}
 	}
 
-	route, err := hostIPToRoute(vrf, params.VethHostIP, peLegLink)
+	route, err := hostIPToRoute(vrf, params.VethHostIPv4, peLegLink)
 	if err != nil {
 		t.Fatalf("failed to convert host ip to route: %v", err)
 	}
@@ -359,3 +485,22 @@ func TestIPToRoute(t *testing.T) {
 		}
 	}
 }
+
+func TestPELegForHostLeg(t *testing.T) {
+	tests := []struct {
+		hostSide string
+		expected string
+		ours     bool
+	}{
+		{"hostred", "pered", true},
+		{"l2host110", "l2pe110", true},
+		{"l2hostfoo", "", false},
+		{"eth0", "", false},
+	}
+	for _, tc := range tests {
+		res, ours := peLegForHostLeg(tc.hostSide)
+		if ours != tc.ours || res != tc.expected {
+			t.Fatalf("expecting %q %v for %s, got %q %v", tc.expected, tc.ours, tc.hostSide, res, ours)
+		}
+	}
+}
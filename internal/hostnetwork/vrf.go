@@ -4,12 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"time"
 
+	"github.com/openperouter/openperouter/internal/metrics"
 	"github.com/vishvananda/netlink"
 )
 
 // setupVRF creates a new VRF and sets it up.
-func setupVRF(name string) (*netlink.Vrf, error) {
+func setupVRF(name string) (_ *netlink.Vrf, err error) {
+	defer func(start time.Time) { metrics.ObserveHostNetworkStep(metrics.StepVRF, start, err) }(time.Now())
 	link, err := netlink.LinkByName(name)
 	if err != nil && errors.As(err, &netlink.LinkNotFoundError{}) {
 		link, err = createVRF(name)
@@ -6,11 +6,14 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/openperouter/openperouter/internal/metrics"
 	"github.com/vishvananda/netlink"
 )
 
-func setupVXLan(params VNIParams, bridge *netlink.Bridge) error {
+func setupVXLan(params VNIParams, bridge *netlink.Bridge) (err error) {
+	defer func(start time.Time) { metrics.ObserveHostNetworkStep(metrics.StepVXLan, start, err) }(time.Now())
 	loopback, err := netlink.LinkByName(UnderlayLoopback)
 	if err != nil {
 		return fmt.Errorf("failed to get loopback by name: %w", err)
@@ -81,7 +84,10 @@ func checkVXLanConfigured(vxLan *netlink.Vxlan, bridgeIndex, loopbackIndex int,
 		return fmt.Errorf("learning is enabled")
 	}
 
-	vtepIP, _, _ := net.ParseCIDR(params.VTEPIP) // TODO
+	vtepIP, _, err := net.ParseCIDR(params.VTEPIP)
+	if err != nil {
+		return fmt.Errorf("failed to parse vtep ip %s: %w", params.VTEPIP, err)
+	}
 	if !vxLan.SrcAddr.Equal(vtepIP) {
 		return fmt.Errorf("src addr is not one coming from params: %v, %v", vxLan.SrcAddr, params.VTEPIP)
 	}
@@ -100,7 +106,10 @@ func createVXLan(params VNIParams, bridge *netlink.Bridge) (*netlink.Vxlan, erro
 
 	name := vxLanName(params.VNI)
 
-	vtepIP, _, _ := net.ParseCIDR(params.VTEPIP) // TODO
+	vtepIP, _, err := net.ParseCIDR(params.VTEPIP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vtep ip %s: %w", params.VTEPIP, err)
+	}
 	vxlan := &netlink.Vxlan{LinkAttrs: netlink.LinkAttrs{
 		Name:        name,
 		MasterIndex: bridge.Index,
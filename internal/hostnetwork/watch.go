@@ -0,0 +1,155 @@
+package hostnetwork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// WatchHost calls notify every time a link, an address or a route related
+// to a link managed by the controller changes in the host namespace.
+// It returns when the context is done or when the subscription fails.
+func WatchHost(ctx context.Context, notify func(reason string)) error {
+	return watchNamespace(ctx, nil, isManagedHostLink, notify)
+}
+
+// WatchRouterNamespace calls notify every time a link, an address or a route
+// related to a link managed by the controller changes in the given namespace.
+// It returns when the context is done or when the subscription fails.
+// Note that the namespace is kept alive for as long as it is watched.
+func WatchRouterNamespace(ctx context.Context, targetNS string, notify func(reason string)) error {
+	ns, err := netns.GetFromName(targetNS)
+	if err != nil {
+		return fmt.Errorf("WatchRouterNamespace: Failed to get network namespace %s: %w", targetNS, err)
+	}
+	defer ns.Close()
+	return watchNamespace(ctx, &ns, isManagedRouterLink, notify)
+}
+
+// isManagedHostLink tells if the given link of the host namespace
+// is one of those handled by the controller.
+func isManagedHostLink(l netlink.Link) bool {
+	name := l.Attrs().Name
+	return strings.HasPrefix(name, HostVethPrefix) ||
+		strings.HasPrefix(name, L2HostVethPrefix) ||
+		l.Attrs().Alias == UnderlayNicAlias
+}
+
+// isManagedRouterLink tells if the given link of the router namespace
+// is one of those handled by the controller.
+func isManagedRouterLink(l netlink.Link) bool {
+	name := l.Attrs().Name
+	if l.Type() == "vrf" || name == UnderlayLoopback || l.Attrs().Alias == UnderlayNicAlias {
+		return true
+	}
+	for _, prefix := range []string{PEVethPrefix, L2PEVethPrefix, bridgePrefix, vniPrefix} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isManagedRoute tells if the given route may have been set by the
+// controller. The routes installed by routing daemons, such as the ones
+// FRR learns via BGP, change continuously and are ignored.
+func isManagedRoute(r netlink.Route) bool {
+	return r.Protocol <= unix.RTPROT_STATIC
+}
+
+func watchNamespace(ctx context.Context, ns *netns.NsHandle, isManaged func(netlink.Link) bool, notify func(reason string)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(done)
+	}()
+
+	subscriptionErrors := make(chan error, 3)
+	onError := func(err error) {
+		select {
+		case subscriptionErrors <- err:
+		default:
+		}
+	}
+
+	links := make(chan netlink.LinkUpdate)
+	if err := netlink.LinkSubscribeWithOptions(links, done, netlink.LinkSubscribeOptions{
+		Namespace:     ns,
+		ErrorCallback: onError,
+		ListExisting:  true,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to link changes: %w", err)
+	}
+	addresses := make(chan netlink.AddrUpdate)
+	if err := netlink.AddrSubscribeWithOptions(addresses, done, netlink.AddrSubscribeOptions{
+		Namespace:     ns,
+		ErrorCallback: onError,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to address changes: %w", err)
+	}
+	routes := make(chan netlink.RouteUpdate)
+	if err := netlink.RouteSubscribeWithOptions(routes, done, netlink.RouteSubscribeOptions{
+		Namespace:     ns,
+		ErrorCallback: onError,
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to route changes: %w", err)
+	}
+
+	// the indexes of the managed links, used to tell if an address or
+	// a route belongs to one of them
+	managed := map[int]string{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-subscriptionErrors:
+			return fmt.Errorf("netlink subscription failed: %w", err)
+		case u, ok := <-links:
+			if !ok {
+				return errors.New("link subscription closed")
+			}
+			index := u.Attrs().Index
+			_, wasManaged := managed[index]
+			if u.Header.Type == unix.RTM_DELLINK {
+				delete(managed, index)
+				if wasManaged {
+					notify("link " + u.Attrs().Name + " deleted")
+				}
+				continue
+			}
+			if !isManaged(u.Link) {
+				delete(managed, index)
+				if wasManaged {
+					notify("link " + u.Attrs().Name + " changed")
+				}
+				continue
+			}
+			managed[index] = u.Attrs().Name
+			notify("link " + u.Attrs().Name + " changed")
+		case u, ok := <-addresses:
+			if !ok {
+				return errors.New("address subscription closed")
+			}
+			if name, ok := managed[u.LinkIndex]; ok {
+				notify("address " + u.LinkAddress.String() + " of " + name + " changed")
+			}
+		case u, ok := <-routes:
+			if !ok {
+				return errors.New("route subscription closed")
+			}
+			if !isManagedRoute(u.Route) {
+				continue
+			}
+			if name, ok := managed[u.LinkIndex]; ok {
+				notify("route " + u.Route.String() + " of " + name + " changed")
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package hostnetwork
+
+import (
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestIsManagedLink(t *testing.T) {
+	tests := []struct {
+		name          string
+		link          netlink.Link
+		managedHost   bool
+		managedRouter bool
+	}{
+		{
+			name:          "host veth",
+			link:          &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "hostred"}},
+			managedHost:   true,
+			managedRouter: false,
+		},
+		{
+			name:          "l2 host veth",
+			link:          &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "l2host110"}},
+			managedHost:   true,
+			managedRouter: false,
+		},
+		{
+			name:          "pe veth",
+			link:          &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "pered"}},
+			managedHost:   false,
+			managedRouter: true,
+		},
+		{
+			name:          "underlay nic",
+			link:          &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth1", Alias: UnderlayNicAlias}},
+			managedHost:   true,
+			managedRouter: true,
+		},
+		{
+			name:          "vrf",
+			link:          &netlink.Vrf{LinkAttrs: netlink.LinkAttrs{Name: "red"}},
+			managedHost:   false,
+			managedRouter: true,
+		},
+		{
+			name:          "bridge",
+			link:          &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "br100"}},
+			managedHost:   false,
+			managedRouter: true,
+		},
+		{
+			name:          "loopback",
+			link:          &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: UnderlayLoopback}},
+			managedHost:   false,
+			managedRouter: true,
+		},
+		{
+			name:          "pod interface",
+			link:          &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}},
+			managedHost:   false,
+			managedRouter: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := isManagedHostLink(tc.link); res != tc.managedHost {
+				t.Fatalf("expecting managed in host %v, got %v", tc.managedHost, res)
+			}
+			if res := isManagedRouterLink(tc.link); res != tc.managedRouter {
+				t.Fatalf("expecting managed in router %v, got %v", tc.managedRouter, res)
+			}
+		})
+	}
+}
+
+func TestIsManagedRoute(t *testing.T) {
+	if !isManagedRoute(netlink.Route{Protocol: unix.RTPROT_BOOT}) {
+		t.Fatalf("expecting boot routes to be managed")
+	}
+	if isManagedRoute(netlink.Route{Protocol: unix.RTPROT_BGP}) {
+		t.Fatalf("expecting bgp routes not to be managed")
+	}
+}
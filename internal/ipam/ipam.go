@@ -2,6 +2,7 @@ package ipam
 
 import (
 	"fmt"
+	"math"
 	"net"
 
 	"github.com/apparentlymart/go-cidr/cidr"
@@ -37,7 +38,12 @@ func VTEPIp(pool string, index int) (string, error) {
 	if len(ips) != 1 {
 		return "", fmt.Errorf("vtepIP, expecting 1 ip, got %v", ips)
 	}
-	return ips[0].IP.String() + "/32", nil
+	_, bits := ips[0].Mask.Size()
+	vtep := net.IPNet{
+		IP:   ips[0].IP,
+		Mask: net.CIDRMask(bits, bits),
+	}
+	return vtep.String(), nil
 }
 
 // cidrElem returns the ith elem of len size for the given cidr.
@@ -51,9 +57,10 @@ func cidrElem(pool string, mask int, index int) (*net.IPNet, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get %d address from %s: %w", index, pool, err)
 	}
+	_, bits := ipNet.Mask.Size()
 	return &net.IPNet{
 		IP:   ip,
-		Mask: net.CIDRMask(mask, 32),
+		Mask: net.CIDRMask(mask, bits),
 	}, nil
 }
 
@@ -64,6 +71,7 @@ func sliceCIDR(pool string, index, size int) ([]net.IPNet, error) {
 		return nil, fmt.Errorf("failed to parse cidr %s: %w", pool, err)
 	}
 
+	_, bits := ipNet.Mask.Size()
 	res := []net.IPNet{}
 	for i := 0; i < size; i++ {
 		ipIndex := size*index + i
@@ -73,7 +81,7 @@ func sliceCIDR(pool string, index, size int) ([]net.IPNet, error) {
 		}
 		ipNet := net.IPNet{
 			IP:   ip,
-			Mask: net.CIDRMask(31, 32),
+			Mask: net.CIDRMask(bits-1, bits),
 		}
 
 		res = append(res, ipNet)
@@ -88,5 +96,11 @@ func IPsInCIDR(pool string) (uint64, error) {
 		return 0, fmt.Errorf("failed to parse cidr %s: %w", pool, err)
 	}
 
+	// AddressCount overflows for prefixes with 64 or more host bits,
+	// which are common for ipv6.
+	ones, bits := ipNet.Mask.Size()
+	if bits-ones >= 64 {
+		return math.MaxUint64, nil
+	}
 	return cidr.AddressCount(ipNet), nil
 }
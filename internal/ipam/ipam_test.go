@@ -1,6 +1,7 @@
 package ipam
 
 import (
+	"math"
 	"testing"
 )
 
@@ -29,6 +30,14 @@ func TestIPam(t *testing.T) {
 			"192.168.1.3/31",
 			false,
 		},
+		{
+			"ipv6",
+			"2001:db8::/64",
+			1,
+			"2001:db8::2/127",
+			"2001:db8::3/127",
+			false,
+		},
 	}
 
 	for _, tc := range tests {
@@ -100,3 +109,70 @@ func TestVethIPs(t *testing.T) {
 	}
 
 }
+
+func TestVTEPIp(t *testing.T) {
+	tests := []struct {
+		name       string
+		pool       string
+		index      int
+		expected   string
+		shouldFail bool
+	}{
+		{
+			"ipv4",
+			"100.65.0.0/24",
+			1,
+			"100.65.0.1/32",
+			false,
+		}, {
+			"ipv6",
+			"2001:db8::/64",
+			2,
+			"2001:db8::2/128",
+			false,
+		}, {
+			"out of range",
+			"100.65.0.0/30",
+			4,
+			"",
+			true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := VTEPIp(tc.pool, tc.index)
+			if err != nil && !tc.shouldFail {
+				t.Fatalf("got error %v while should not fail", err)
+			}
+			if err == nil && tc.shouldFail {
+				t.Fatalf("was expecting error, didn't fail")
+			}
+			if res != tc.expected {
+				t.Fatalf("was expecting %s, got %s", tc.expected, res)
+			}
+		})
+	}
+}
+
+func TestIPsInCIDR(t *testing.T) {
+	tests := []struct {
+		name     string
+		pool     string
+		expected uint64
+	}{
+		{"ipv4", "100.65.0.0/24", 256},
+		{"ipv6", "2001:db8::/120", 256},
+		{"large ipv6", "2001:db8::/64", math.MaxUint64},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := IPsInCIDR(tc.pool)
+			if err != nil {
+				t.Fatalf("got error %v while should not fail", err)
+			}
+			if res != tc.expected {
+				t.Fatalf("was expecting %d, got %d", tc.expected, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Package metrics contains the prometheus collectors exposed by the
+// controller, registered in the controller-runtime registry.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "openperouter"
+
+// The steps of the host network configuration whose latency is tracked.
+const (
+	StepUnderlay = "underlay"
+	StepVeth     = "veth"
+	StepVRF      = "vrf"
+	StepBridge   = "bridge"
+	StepVXLan    = "vxlan"
+)
+
+// The reasons an FRR reload fails.
+const (
+	FRRConversionFailed = "conversion"
+	FRRReloadFailed     = "reload"
+)
+
+var (
+	reconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "reconciles_total",
+		Help:      "The number of reconciliations of the node, by result.",
+	}, []string{"result"})
+
+	reconcileDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "reconcile_duration_seconds",
+		Help:      "The duration of the reconciliations of the node.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+
+	lastSuccessfulReconcile = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "controller",
+		Name:      "last_successful_reconcile_timestamp_seconds",
+		Help:      "The time of the last reconciliation of the node that succeeded.",
+	})
+
+	frrReloadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "frr",
+		Name:      "reload_duration_seconds",
+		Help:      "The time it takes to render the FRR configuration from the resources and have the router reload it.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+
+	frrReloadsSkipped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "frr",
+		Name:      "reloads_skipped_total",
+		Help:      "The number of FRR reloads skipped because the configuration was already applied.",
+	})
+
+	frrReloadFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "frr",
+		Name:      "reload_failures_total",
+		Help:      "The number of failures in rendering or reloading the FRR configuration, by reason.",
+	}, []string{"reason"})
+
+	hostNetworkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "hostnetwork",
+		Name:      "apply_duration_seconds",
+		Help:      "The time it takes to apply each step of the host network configuration.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"step"})
+
+	hostNetworkFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "hostnetwork",
+		Name:      "apply_failures_total",
+		Help:      "The number of failures in applying each step of the host network configuration.",
+	}, []string{"step"})
+
+	configuredVNIs = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "configured_vnis",
+		Help:      "The number of vnis configured on the node, by type (l3 or l2).",
+	}, []string{"type"})
+
+	nodeIndex = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_index",
+		Help:      "The index of the node, used to assign the addresses to it.",
+	})
+
+	ipamPoolUtilization = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "ipam",
+		Name:      "pool_utilization_ratio",
+		Help:      "The fraction of the addresses of each pool needed to serve all the nodes of the cluster.",
+	}, []string{"pool", "owner", "cidr"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		reconciles,
+		reconcileDuration,
+		lastSuccessfulReconcile,
+		frrReloadDuration,
+		frrReloadsSkipped,
+		frrReloadFailures,
+		hostNetworkDuration,
+		hostNetworkFailures,
+		configuredVNIs,
+		nodeIndex,
+		ipamPoolUtilization,
+	)
+}
+
+// ObserveReconcile tracks a reconciliation started at the given time,
+// which failed if err is not nil.
+func ObserveReconcile(start time.Time, err error) {
+	reconcileDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconciles.WithLabelValues("error").Inc()
+		return
+	}
+	reconciles.WithLabelValues("success").Inc()
+	lastSuccessfulReconcile.SetToCurrentTime()
+}
+
+// ObserveFRRReload tracks a reload of the FRR configuration started at
+// the given time, which failed if err is not nil.
+func ObserveFRRReload(start time.Time, err error) {
+	frrReloadDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		frrReloadFailures.WithLabelValues(FRRReloadFailed).Inc()
+	}
+}
+
+// FRRReloadSkipped tracks a reload of the FRR configuration skipped
+// because the configuration did not change.
+func FRRReloadSkipped() {
+	frrReloadsSkipped.Inc()
+}
+
+// FRRConversionFailure tracks a failure in converting the resources
+// to the FRR configuration.
+func FRRConversionFailure() {
+	frrReloadFailures.WithLabelValues(FRRConversionFailed).Inc()
+}
+
+// ObserveHostNetworkStep tracks a step of the host network configuration
+// started at the given time, which failed if err is not nil.
+func ObserveHostNetworkStep(step string, start time.Time, err error) {
+	hostNetworkDuration.WithLabelValues(step).Observe(time.Since(start).Seconds())
+	if err != nil {
+		hostNetworkFailures.WithLabelValues(step).Inc()
+	}
+}
+
+// SetNodeIndex sets the index of the node.
+func SetNodeIndex(index int) {
+	nodeIndex.Set(float64(index))
+}
+
+// SetConfiguredVNIs sets the number of l3 and l2 vnis configured on the node.
+func SetConfiguredVNIs(l3, l2 int) {
+	configuredVNIs.WithLabelValues("l3").Set(float64(l3))
+	configuredVNIs.WithLabelValues("l2").Set(float64(l2))
+}
+
+// PoolUtilization is the utilization of an ipam pool, the owner being
+// the resource the pool belongs to.
+type PoolUtilization struct {
+	Pool  string
+	Owner string
+	CIDR  string
+	Ratio float64
+}
+
+// SetIPAMPoolsUtilization replaces the utilization of the ipam pools with
+// the given one, so that the pools not in use anymore are not reported.
+func SetIPAMPoolsUtilization(pools []PoolUtilization) {
+	ipamPoolUtilization.Reset()
+	for _, p := range pools {
+		ipamPoolUtilization.WithLabelValues(p.Pool, p.Owner, p.CIDR).Set(p.Ratio)
+	}
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier:Apache-2.0
+
+// Package nodeindex allocates to each node the index its addresses
+// are derived from.
+package nodeindex
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// nodeIndexLabel marks the configmaps used to allocate the node indexes.
+	nodeIndexLabel = "openperouter.io/node-index"
+	// nodeIndexPrefix is the prefix of the name of the configmap claiming an index.
+	// Having the index in the name guarantees that the claim is atomic, as only
+	// one configmap with a given name can be created.
+	nodeIndexPrefix  = "node-index-"
+	nodeIndexNodeKey = "node"
+	nodeIndexKey     = "index"
+)
+
+// ForNode returns the index allocated to the given node, claiming the lowest
+// free one if the node does not have one yet.
+// Each index is claimed by creating a configmap owned by the node, so that
+// the index is sticky across the lifecycle of the other nodes and it is
+// freed by the garbage collector when the node is deleted.
+func ForNode(ctx context.Context, cli client.Client, namespace string, node *v1.Node) (int, error) {
+	res := 0
+	err := retry.OnError(retry.DefaultRetry, apierrors.IsAlreadyExists, func() error {
+		var allocations v1.ConfigMapList
+		if err := cli.List(ctx, &allocations, client.InNamespace(namespace), client.HasLabels{nodeIndexLabel}); err != nil {
+			return fmt.Errorf("failed to list node index allocations: %w", err)
+		}
+		index, found, err := allocatedIndex(allocations.Items, node)
+		if err != nil {
+			return err
+		}
+		if found {
+			res = index
+			return nil
+		}
+
+		index, err = firstFreeIndex(allocations.Items)
+		if err != nil {
+			return err
+		}
+		// if another node claimed the same index in the meanwhile, the creation
+		// fails with AlreadyExists and we try again with the next free index.
+		if err := cli.Create(ctx, indexAllocation(namespace, node, index)); err != nil {
+			return err
+		}
+		res = index
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate index for node %s: %w", node.Name, err)
+	}
+	return res, nil
+}
+
+// Allocated returns the number of indexes spanned by the allocations,
+// which is the highest index claimed plus one. As the indexes are sticky,
+// it can be higher than the number of nodes.
+func Allocated(ctx context.Context, cli client.Reader, namespace string) (int, error) {
+	var allocations v1.ConfigMapList
+	if err := cli.List(ctx, &allocations, client.InNamespace(namespace), client.HasLabels{nodeIndexLabel}); err != nil {
+		return 0, fmt.Errorf("failed to list node index allocations: %w", err)
+	}
+	res := 0
+	for _, a := range allocations.Items {
+		index, err := indexFromAllocation(&a)
+		if err != nil {
+			return 0, err
+		}
+		res = max(res, index+1)
+	}
+	return res, nil
+}
+
+// allocatedIndex returns the index allocated to the given node, if any.
+// Allocations belonging to a previous incarnation of a node with the
+// same name are ignored, as they are going to be garbage collected.
+func allocatedIndex(allocations []v1.ConfigMap, node *v1.Node) (int, bool, error) {
+	res := 0
+	found := false
+	for _, a := range allocations {
+		if a.Data[nodeIndexNodeKey] != node.Name || !ownedBy(&a, node) {
+			continue
+		}
+		index, err := indexFromAllocation(&a)
+		if err != nil {
+			return 0, false, err
+		}
+		if found {
+			return 0, false, fmt.Errorf("node %s has conflicting indexes %d and %d", node.Name, res, index)
+		}
+		res = index
+		found = true
+	}
+	return res, found, nil
+}
+
+// firstFreeIndex returns the lowest index not claimed by any allocation.
+func firstFreeIndex(allocations []v1.ConfigMap) (int, error) {
+	used := map[int]bool{}
+	for _, a := range allocations {
+		index, err := indexFromAllocation(&a)
+		if err != nil {
+			return 0, err
+		}
+		used[index] = true
+	}
+	for i := 0; ; i++ {
+		if !used[i] {
+			return i, nil
+		}
+	}
+}
+
+func indexFromAllocation(a *v1.ConfigMap) (int, error) {
+	index, err := strconv.Atoi(a.Data[nodeIndexKey])
+	if err != nil {
+		return 0, fmt.Errorf("invalid index in allocation %s/%s: %w", a.Namespace, a.Name, err)
+	}
+	if a.Name != nodeIndexPrefix+strconv.Itoa(index) {
+		return 0, fmt.Errorf("allocation %s/%s does not match index %d", a.Namespace, a.Name, index)
+	}
+	return index, nil
+}
+
+func indexAllocation(namespace string, node *v1.Node, index int) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      nodeIndexPrefix + strconv.Itoa(index),
+			Namespace: namespace,
+			Labels:    map[string]string{nodeIndexLabel: ""},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "Node",
+					Name:       node.Name,
+					UID:        node.UID,
+				},
+			},
+		},
+		Data: map[string]string{
+			nodeIndexNodeKey: node.Name,
+			nodeIndexKey:     strconv.Itoa(index),
+		},
+	}
+}
+
+func ownedBy(obj metav1.Object, node *v1.Node) bool {
+	for _, o := range obj.GetOwnerReferences() {
+		if o.Kind == "Node" && o.UID == node.UID {
+			return true
+		}
+	}
+	return false
+}
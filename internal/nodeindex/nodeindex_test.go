@@ -0,0 +1,154 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package nodeindex
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const testNamespace = "openperouter-system"
+
+func TestForNode(t *testing.T) {
+	node := func(name string) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name + "-uid")}}
+	}
+	cli := fake.NewClientBuilder().Build()
+	ctx := context.Background()
+
+	expectIndex := func(n *v1.Node, expected int) {
+		t.Helper()
+		index, err := ForNode(ctx, cli, testNamespace, n)
+		if err != nil {
+			t.Fatalf("unexpected error allocating index for %s: %v", n.Name, err)
+		}
+		if index != expected {
+			t.Fatalf("expecting index %d for %s, got %d", expected, n.Name, index)
+		}
+	}
+
+	expectIndex(node("node0"), 0)
+	expectIndex(node("node1"), 1)
+	expectIndex(node("node2"), 2)
+	// the index is sticky
+	expectIndex(node("node1"), 1)
+
+	// node1 deleted, the garbage collector removes its allocation
+	if err := cli.Delete(ctx, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: nodeIndexPrefix + "1", Namespace: testNamespace}}); err != nil {
+		t.Fatalf("failed to delete allocation: %v", err)
+	}
+	expectIndex(node("node2"), 2)
+	expectIndex(node("node3"), 1)
+
+	// a node recreated with the same name does not reuse the allocation of the old one
+	recreated := node("node0")
+	recreated.UID = "node0-new-uid"
+	expectIndex(recreated, 3)
+}
+
+func TestForNodeConflict(t *testing.T) {
+	n := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", UID: "node0-uid"}}
+	cli := fake.NewClientBuilder().WithObjects(
+		indexAllocation(testNamespace, n, 0),
+		indexAllocation(testNamespace, n, 1),
+	).Build()
+
+	_, err := ForNode(context.Background(), cli, testNamespace, n)
+	if err == nil || !strings.Contains(err.Error(), "conflicting indexes") {
+		t.Fatalf("expecting conflict error, got %v", err)
+	}
+}
+
+func TestForNodeAlreadyClaimed(t *testing.T) {
+	n := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", UID: "node1-uid"}}
+	other := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", UID: "node0-uid"}}
+	// simulates another node claiming index 0 after the allocations were listed
+	cli := fake.NewClientBuilder().WithInterceptorFuncs(claimOnFirstCreate(indexAllocation(testNamespace, other, 0))).Build()
+
+	index, err := ForNode(context.Background(), cli, testNamespace, n)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if index != 1 {
+		t.Fatalf("expecting index 1, got %d", index)
+	}
+}
+
+// claimOnFirstCreate creates the given allocation right before the first
+// creation performed through the client.
+func claimOnFirstCreate(claim *v1.ConfigMap) interceptor.Funcs {
+	claimed := false
+	return interceptor.Funcs{
+		Create: func(ctx context.Context, cli client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if !claimed {
+				claimed = true
+				if err := cli.Create(ctx, claim.DeepCopy()); err != nil {
+					return err
+				}
+			}
+			return cli.Create(ctx, obj, opts...)
+		},
+	}
+}
+
+func TestAllocated(t *testing.T) {
+	node := func(name string) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name + "-uid")}}
+	}
+
+	tests := []struct {
+		name        string
+		allocations []client.Object
+		expected    int
+	}{
+		{
+			name:     "no allocations",
+			expected: 0,
+		},
+		{
+			name: "contiguous indexes",
+			allocations: []client.Object{
+				indexAllocation(testNamespace, node("node0"), 0),
+				indexAllocation(testNamespace, node("node1"), 1),
+			},
+			expected: 2,
+		},
+		{
+			name: "indexes freed by deleted nodes",
+			allocations: []client.Object{
+				indexAllocation(testNamespace, node("node0"), 0),
+				indexAllocation(testNamespace, node("node3"), 3),
+			},
+			expected: 4,
+		},
+		{
+			name: "allocations in other namespaces",
+			allocations: []client.Object{
+				indexAllocation(testNamespace, node("node0"), 0),
+				indexAllocation("other", node("node5"), 5),
+			},
+			expected: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := fake.NewClientBuilder().WithObjects(tc.allocations...).Build()
+			res, err := Allocated(context.Background(), cli, testNamespace)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res != tc.expected {
+				t.Fatalf("expecting %d indexes, got %d", tc.expected, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package reloaderconn
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSOptions are the files the certificates used for mutual TLS are read
+// from. They are read again when they change, as when the secret they are
+// mounted from is rotated.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// CAFile is the bundle of the CAs the certificate of the peer must be
+	// signed by.
+	CAFile string
+}
+
+// enabled tells if mutual TLS is configured.
+func (o TLSOptions) enabled() bool {
+	return o.CertFile != "" || o.KeyFile != "" || o.CAFile != ""
+}
+
+func (o TLSOptions) validate() error {
+	if !o.enabled() {
+		return nil
+	}
+	if o.CertFile == "" || o.KeyFile == "" || o.CAFile == "" {
+		return errors.New("the certificate, the key and the CA bundle are all required for mutual TLS")
+	}
+	return nil
+}
+
+// fileStamp identifies the version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// certStore holds the certificate and the CA pool read from the files of
+// the given options, loading them again when the files change.
+type certStore struct {
+	options TLSOptions
+
+	mu     sync.Mutex
+	stamps []fileStamp
+	cert   *tls.Certificate
+	pool   *x509.CertPool
+}
+
+func newCertStore(options TLSOptions) (*certStore, error) {
+	s := &certStore{options: options}
+	if _, _, err := s.current(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// current returns the certificate and the CA pool, loading them again if
+// any of the files changed. When they can't be loaded, the ones loaded
+// before are kept, as the files may be caught in the middle of a rotation.
+func (s *certStore) current() (*tls.Certificate, *x509.CertPool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stamps, err := s.fileStamps()
+	if err == nil && s.cert != nil && equalStamps(stamps, s.stamps) {
+		return s.cert, s.pool, nil
+	}
+	if err == nil {
+		err = s.load()
+	}
+	if err != nil {
+		if s.cert == nil {
+			return nil, nil, err
+		}
+		slog.Error("failed to reload the certificates, using the previous ones", "error", err)
+		return s.cert, s.pool, nil
+	}
+	s.stamps = stamps
+	return s.cert, s.pool, nil
+}
+
+func (s *certStore) load() error {
+	cert, err := tls.LoadX509KeyPair(s.options.CertFile, s.options.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the certificate %s: %w", s.options.CertFile, err)
+	}
+	ca, err := os.ReadFile(s.options.CAFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the CA bundle %s: %w", s.options.CAFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("no certificates found in the CA bundle %s", s.options.CAFile)
+	}
+	s.cert = &cert
+	s.pool = pool
+	return nil
+}
+
+func (s *certStore) fileStamps() ([]fileStamp, error) {
+	res := []fileStamp{}
+	for _, f := range []string{s.options.CertFile, s.options.KeyFile, s.options.CAFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", f, err)
+		}
+		res = append(res, fileStamp{modTime: info.ModTime(), size: info.Size()})
+	}
+	return res, nil
+}
+
+func equalStamps(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
+
+// serverConfig returns the configuration of a server requiring the
+// clients to present a certificate signed by the CAs.
+func (s *certStore) serverConfig() (*tls.Config, error) {
+	cert, pool, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// clientConfig returns the configuration of a client verifying that the
+// server presents a certificate for the given name, signed by the CAs.
+func (s *certStore) clientConfig(serverName string) (*tls.Config, error) {
+	cert, pool, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*cert},
+		RootCAs:      pool,
+		ServerName:   serverName,
+	}, nil
+}
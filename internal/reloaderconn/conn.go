@@ -0,0 +1,167 @@
+// SPDX-License-Identifier:Apache-2.0
+
+// Package reloaderconn implements the channel between the controller and
+// the reloader: plain HTTP or HTTP over mutual TLS, on TCP or on a Unix
+// socket placed in the volume the two share.
+package reloaderconn
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultServerName is the name the certificate of the reloader is
+// verified against, as the address of the router pod is not known when
+// the certificate is issued.
+const DefaultServerName = "openperouter-reloader"
+
+// ClientOptions configure how the controller reaches the reloader.
+type ClientOptions struct {
+	TLS TLSOptions
+	// ServerName is the name the certificate of the reloader is verified
+	// against, DefaultServerName if empty.
+	ServerName string
+	// Socket is the path of the Unix socket the reloader listens on. When
+	// set, the address of the reloader is ignored.
+	Socket string
+}
+
+// Client sends the requests to the reloader.
+type Client struct {
+	http   *http.Client
+	scheme string
+	socket string
+}
+
+// NewClient returns a client reaching the reloader as described by the
+// given options.
+func NewClient(opts ClientOptions) (*Client, error) {
+	if err := opts.TLS.validate(); err != nil {
+		return nil, err
+	}
+	res := &Client{scheme: "http", socket: opts.Socket}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	dial := dialer.DialContext
+	if opts.Socket != "" {
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", opts.Socket)
+		}
+		transport.DialContext = dial
+		transport.Proxy = nil
+	}
+	if opts.TLS.enabled() {
+		store, err := newCertStore(opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		serverName := opts.ServerName
+		if serverName == "" {
+			serverName = DefaultServerName
+		}
+		// the configuration is built for each connection, so that the
+		// rotated certificates are picked up
+		transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			config, err := store.clientConfig(serverName)
+			if err != nil {
+				return nil, err
+			}
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, config)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("tls handshake with %s failed: %w", addr, err)
+			}
+			return tlsConn, nil
+		}
+		res.scheme = "https"
+	}
+	res.http = &http.Client{Transport: transport}
+	return res, nil
+}
+
+// URL returns the URL of the given path on the reloader listening on the
+// given address.
+func (c *Client) URL(address, path string) string {
+	if c.socket != "" {
+		address = "reloader"
+	}
+	return fmt.Sprintf("%s://%s%s", c.scheme, address, path)
+}
+
+// Do sends the given request to the reloader.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.http.Do(req)
+}
+
+// ServerOptions configure how the reloader accepts the requests.
+type ServerOptions struct {
+	TLS TLSOptions
+	// Socket is the path of the Unix socket to listen on instead of the
+	// bind address.
+	Socket string
+}
+
+// Listen returns the listener the reloader accepts the requests from, as
+// described by the given options.
+func Listen(bindAddress string, opts ServerOptions) (net.Listener, error) {
+	if err := opts.TLS.validate(); err != nil {
+		return nil, err
+	}
+	var store *certStore
+	if opts.TLS.enabled() {
+		var err error
+		store, err = newCertStore(opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var listener net.Listener
+	var err error
+	if opts.Socket != "" {
+		listener, err = listenUnix(opts.Socket)
+	} else {
+		listener, err = net.Listen("tcp", bindAddress)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return listener, nil
+	}
+	return tls.NewListener(listener, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return store.serverConfig()
+		},
+	}), nil
+}
+
+// listenUnix listens on the Unix socket at the given path, accessible to
+// its owner only.
+func listenUnix(path string) (net.Listener, error) {
+	// the socket left by a previous instance
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove the stale socket %s: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set the permissions of %s: %w", path, err)
+	}
+	return listener, nil
+}
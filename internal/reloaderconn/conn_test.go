@@ -0,0 +1,228 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package reloaderconn
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// rotations is the number of certificates written by the tests.
+var rotations int
+
+// testCA issues the certificates used by the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the ca key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create the ca: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse the ca: %v", err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// writeCert writes a certificate for the given name issued by the ca, its
+// key and the ca bundle to the given directory, returning their paths.
+func (ca *testCA) writeCert(t *testing.T, dir, name string) TLSOptions {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create the certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal the key: %v", err)
+	}
+	res := TLSOptions{
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	// the modification time changes with each rotation, as the files
+	// may be rewritten within the resolution of the clock
+	rotations++
+	modTime := time.Now().Add(time.Duration(rotations) * time.Second)
+	for path, content := range map[string][]byte{
+		res.CertFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		res.KeyFile:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		res.CAFile:   ca.pem,
+	} {
+		if err := os.WriteFile(path, content, 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("failed to set the modification time of %s: %v", path, err)
+		}
+	}
+	return res
+}
+
+func serve(t *testing.T, opts ServerOptions) net.Listener {
+	t.Helper()
+	listener, err := Listen("127.0.0.1:0", opts)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	})}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { server.Close() })
+	return listener
+}
+
+func get(client *Client, address string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, client.URL(address, "/"), nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	_, err = io.ReadAll(res.Body)
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverTLS := ca.writeCert(t, t.TempDir(), DefaultServerName)
+	listener := serve(t, ServerOptions{TLS: serverTLS})
+	address := listener.Addr().String()
+
+	client, err := NewClient(ClientOptions{TLS: ca.writeCert(t, t.TempDir(), "controller")})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+	if err := get(client, address); err != nil {
+		t.Fatalf("expected the request to succeed, got %v", err)
+	}
+
+	plain, err := NewClient(ClientOptions{})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+	if err := get(plain, address); err == nil {
+		t.Fatalf("expected the plain http request to fail")
+	}
+
+	otherCA := newTestCA(t)
+	untrustedDir := t.TempDir()
+	untrusted, err := NewClient(ClientOptions{TLS: otherCA.writeCert(t, untrustedDir, "controller")})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+	if err := get(untrusted, address); err == nil {
+		t.Fatalf("expected the request with a certificate from another ca to fail")
+	}
+
+	wrongName, err := NewClient(ClientOptions{TLS: ca.writeCert(t, t.TempDir(), "controller"), ServerName: "other"})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+	if err := get(wrongName, address); err == nil {
+		t.Fatalf("expected the request to fail when the server name does not match")
+	}
+
+	// the server trusts the other ca after the rotation, and the client
+	// now presents the certificate it issued
+	ca.writeCert(t, filepath.Dir(serverTLS.CertFile), DefaultServerName)
+	if err := os.WriteFile(serverTLS.CAFile, append(append([]byte{}, ca.pem...), otherCA.pem...), 0600); err != nil {
+		t.Fatalf("failed to rotate the ca bundle: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(untrustedDir, "ca.crt"), ca.pem, 0600); err != nil {
+		t.Fatalf("failed to rotate the client ca bundle: %v", err)
+	}
+	if err := get(untrusted, address); err != nil {
+		t.Fatalf("expected the request to succeed after the rotation, got %v", err)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "reloader.sock")
+	// a socket left by a previous instance is replaced
+	if err := os.WriteFile(socket, nil, 0600); err != nil {
+		t.Fatalf("failed to create the stale socket: %v", err)
+	}
+	serve(t, ServerOptions{Socket: socket})
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("failed to stat the socket: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the socket to be accessible to its owner only, got %s", info.Mode().Perm())
+	}
+
+	client, err := NewClient(ClientOptions{Socket: socket})
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+	// the address is ignored
+	if err := get(client, "192.0.2.1:9080"); err != nil {
+		t.Fatalf("expected the request to succeed, got %v", err)
+	}
+}
+
+func TestIncompleteTLSOptions(t *testing.T) {
+	if _, err := NewClient(ClientOptions{TLS: TLSOptions{CertFile: "tls.crt"}}); err == nil {
+		t.Fatalf("expected error with no key and ca")
+	}
+	if _, err := Listen("127.0.0.1:0", ServerOptions{TLS: TLSOptions{CAFile: "ca.crt"}}); err == nil {
+		t.Fatalf("expected error with no certificate and key")
+	}
+}
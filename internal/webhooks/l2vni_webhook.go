@@ -0,0 +1,86 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-per-io-openperouter-github-io-v1alpha1-l2vni,mutating=false,failurePolicy=fail,groups=per.io.openperouter.github.io,resources=l2vnis,versions=v1alpha1,name=l2vnivalidationwebhook.openperouter.io,sideEffects=None,admissionReviewVersions=v1
+
+// L2VNIValidator validates an l2vni against the existing resources.
+type L2VNIValidator struct {
+	client    client.Reader
+	namespace string
+}
+
+var _ admission.CustomValidator = &L2VNIValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (v *L2VNIValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	l2vni, ok := obj.(*v1alpha1.L2VNI)
+	if !ok {
+		return nil, fmt.Errorf("expected an l2vni, got %T", obj)
+	}
+	return nil, v.validate(ctx, nil, l2vni)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (v *L2VNIValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	old, ok := oldObj.(*v1alpha1.L2VNI)
+	if !ok {
+		return nil, fmt.Errorf("expected an l2vni, got %T", oldObj)
+	}
+	l2vni, ok := newObj.(*v1alpha1.L2VNI)
+	if !ok {
+		return nil, fmt.Errorf("expected an l2vni, got %T", newObj)
+	}
+	// the updates not touching the spec, as the ones adding or removing
+	// the finalizers, and the ones of objects being deleted are not
+	// validated: they must go through even if the configuration is invalid
+	if l2vni.DeletionTimestamp != nil || reflect.DeepEqual(old.Spec, l2vni.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, old, l2vni)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (v *L2VNIValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate checks the resources with the given l2vni, which replaces the old
+// one on update, returning only the errors caused by the change.
+func (v *L2VNIValidator) validate(ctx context.Context, old, l2vni *v1alpha1.L2VNI) error {
+	existing, err := existingResources(ctx, v.client, v.namespace)
+	if err != nil {
+		return err
+	}
+	before := existing
+	if old != nil {
+		before.l2vnis = l2vnisWith(existing.l2vnis, old)
+	}
+	after := existing
+	after.l2vnis = l2vnisWith(existing.l2vnis, l2vni)
+	return changeErrors(before, after)
+}
+
+// l2vnisWith returns the given list of l2vnis, where the one
+// matching the given l2vni is replaced or added.
+func l2vnisWith(l2vnis []v1alpha1.L2VNI, l2vni *v1alpha1.L2VNI) []v1alpha1.L2VNI {
+	res := []v1alpha1.L2VNI{}
+	for _, v := range l2vnis {
+		if sameObject(&v, l2vni) {
+			continue
+		}
+		res = append(res, v)
+	}
+	return append(res, *l2vni.DeepCopy())
+}
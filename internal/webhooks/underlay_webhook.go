@@ -0,0 +1,87 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-per-io-openperouter-github-io-v1alpha1-underlay,mutating=false,failurePolicy=fail,groups=per.io.openperouter.github.io,resources=underlays,versions=v1alpha1,name=underlayvalidationwebhook.openperouter.io,sideEffects=None,admissionReviewVersions=v1
+
+// UnderlayValidator validates an underlay against the
+// existing resources.
+type UnderlayValidator struct {
+	client    client.Reader
+	namespace string
+}
+
+var _ admission.CustomValidator = &UnderlayValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (v *UnderlayValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	underlay, ok := obj.(*v1alpha1.Underlay)
+	if !ok {
+		return nil, fmt.Errorf("expected an underlay, got %T", obj)
+	}
+	return nil, v.validate(ctx, nil, underlay)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (v *UnderlayValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	old, ok := oldObj.(*v1alpha1.Underlay)
+	if !ok {
+		return nil, fmt.Errorf("expected an underlay, got %T", oldObj)
+	}
+	underlay, ok := newObj.(*v1alpha1.Underlay)
+	if !ok {
+		return nil, fmt.Errorf("expected an underlay, got %T", newObj)
+	}
+	// the updates not touching the spec, as the ones adding or removing
+	// the finalizers, and the ones of objects being deleted are not
+	// validated: they must go through even if the configuration is invalid
+	if underlay.DeletionTimestamp != nil || reflect.DeepEqual(old.Spec, underlay.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, old, underlay)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (v *UnderlayValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate checks the resources with the given underlay, which replaces the old
+// one on update, returning only the errors caused by the change.
+func (v *UnderlayValidator) validate(ctx context.Context, old, underlay *v1alpha1.Underlay) error {
+	existing, err := existingResources(ctx, v.client, v.namespace)
+	if err != nil {
+		return err
+	}
+	before := existing
+	if old != nil {
+		before.underlays = underlaysWith(existing.underlays, old)
+	}
+	after := existing
+	after.underlays = underlaysWith(existing.underlays, underlay)
+	return changeErrors(before, after)
+}
+
+// underlaysWith returns the given list of underlays, where the one
+// matching the given underlay is replaced or added.
+func underlaysWith(underlays []v1alpha1.Underlay, underlay *v1alpha1.Underlay) []v1alpha1.Underlay {
+	res := []v1alpha1.Underlay{}
+	for _, u := range underlays {
+		if sameObject(&u, underlay) {
+			continue
+		}
+		res = append(res, u)
+	}
+	return append(res, *underlay.DeepCopy())
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-per-io-openperouter-github-io-v1alpha1-vni,mutating=false,failurePolicy=fail,groups=per.io.openperouter.github.io,resources=vnis,versions=v1alpha1,name=vnivalidationwebhook.openperouter.io,sideEffects=None,admissionReviewVersions=v1
+
+// VNIValidator validates a vni against the existing resources.
+type VNIValidator struct {
+	client    client.Reader
+	namespace string
+}
+
+var _ admission.CustomValidator = &VNIValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (v *VNIValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	vni, ok := obj.(*v1alpha1.VNI)
+	if !ok {
+		return nil, fmt.Errorf("expected a vni, got %T", obj)
+	}
+	return nil, v.validate(ctx, nil, vni)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type.
+func (v *VNIValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	old, ok := oldObj.(*v1alpha1.VNI)
+	if !ok {
+		return nil, fmt.Errorf("expected a vni, got %T", oldObj)
+	}
+	vni, ok := newObj.(*v1alpha1.VNI)
+	if !ok {
+		return nil, fmt.Errorf("expected a vni, got %T", newObj)
+	}
+	// the updates not touching the spec, as the ones adding or removing
+	// the finalizers, and the ones of objects being deleted are not
+	// validated: they must go through even if the configuration is invalid
+	if vni.DeletionTimestamp != nil || reflect.DeepEqual(old.Spec, vni.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, old, vni)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+func (v *VNIValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate checks the resources with the given vni, which replaces the old
+// one on update, returning only the errors caused by the change.
+func (v *VNIValidator) validate(ctx context.Context, old, vni *v1alpha1.VNI) error {
+	existing, err := existingResources(ctx, v.client, v.namespace)
+	if err != nil {
+		return err
+	}
+	before := existing
+	if old != nil {
+		before.vnis = vnisWith(existing.vnis, old)
+	}
+	after := existing
+	after.vnis = vnisWith(existing.vnis, vni)
+	return changeErrors(before, after)
+}
+
+// vnisWith returns the given list of vnis, where the one
+// matching the given vni is replaced or added.
+func vnisWith(vnis []v1alpha1.VNI, vni *v1alpha1.VNI) []v1alpha1.VNI {
+	res := []v1alpha1.VNI{}
+	for _, v := range vnis {
+		if sameObject(&v, vni) {
+			continue
+		}
+		res = append(res, v)
+	}
+	return append(res, *vni.DeepCopy())
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	"github.com/openperouter/openperouter/internal/conversion"
+	"github.com/openperouter/openperouter/internal/nodeindex"
+	v1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Setup registers the validating webhooks for the openperouter resources
+// against the given manager. The indexes of the nodes are allocated in
+// the given namespace.
+func Setup(mgr ctrl.Manager, namespace string) error {
+	if err := ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.Underlay{}).
+		WithValidator(&UnderlayValidator{client: mgr.GetClient(), namespace: namespace}).
+		Complete(); err != nil {
+		return fmt.Errorf("failed to create the underlay webhook: %w", err)
+	}
+	if err := ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.VNI{}).
+		WithValidator(&VNIValidator{client: mgr.GetClient(), namespace: namespace}).
+		Complete(); err != nil {
+		return fmt.Errorf("failed to create the vni webhook: %w", err)
+	}
+	if err := ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.L2VNI{}).
+		WithValidator(&L2VNIValidator{client: mgr.GetClient(), namespace: namespace}).
+		Complete(); err != nil {
+		return fmt.Errorf("failed to create the l2vni webhook: %w", err)
+	}
+	return nil
+}
+
+// resources contains the existing resources the object being validated
+// is merged into.
+type resources struct {
+	underlays []v1alpha1.Underlay
+	vnis      []v1alpha1.VNI
+	l2vnis    []v1alpha1.L2VNI
+	nodes     []v1.Node
+	// indexes is the number of node indexes allocated, the addresses
+	// of the nodes are derived from.
+	indexes int
+}
+
+func existingResources(ctx context.Context, cli client.Reader, namespace string) (resources, error) {
+	var underlays v1alpha1.UnderlayList
+	if err := cli.List(ctx, &underlays); err != nil {
+		return resources{}, fmt.Errorf("failed to list underlays: %w", err)
+	}
+	var vnis v1alpha1.VNIList
+	if err := cli.List(ctx, &vnis); err != nil {
+		return resources{}, fmt.Errorf("failed to list vnis: %w", err)
+	}
+	var l2vnis v1alpha1.L2VNIList
+	if err := cli.List(ctx, &l2vnis); err != nil {
+		return resources{}, fmt.Errorf("failed to list l2vnis: %w", err)
+	}
+	var nodes v1.NodeList
+	if err := cli.List(ctx, &nodes); err != nil {
+		return resources{}, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	indexes, err := nodeindex.Allocated(ctx, cli, namespace)
+	if err != nil {
+		return resources{}, err
+	}
+	return resources{
+		underlays: underlays.Items,
+		vnis:      vnis.Items,
+		l2vnis:    l2vnis.Items,
+		nodes:     nodes.Items,
+		indexes:   indexes,
+	}, nil
+}
+
+func (r resources) validate() error {
+	return conversion.Validate(r.underlays, r.vnis, r.l2vnis, r.nodes, r.indexes)
+}
+
+// changeErrors validates the resources after a change, returning only the
+// errors caused by the change. The errors the resources already had before
+// it, for example because the nodes outgrew a cidr, are not reported, so that
+// they don't block the unrelated changes.
+func changeErrors(before, after resources) error {
+	err := after.validate()
+	if err == nil {
+		return nil
+	}
+	existing := map[string]bool{}
+	for _, e := range flattenErrors(before.validate()) {
+		existing[e.Error()] = true
+	}
+	res := []error{}
+	for _, e := range flattenErrors(err) {
+		if !existing[e.Error()] {
+			res = append(res, e)
+		}
+	}
+	return errors.Join(res...)
+}
+
+// flattenErrors returns the errors joined in the given one.
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	res := []error{}
+	for _, e := range joined.Unwrap() {
+		res = append(res, flattenErrors(e)...)
+	}
+	return res
+}
+
+func sameObject(a, b client.Object) bool {
+	return a.GetName() == b.GetName() && a.GetNamespace() == b.GetNamespace()
+}
@@ -0,0 +1,367 @@
+// SPDX-License-Identifier:Apache-2.0
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/openperouter/openperouter/api/v1alpha1"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const testNamespace = "openperouter-system"
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add v1alpha1 to scheme: %v", err)
+	}
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add v1 to scheme: %v", err)
+	}
+	return scheme
+}
+
+// admit runs the given object through the admission path of the webhook
+// served by the given validator, as the api server would on create or,
+// if the old object is set, on update.
+func admit(t *testing.T, scheme *runtime.Scheme, validator admission.CustomValidator, old, obj client.Object) admission.Response {
+	t.Helper()
+	req := admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "uid",
+			Operation: admissionv1.Create,
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Object:    runtime.RawExtension{Raw: marshal(t, obj)},
+		},
+	}
+	if old != nil && !reflect.ValueOf(old).IsNil() {
+		req.Operation = admissionv1.Update
+		req.OldObject = runtime.RawExtension{Raw: marshal(t, old)}
+	}
+	return admission.WithCustomValidator(scheme, obj.DeepCopyObject(), validator).Handle(context.Background(), req)
+}
+
+func marshal(t *testing.T, obj client.Object) []byte {
+	t.Helper()
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("failed to marshal %s: %v", obj.GetName(), err)
+	}
+	return raw
+}
+
+func checkResponse(t *testing.T, res admission.Response, expectedErr string) {
+	t.Helper()
+	if expectedErr == "" {
+		if !res.Allowed {
+			t.Fatalf("expecting the object to be admitted, got %v", res.Result)
+		}
+		return
+	}
+	if res.Allowed {
+		t.Fatalf("expecting the object to be rejected with %q, got admitted", expectedErr)
+	}
+	if res.Result == nil || !strings.Contains(res.Result.Message, expectedErr) {
+		t.Fatalf("expecting the object to be rejected with %q, got %v", expectedErr, res.Result)
+	}
+}
+
+func testNodes() []client.Object {
+	return []client.Object{
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+	}
+}
+
+// indexAllocation returns the configmap claiming the given index, as
+// allocated by the controller.
+func indexAllocation(node string, index int) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "node-index-" + strconv.Itoa(index),
+			Namespace: testNamespace,
+			Labels:    map[string]string{"openperouter.io/node-index": ""},
+		},
+		Data: map[string]string{
+			"node":  node,
+			"index": strconv.Itoa(index),
+		},
+	}
+}
+
+func deleting[T client.Object](obj T) T {
+	obj.SetDeletionTimestamp(ptr.To(metav1.Now()))
+	obj.SetFinalizers([]string{"openperouter.io/node-node0"})
+	return obj
+}
+
+func testUnderlay(mutate func(*v1alpha1.Underlay)) *v1alpha1.Underlay {
+	res := &v1alpha1.Underlay{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "Underlay"},
+		ObjectMeta: metav1.ObjectMeta{Name: "underlay", Namespace: testNamespace},
+		Spec: v1alpha1.UnderlaySpec{
+			ASN:      64514,
+			VTEPCIDR: "100.65.0.0/24",
+			Nics:     []string{"eth1"},
+			Neighbors: []v1alpha1.Neighbor{
+				{ASN: 64512, Address: "192.168.11.2"},
+			},
+		},
+	}
+	if mutate != nil {
+		mutate(res)
+	}
+	return res
+}
+
+func testVNI(name, vrf string, id uint32, cidr string) *v1alpha1.VNI {
+	return &v1alpha1.VNI{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "VNI"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec: v1alpha1.VNISpec{
+			ASN:       64514,
+			VRF:       vrf,
+			VNI:       id,
+			LocalCIDR: cidr,
+			LocalASN:  64515,
+		},
+	}
+}
+
+func TestUnderlayWebhook(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    []client.Object
+		old         *v1alpha1.Underlay
+		underlay    *v1alpha1.Underlay
+		expectedErr string
+	}{
+		{
+			name:     "valid",
+			underlay: testUnderlay(nil),
+		},
+		{
+			name:     "valid update of an existing underlay",
+			existing: []client.Object{testUnderlay(func(u *v1alpha1.Underlay) { u.Spec.Nics = []string{"eth2"} })},
+			old:      testUnderlay(func(u *v1alpha1.Underlay) { u.Spec.Nics = []string{"eth2"} }),
+			underlay: testUnderlay(nil),
+		},
+		{
+			name:        "no nics",
+			underlay:    testUnderlay(func(u *v1alpha1.Underlay) { u.Spec.Nics = nil }),
+			expectedErr: "underlay underlay: at least one nic must be set",
+		},
+		{
+			name:        "nic and nics set together",
+			underlay:    testUnderlay(func(u *v1alpha1.Underlay) { u.Spec.Nic = "eth2" }),
+			expectedErr: "nic and nics can't be set together",
+		},
+		{
+			name:        "invalid vtep cidr on update",
+			existing:    []client.Object{testUnderlay(nil)},
+			old:         testUnderlay(nil),
+			underlay:    testUnderlay(func(u *v1alpha1.Underlay) { u.Spec.VTEPCIDR = "100.65.0.0/33" }),
+			expectedErr: "underlay underlay: invalid vtep cidr",
+		},
+		{
+			name: "vtep cidr too small for the allocated indexes",
+			// two nodes, but the indexes of deleted nodes are still allocated
+			existing:    []client.Object{indexAllocation("node0", 0), indexAllocation("node1", 4)},
+			underlay:    testUnderlay(func(u *v1alpha1.Underlay) { u.Spec.VTEPCIDR = "100.65.0.0/30" }),
+			expectedErr: "at least 5 are required",
+		},
+		{
+			name:     "vtep cidr overlapping with an existing vni",
+			existing: []client.Object{testVNI("red", "red", 100, "100.65.0.0/28")},
+			// the cidr of the vni is within the one of the underlay
+			underlay:    testUnderlay(nil),
+			expectedErr: "overlaps with cidr",
+		},
+		{
+			name:     "finalizer added to an underlay the nodes outgrew",
+			existing: []client.Object{indexAllocation("node0", 0), indexAllocation("node1", 4)},
+			old:      testUnderlay(func(u *v1alpha1.Underlay) { u.Spec.VTEPCIDR = "100.65.0.0/30" }),
+			underlay: testUnderlay(func(u *v1alpha1.Underlay) {
+				u.Spec.VTEPCIDR = "100.65.0.0/30"
+				u.Finalizers = []string{"openperouter.io/node-node0"}
+			}),
+		},
+		{
+			name:     "invalid underlay being deleted",
+			old:      testUnderlay(func(u *v1alpha1.Underlay) { u.Spec.Nics = nil }),
+			underlay: deleting(testUnderlay(func(u *v1alpha1.Underlay) { u.Spec.Nics = nil })),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := testScheme(t)
+			cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(testNodes(), tc.existing...)...).Build()
+			res := admit(t, scheme, &UnderlayValidator{client: cli, namespace: testNamespace}, tc.old, tc.underlay)
+			checkResponse(t, res, tc.expectedErr)
+		})
+	}
+}
+
+func TestVNIWebhook(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    []client.Object
+		old         *v1alpha1.VNI
+		vni         *v1alpha1.VNI
+		expectedErr string
+	}{
+		{
+			name:     "valid",
+			existing: []client.Object{testUnderlay(nil), testVNI("blue", "blue", 200, "192.169.11.0/24")},
+			vni:      testVNI("red", "red", 100, "192.169.10.0/24"),
+		},
+		{
+			name:     "valid update of an existing vni",
+			existing: []client.Object{testVNI("red", "red", 100, "192.169.11.0/24")},
+			old:      testVNI("red", "red", 100, "192.169.11.0/24"),
+			vni:      testVNI("red", "red", 100, "192.169.10.0/24"),
+		},
+		{
+			name:        "invalid vni",
+			vni:         testVNI("red", "red", 0, "192.169.10.0/24"),
+			expectedErr: "vni red: invalid vni 0",
+		},
+		{
+			name:        "no local cidr",
+			vni:         testVNI("red", "red", 100, ""),
+			expectedErr: "vni red: at least one local cidr must be set",
+		},
+		{
+			name:        "invalid local cidr on update",
+			existing:    []client.Object{testVNI("red", "red", 100, "192.169.10.0/24")},
+			old:         testVNI("red", "red", 100, "192.169.10.0/24"),
+			vni:         testVNI("red", "red", 100, "192.169.10.0/33"),
+			expectedErr: "vni red: invalid local cidr",
+		},
+		{
+			name: "local cidr too small for the allocated indexes",
+			// the router side takes one more address
+			existing:    []client.Object{indexAllocation("node0", 0), indexAllocation("node1", 3)},
+			vni:         testVNI("red", "red", 100, "192.169.10.0/30"),
+			expectedErr: "at least 5 are required",
+		},
+		{
+			name:        "duplicate vni",
+			existing:    []client.Object{testVNI("blue", "blue", 100, "192.169.11.0/24")},
+			vni:         testVNI("red", "red", 100, "192.169.10.0/24"),
+			expectedErr: "duplicate vni 100",
+		},
+		{
+			name:     "finalizer removed from a vni the nodes outgrew",
+			existing: []client.Object{indexAllocation("node0", 0), indexAllocation("node1", 3)},
+			old: func() *v1alpha1.VNI {
+				res := testVNI("red", "red", 100, "192.169.10.0/30")
+				res.Finalizers = []string{"openperouter.io/node-node0"}
+				return res
+			}(),
+			vni: testVNI("red", "red", 100, "192.169.10.0/30"),
+		},
+		{
+			name:     "invalid vni being deleted",
+			existing: []client.Object{testVNI("blue", "blue", 100, "192.169.11.0/24")},
+			old:      testVNI("red", "red", 100, "192.169.10.0/24"),
+			vni:      deleting(testVNI("red", "red", 100, "192.169.10.0/24")),
+		},
+		{
+			name: "unrelated change with another vni already invalid",
+			existing: []client.Object{
+				testVNI("blue", "blue", 200, "192.169.11.0/30"),
+				indexAllocation("node0", 0), indexAllocation("node1", 3),
+				testVNI("red", "red", 100, "192.169.10.0/24"),
+			},
+			old: testVNI("red", "red", 100, "192.169.10.0/24"),
+			vni: testVNI("red", "red", 100, "192.169.12.0/24"),
+		},
+		{
+			name: "invalid change with another vni already invalid",
+			existing: []client.Object{
+				testVNI("blue", "blue", 200, "192.169.11.0/30"),
+				indexAllocation("node0", 0), indexAllocation("node1", 3),
+				testVNI("red", "red", 100, "192.169.10.0/24"),
+			},
+			old:         testVNI("red", "red", 100, "192.169.10.0/24"),
+			vni:         testVNI("red", "red", 200, "192.169.10.0/24"),
+			expectedErr: "vni red: duplicate vni 200, already used by blue",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := testScheme(t)
+			cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(testNodes(), tc.existing...)...).Build()
+			res := admit(t, scheme, &VNIValidator{client: cli, namespace: testNamespace}, tc.old, tc.vni)
+			checkResponse(t, res, tc.expectedErr)
+			if tc.expectedErr != "" && strings.Contains(res.Result.Message, "vni blue") {
+				t.Fatalf("expecting only the errors caused by the change, got %s", res.Result.Message)
+			}
+		})
+	}
+}
+
+func TestL2VNIWebhook(t *testing.T) {
+	l2vni := func(id uint32, vrf string) *v1alpha1.L2VNI {
+		res := &v1alpha1.L2VNI{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "L2VNI"},
+			ObjectMeta: metav1.ObjectMeta{Name: "layer2", Namespace: testNamespace},
+			Spec:       v1alpha1.L2VNISpec{VNI: id},
+		}
+		if vrf != "" {
+			res.Spec.VRF = ptr.To(vrf)
+		}
+		return res
+	}
+
+	tests := []struct {
+		name        string
+		existing    []client.Object
+		old         *v1alpha1.L2VNI
+		l2vni       *v1alpha1.L2VNI
+		expectedErr string
+	}{
+		{
+			name:     "valid",
+			existing: []client.Object{testVNI("red", "red", 100, "192.169.10.0/24")},
+			l2vni:    l2vni(110, "red"),
+		},
+		{
+			name:        "vrf not defined",
+			l2vni:       l2vni(110, "red"),
+			expectedErr: "l2vni layer2: vrf red is not defined by any vni",
+		},
+		{
+			name:  "l2vni with an undefined vrf being deleted",
+			old:   l2vni(110, "red"),
+			l2vni: deleting(l2vni(110, "red")),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := testScheme(t)
+			cli := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(testNodes(), tc.existing...)...).Build()
+			res := admit(t, scheme, &L2VNIValidator{client: cli, namespace: testNamespace}, tc.old, tc.l2vni)
+			checkResponse(t, res, tc.expectedErr)
+		})
+	}
+}